@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/jsonpatch"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+// maxBatchOperations is the most operations a single batch can hold.
+const maxBatchOperations = 100
+
+// batchOperation is one operation of a POST /v1/movies/batch request. Movie holds the
+// movie to create or, for an update, a merge patch of the fields to change. The
+// version of an update or delete defaults to the current one.
+type batchOperation struct {
+	Op      string          `json:"op"`
+	ID      int64           `json:"id"`
+	Version *int32          `json:"version"`
+	Movie   json.RawMessage `json:"movie"`
+}
+
+// batchResult reports the outcome of an operation. When the batch fails, the
+// operations that were fine are reported as not applied. A create that duplicates an
+// existing movie fails with that movie in Movie, like POST /v1/movies.
+type batchResult struct {
+	Index  int               `json:"index"`
+	Op     string            `json:"op"`
+	Status string            `json:"status"`
+	ID     int64             `json:"id,omitzero"`
+	Movie  *data.Movie       `json:"movie,omitzero"`
+	Errors map[string]string `json:"errors,omitzero"`
+
+	status int // the HTTP status this result alone would have
+}
+
+// batchMoviesHandler creates, updates and deletes movies in a single transaction: either
+// every operation is applied or none is. The response reports the outcome of each
+// operation, and a failed batch responds with the status of the first operation that
+// failed, e.g. 409 Conflict for a version conflict. Creates are checked for duplicates,
+// of existing movies and of each other, unless allow_duplicate=true.
+func (app *application) batchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Operations []batchOperation `json:"operations"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	allowDuplicate := app.readString(r.URL.Query(), "allow_duplicate", "false")
+
+	v := validator.New()
+	v.Check(validator.PermittedValue(allowDuplicate, "true", "false"), "allow_duplicate", "must be true or false")
+	v.Check(len(input.Operations) > 0, "operations", "must contain at least one operation")
+	v.Check(len(input.Operations) <= maxBatchOperations, "operations", "must not contain more than 100 operations")
+
+	// every operation sees the movies as they were before the batch, so a movie can
+	// only be changed once
+	var ids []int64
+	for _, op := range input.Operations {
+		if op.Op != data.BatchCreate && op.ID != 0 {
+			ids = append(ids, op.ID)
+		}
+	}
+	v.Check(validator.Unique(ids), "operations", "must not change the same movie more than once")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	genres, err := app.models.Genres.GetIndex(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	results := make([]*batchResult, len(input.Operations))
+	ops := make([]*data.MovieOperation, len(input.Operations))
+	befores := make([]*data.Movie, len(input.Operations))
+	failed := false
+
+	// the first create of each title and year
+	seen := make(map[string]int)
+
+	for i, op := range input.Operations {
+		results[i] = &batchResult{Index: i, Op: op.Op, ID: op.ID}
+
+		ops[i], befores[i], err = app.prepareBatchOperation(r, op, genres, results[i])
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if results[i].Errors == nil && op.Op == data.BatchCreate && allowDuplicate == "false" {
+			key := data.DuplicateKey(ops[i].Movie)
+			if first, ok := seen[key]; ok {
+				results[i].fail(http.StatusConflict, "movie", fmt.Sprintf("has the same title and year as operation %d", first))
+			} else {
+				seen[key] = i
+
+				duplicate, err := app.models.Movies.FindDuplicate(r.Context(), ops[i].Movie)
+				switch {
+				case err == nil:
+					results[i].fail(http.StatusConflict, "movie", "a movie with the same title and year already exists; pass allow_duplicate=true to create it anyway")
+					results[i].Movie = duplicate
+				case !errors.Is(err, data.ErrRecordNotFound):
+					app.serverErrorResponse(w, r, err)
+					return
+				}
+			}
+		}
+
+		if results[i].Errors != nil {
+			failed = true
+		}
+	}
+
+	if !failed {
+		for i, op := range ops {
+			switch op.Action {
+			case data.BatchCreate:
+				op.Revision = app.newMovieRevision(r, data.RevisionInsert, nil, op.Movie)
+			case data.BatchUpdate:
+				op.Revision = app.newMovieRevision(r, data.RevisionUpdate, befores[i], op.Movie)
+			case data.BatchDelete:
+				op.Revision = app.newMovieRevision(r, data.RevisionDelete, befores[i], nil)
+			}
+		}
+
+		err = app.models.Movies.ExecuteBatch(r.Context(), ops)
+		switch {
+		case errors.Is(err, data.ErrBatchFailed):
+			failed = true
+			for i, op := range ops {
+				if errors.Is(op.Err, data.ErrEditConflict) {
+					results[i].fail(http.StatusConflict, "version", "the movie has been changed or deleted since it was read")
+				}
+			}
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if failed {
+		status := 0
+		for _, result := range results {
+			if result.Errors == nil {
+				result.Status = "not_applied"
+			} else if status == 0 {
+				status = result.status
+			}
+		}
+
+		err = app.writeJSON(w, status, envelope{"results": results}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for i, op := range ops {
+		result := results[i]
+		result.ID = op.Movie.ID
+
+		switch op.Action {
+		case data.BatchCreate:
+			result.Status = "created"
+			result.Movie = op.Movie
+		case data.BatchUpdate:
+			result.Status = "updated"
+			result.Movie = op.Movie
+		case data.BatchDelete:
+			result.Status = "deleted"
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// prepareBatchOperation turns an operation of the request into a data.MovieOperation,
+// along with the movie as it was before, for the revision history. Problems with the
+// operation are reported in result; the error is only for unexpected failures.
+func (app *application) prepareBatchOperation(r *http.Request, op batchOperation, genres data.GenreIndex, result *batchResult) (*data.MovieOperation, *data.Movie, error) {
+	v := validator.New()
+	v.Check(validator.PermittedValue(op.Op, data.BatchCreate, data.BatchUpdate, data.BatchDelete), "op", "must be create, update or delete")
+
+	switch op.Op {
+	case data.BatchCreate:
+		v.Check(op.ID == 0, "id", "must not be set when creating a movie")
+		v.Check(op.Version == nil, "version", "must not be set when creating a movie")
+		v.Check(op.Movie != nil, "movie", "must be provided")
+	case data.BatchUpdate:
+		v.Check(op.ID > 0, "id", "must be provided")
+		v.Check(op.Movie != nil, "movie", "must be provided")
+	case data.BatchDelete:
+		v.Check(op.ID > 0, "id", "must be provided")
+		v.Check(op.Movie == nil, "movie", "must not be set when deleting a movie")
+	}
+	if op.Op != data.BatchCreate && app.config.movies.requireIfMatch {
+		v.Check(op.Version != nil, "version", "must be provided")
+	}
+
+	if !v.Valid() {
+		result.failValidation(v)
+		return nil, nil, nil
+	}
+
+	var before *data.Movie
+	doc := any(map[string]any{})
+
+	if op.Op != data.BatchCreate {
+		movie, err := app.models.Movies.Get(r.Context(), op.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				result.fail(http.StatusNotFound, "id", "the requested resource could not be found")
+				return nil, nil, nil
+			default:
+				return nil, nil, err
+			}
+		}
+		if op.Version != nil && *op.Version != movie.Version {
+			result.fail(http.StatusConflict, "version", "the movie has been changed since it was read")
+			return nil, nil, nil
+		}
+		before = movie
+
+		if op.Op == data.BatchDelete {
+			return &data.MovieOperation{Action: op.Op, Movie: &data.Movie{ID: movie.ID, Version: movie.Version}}, before, nil
+		}
+
+		doc, err = toJSONValue(movieDocument{
+			Title:   movie.Title,
+			Year:    movie.Year,
+			Runtime: movie.Runtime,
+			Genres:  movie.Genres,
+			Version: movie.Version,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var patch map[string]any
+	if err := json.Unmarshal(op.Movie, &patch); err != nil || patch == nil {
+		v.AddError("movie", "must be a JSON object")
+		result.failValidation(v)
+		return nil, nil, nil
+	}
+
+	patched := decodeMovieDocument(jsonpatch.MergePatch(doc, patch), v)
+	if !v.Valid() {
+		result.failValidation(v)
+		return nil, nil, nil
+	}
+
+	var version int32
+	if before != nil {
+		version = before.Version
+	}
+	if patched.Version != version {
+		if op.Op == data.BatchCreate {
+			v.AddError("version", "must not be set when creating a movie")
+			result.failValidation(v)
+		} else {
+			result.fail(http.StatusConflict, "version", "the movie has been changed since it was read")
+		}
+		return nil, nil, nil
+	}
+
+	movie := &data.Movie{
+		Title:   patched.Title,
+		Year:    patched.Year,
+		Runtime: patched.Runtime,
+		Genres:  genres.Normalize(patched.Genres),
+	}
+	if before != nil {
+		movie.ID = before.ID
+		movie.Version = before.Version
+	}
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		result.failValidation(v)
+		return nil, nil, nil
+	}
+
+	return &data.MovieOperation{Action: op.Op, Movie: movie}, before, nil
+}
+
+func (res *batchResult) fail(status int, key, message string) {
+	res.Status = "failed"
+	res.Errors = map[string]string{key: message}
+	res.status = status
+}
+
+func (res *batchResult) failValidation(v *validator.Validator) {
+	res.Status = "failed"
+	res.Errors = v.Errors
+	res.status = http.StatusUnprocessableEntity
+}
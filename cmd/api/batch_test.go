@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestBatchMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.ReviewsWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	moana := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	heat := ts.createTestMovie(t, token, map[string]any{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}})
+
+	newMovie := map[string]any{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"horror"}}
+
+	tests := []struct {
+		name         string
+		token        string
+		operations   []map[string]any
+		wantStatus   int
+		wantStatuses []string
+		wantErrKeys  map[int]string // a key of the errors of each failed operation
+	}{
+		{
+			name:       "without permission",
+			token:      readerToken,
+			operations: []map[string]any{{"op": "create", "movie": newMovie}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "no operations",
+			token:      token,
+			operations: []map[string]any{},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "same movie twice",
+			token:      token,
+			operations: []map[string]any{{"op": "update", "id": moana, "movie": map[string]any{"year": 2017}}, {"op": "delete", "id": moana}},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:  "invalid operation",
+			token: token,
+			operations: []map[string]any{
+				{"op": "create", "movie": newMovie},
+				{"op": "update", "id": moana, "movie": map[string]any{"year": 3000}},
+				{"op": "rename", "id": heat},
+			},
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantStatuses: []string{"not_applied", "failed", "failed"},
+			wantErrKeys:  map[int]string{1: "year", 2: "op"},
+		},
+		{
+			name:  "missing movie",
+			token: token,
+			operations: []map[string]any{
+				{"op": "create", "movie": newMovie},
+				{"op": "delete", "id": 999},
+			},
+			wantStatus:   http.StatusNotFound,
+			wantStatuses: []string{"not_applied", "failed"},
+			wantErrKeys:  map[int]string{1: "id"},
+		},
+		{
+			name:  "version conflict",
+			token: token,
+			operations: []map[string]any{
+				{"op": "create", "movie": newMovie},
+				{"op": "update", "id": moana, "version": 7, "movie": map[string]any{"title": "Moana!"}},
+			},
+			wantStatus:   http.StatusConflict,
+			wantStatuses: []string{"not_applied", "failed"},
+			wantErrKeys:  map[int]string{1: "version"},
+		},
+		{
+			name:  "create, update and delete",
+			token: token,
+			operations: []map[string]any{
+				{"op": "create", "movie": newMovie},
+				{"op": "update", "id": moana, "version": 1, "movie": map[string]any{"title": "Moana!", "genres": []string{"animation", "musical"}}},
+				{"op": "delete", "id": heat},
+			},
+			wantStatus:   http.StatusOK,
+			wantStatuses: []string{"created", "updated", "deleted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/movies/batch", tt.token, map[string]any{"operations": tt.operations})
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatuses == nil {
+				return
+			}
+
+			results := res.body["results"].([]any)
+			var statuses []string
+			for i, result := range results {
+				result := result.(map[string]any)
+				statuses = append(statuses, result["status"].(string))
+
+				if key, ok := tt.wantErrKeys[i]; ok {
+					if _, ok := result["errors"].(map[string]any)[key]; !ok {
+						t.Errorf("operation %d: got errors %v; want one for %q", i, result["errors"], key)
+					}
+				}
+			}
+			if fmt.Sprint(statuses) != fmt.Sprint(tt.wantStatuses) {
+				t.Errorf("got statuses %v; want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+
+	// only the last batch went through
+	res := ts.do(t, http.MethodGet, "/v1/movies?sort=title", token, nil)
+	var titles []string
+	for _, movie := range res.body["movies"].([]any) {
+		titles = append(titles, movie.(map[string]any)["title"].(string))
+	}
+	if want := []string{"Alien", "Moana!"}; fmt.Sprint(titles) != fmt.Sprint(want) {
+		t.Errorf("got titles %q; want %q", titles, want)
+	}
+
+	// the revisions are recorded with the batch, at the versions it left the movies at
+	res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d/revisions?sort=-version", moana), token, nil)
+	revisions := res.body["revisions"].([]any)
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions for the updated movie; want 2", len(revisions))
+	}
+	if latest := revisions[0].(map[string]any); latest["action"] != "update" || latest["version"] != 2.0 {
+		t.Errorf("got latest revision %v; want the update to version 2", latest)
+	}
+
+	// an updated movie comes back whole, with the fields the operation leaves alone
+	if res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/reviews", moana), token, map[string]any{"rating": 8}); res.status != http.StatusCreated {
+		t.Fatalf("reviewing movie: got status %d, body %v", res.status, res.body)
+	}
+	res = ts.do(t, http.MethodPost, "/v1/movies/batch", token, map[string]any{"operations": []map[string]any{{"op": "update", "id": moana, "movie": map[string]any{"year": 2017}}}})
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+	}
+	updated := res.body["results"].([]any)[0].(map[string]any)["movie"]
+
+	res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", moana), token, nil)
+	if !reflect.DeepEqual(updated, res.body["movie"]) {
+		t.Errorf("got updated movie %v; want %v", updated, res.body["movie"])
+	}
+}
+
+func TestGetMoviesByIDs(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	var ids []int64
+	for _, title := range []string{"Moana", "Heat", "Alien"} {
+		ids = append(ids, ts.createTestMovie(t, token, map[string]any{"title": title, "year": 2000, "runtime": "100 mins", "genres": []string{"drama"}}))
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "some ids", query: fmt.Sprintf("?ids=%d,%d&sort=title", ids[0], ids[2]), wantStatus: http.StatusOK, wantTitles: []string{"Alien", "Moana"}},
+		{name: "missing ids are left out", query: fmt.Sprintf("?ids=%d,999", ids[1]), wantStatus: http.StatusOK, wantTitles: []string{"Heat"}},
+		{name: "not an integer", query: "?ids=1,two", wantStatus: http.StatusUnprocessableEntity},
+		{name: "negative id", query: "?ids=-1", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies"+tt.query, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var titles []string
+			for _, movie := range res.body["movies"].([]any) {
+				titles = append(titles, movie.(map[string]any)["title"].(string))
+			}
+			if fmt.Sprint(titles) != fmt.Sprint(tt.wantTitles) {
+				t.Errorf("got titles %q; want %q", titles, tt.wantTitles)
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+func (app *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Kind        string `json:"kind"`
+		Description string `json:"description"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection := &data.Collection{
+		Name:        input.Name,
+		Kind:        input.Kind,
+		Description: input.Description,
+	}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.Insert(r.Context(), collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCollectionName):
+			v.AddError("name", "a collection with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/collections/%d", collection.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Kind        *string `json:"kind"`
+		Description *string `json:"description"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name == nil && input.Kind == nil && input.Description == nil {
+		app.unprocessableEntityResponse(w, r, "provide at least one field to update")
+		return
+	}
+
+	if input.Name != nil {
+		collection.Name = *input.Name
+	}
+	if input.Kind != nil {
+		collection.Kind = *input.Kind
+	}
+	if input.Description != nil {
+		collection.Description = *input.Description
+	}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.Update(r.Context(), collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateCollectionName):
+			v.AddError("name", "a collection with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Collections.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "collection successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		Kind string
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Kind = app.readString(qs, "kind", "")
+	v.Check(input.Kind == "" || validator.PermittedValue(input.Kind, data.CollectionKinds...), "kind", "must be series, franchise or curated")
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "name")
+	input.SortSafeList = []string{"name", "created_at", "updated_at", "-name", "-created_at", "-updated_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	collections, metadata, err := app.models.Collections.GetAll(r.Context(), input.Name, input.Kind, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"collections": collections, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCollectionMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "position")
+	input.SortSafeList = []string{"position", "added_at", "title", "year", "-position", "-added_at", "-title", "-year"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	items, metadata, err := app.models.Collections.GetMovies(r.Context(), collection.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MovieID  int64 `json:"movie_id"`
+		Position int   `json:"position"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.MovieID > 0, "movie_id", "must be provided")
+	v.Check(input.Position >= 0, "position", "must not be negative")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.AddMovie(r.Context(), collection.ID, input.MovieID, input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateCollectionMovie):
+			v.AddError("movie_id", "is already in the collection")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollectionResponse(w, r, http.StatusCreated, collection.ID)
+}
+
+func (app *application) moveCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Position int `json:"position"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Position >= 1, "position", "must be provided and greater than zero")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.MoveMovie(r.Context(), collection.ID, movieID, input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollectionResponse(w, r, http.StatusOK, collection.ID)
+}
+
+func (app *application) removeCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Collections.RemoveMovie(r.Context(), collection.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollectionResponse(w, r, http.StatusOK, collection.ID)
+}
+
+// readCollection looks up the collection named by the {id} URL parameter. If it
+// returns false, an error response has already been sent.
+func (app *application) readCollection(w http.ResponseWriter, r *http.Request) (*data.Collection, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	collection, err := app.models.Collections.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// writeCollectionResponse sends the current state of a collection after its movies
+// have changed.
+func (app *application) writeCollectionResponse(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	collection, err := app.models.Collections.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, status, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestCollections(t *testing.T) {
+	ts := newTestServer(t)
+	writerToken := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	alien := ts.createTestMovie(t, writerToken, map[string]any{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"horror"}})
+	aliens := ts.createTestMovie(t, writerToken, map[string]any{"title": "Aliens", "year": 1986, "runtime": "137 mins", "genres": []string{"action"}})
+	alien3 := ts.createTestMovie(t, writerToken, map[string]any{"title": "Alien 3", "year": 1992, "runtime": "114 mins", "genres": []string{"horror"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}})
+
+	saga := map[string]any{"name": "Alien", "kind": "franchise"}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "create without permission", method: http.MethodPost, path: "/v1/collections", token: readerToken, body: saga, wantStatus: http.StatusForbidden},
+		{name: "create with invalid kind", method: http.MethodPost, path: "/v1/collections", token: writerToken, body: map[string]any{"name": "Alien", "kind": "saga"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create", method: http.MethodPost, path: "/v1/collections", token: writerToken, body: saga, wantStatus: http.StatusCreated},
+		{name: "create again", method: http.MethodPost, path: "/v1/collections", token: writerToken, body: saga, wantStatus: http.StatusUnprocessableEntity},
+		{name: "show", method: http.MethodGet, path: "/v1/collections/1", token: readerToken, wantStatus: http.StatusOK},
+		{name: "show missing collection", method: http.MethodGet, path: "/v1/collections/999", token: readerToken, wantStatus: http.StatusNotFound},
+		{name: "list", method: http.MethodGet, path: "/v1/collections?kind=franchise&name=alien", token: readerToken, wantStatus: http.StatusOK},
+		{name: "list with invalid kind", method: http.MethodGet, path: "/v1/collections?kind=saga", token: readerToken, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update without permission", method: http.MethodPatch, path: "/v1/collections/1", token: readerToken, body: map[string]any{"kind": "series"}, wantStatus: http.StatusForbidden},
+		{name: "update", method: http.MethodPatch, path: "/v1/collections/1", token: writerToken, body: map[string]any{"name": "Alien Quadrilogy", "kind": "series"}, wantStatus: http.StatusOK},
+		{name: "update without fields", method: http.MethodPatch, path: "/v1/collections/1", token: writerToken, body: map[string]any{}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "add without permission", method: http.MethodPost, path: "/v1/collections/1/movies", token: readerToken, body: map[string]any{"movie_id": alien}, wantStatus: http.StatusForbidden},
+		{name: "add", method: http.MethodPost, path: "/v1/collections/1/movies", token: writerToken, body: map[string]any{"movie_id": alien3}, wantStatus: http.StatusCreated},
+		{name: "add at the start", method: http.MethodPost, path: "/v1/collections/1/movies", token: writerToken, body: map[string]any{"movie_id": alien, "position": 1}, wantStatus: http.StatusCreated},
+		{name: "add at the end", method: http.MethodPost, path: "/v1/collections/1/movies", token: writerToken, body: map[string]any{"movie_id": aliens}, wantStatus: http.StatusCreated},
+		{name: "add again", method: http.MethodPost, path: "/v1/collections/1/movies", token: writerToken, body: map[string]any{"movie_id": alien}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "add missing movie", method: http.MethodPost, path: "/v1/collections/1/movies", token: writerToken, body: map[string]any{"movie_id": 999}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "add to missing collection", method: http.MethodPost, path: "/v1/collections/999/movies", token: writerToken, body: map[string]any{"movie_id": alien}, wantStatus: http.StatusNotFound},
+		{name: "move", method: http.MethodPatch, path: fmt.Sprintf("/v1/collections/1/movies/%d", aliens), token: writerToken, body: map[string]any{"position": 2}, wantStatus: http.StatusOK},
+		{name: "move missing movie", method: http.MethodPatch, path: "/v1/collections/1/movies/999", token: writerToken, body: map[string]any{"position": 1}, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, "/v1/collections/1/movies", readerToken, nil)
+	var titles []string
+	for _, item := range res.body["movies"].([]any) {
+		titles = append(titles, item.(map[string]any)["movie"].(map[string]any)["title"].(string))
+	}
+	if want := []string{"Alien", "Aliens", "Alien 3"}; !slices.Equal(titles, want) {
+		t.Errorf("got collection movies %v; want %v", titles, want)
+	}
+
+	// the collection filter on the movies listing
+	res = ts.do(t, http.MethodGet, "/v1/movies?collection=1", readerToken, nil)
+	if got := len(res.body["movies"].([]any)); got != 3 {
+		t.Errorf("got %d movies in the collection; want 3", got)
+	}
+
+	res = ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/collections/1/movies/%d", alien3), writerToken, nil)
+	if got := res.body["collection"].(map[string]any)["movie_count"]; res.status != http.StatusOK || got != float64(2) {
+		t.Errorf("got status %d and movie_count %v after removing a movie; want %d and 2", res.status, got, http.StatusOK)
+	}
+
+	res = ts.do(t, http.MethodDelete, "/v1/collections/1", writerToken, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d deleting the collection; want %d", res.status, http.StatusOK)
+	}
+
+	res = ts.do(t, http.MethodGet, "/v1/movies?collection=1", readerToken, nil)
+	if got := len(res.body["movies"].([]any)); got != 0 {
+		t.Errorf("got %d movies in a deleted collection; want 0", got)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+// maxMergeDuplicates is the most duplicates a single merge can fold into a movie.
+const maxMergeDuplicates = 20
+
+// The duplicateMovieResponse() helper sends a 409 Conflict response for a movie that
+// looks like one that already exists, along with the existing movie so that the client
+// can use it instead.
+func (app *application) duplicateMovieResponse(w http.ResponseWriter, r *http.Request, existing *data.Movie) {
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", existing.ID))
+
+	env := envelope{
+		"error": "a movie with the same title and year already exists; pass allow_duplicate=true to create it anyway",
+		"movie": existing,
+	}
+
+	err := app.writeJSON(w, http.StatusConflict, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeMoviesHandler folds the movies listed in duplicate_ids into the movie in the URL:
+// their credits, reviews, external ids and places in lists move over to it, and the
+// duplicates are moved to the trash.
+func (app *application) mergeMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		DuplicateIDs []int64 `json:"duplicate_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(input.DuplicateIDs) > 0, "duplicate_ids", "must contain at least one movie id")
+	v.Check(len(input.DuplicateIDs) <= maxMergeDuplicates, "duplicate_ids", "must not contain more than 20 movie ids")
+	v.Check(validator.Unique(input.DuplicateIDs), "duplicate_ids", "must not contain duplicate values")
+	v.Check(!slices.Contains(input.DuplicateIDs, id), "duplicate_ids", "must not contain the movie being merged into")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	for _, duplicateID := range input.DuplicateIDs {
+		_, err = app.models.Movies.Get(r.Context(), duplicateID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("duplicate_ids", fmt.Sprintf("movie %d does not exist", duplicateID))
+			default:
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.Merge(r.Context(), id, input.DuplicateIDs, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			// one of the movies was deleted since it was read
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the ratings of the movie changed with the reviews it took over
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie, "merged_ids": input.DuplicateIDs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestDuplicateMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Spider-Man: No Way Home", "year": 2021, "runtime": "148 mins", "genres": []string{"superhero"}})
+	amelieID := ts.createTestMovie(t, token, map[string]any{"title": "Amélie", "year": 2001, "runtime": "122 mins", "genres": []string{"comedy", "romance"}})
+
+	tests := []struct {
+		name         string
+		path         string
+		title        string
+		year         int
+		wantStatus   int
+		wantExisting int64
+	}{
+		{name: "same title and year", path: "/v1/movies", title: "Spider-Man: No Way Home", year: 2021, wantStatus: http.StatusConflict, wantExisting: id},
+		{name: "same title but for case and punctuation", path: "/v1/movies", title: "spider man  no way home", year: 2021, wantStatus: http.StatusConflict, wantExisting: id},
+		{name: "same non-ASCII title but for case", path: "/v1/movies", title: "AMÉLIE", year: 2001, wantStatus: http.StatusConflict, wantExisting: amelieID},
+		{name: "same title, another year", path: "/v1/movies", title: "Spider-Man: No Way Home", year: 2020, wantStatus: http.StatusCreated},
+		{name: "duplicate allowed", path: "/v1/movies?allow_duplicate=true", title: "Spider-Man: No Way Home", year: 2021, wantStatus: http.StatusCreated},
+		{name: "invalid allow_duplicate", path: "/v1/movies?allow_duplicate=yes", title: "Spider-Man: Far From Home", year: 2019, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, tt.path, token, map[string]any{"title": tt.title, "year": tt.year, "runtime": "148 mins", "genres": []string{"superhero"}})
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+
+			if tt.wantStatus == http.StatusConflict {
+				if got := res.body["movie"].(map[string]any)["id"]; got != float64(tt.wantExisting) {
+					t.Errorf("got existing movie %v; want %d", got, tt.wantExisting)
+				}
+				if got, want := res.header.Get("Location"), fmt.Sprintf("/v1/movies/%d", tt.wantExisting); got != want {
+					t.Errorf("got Location %q; want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDuplicateMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	header := http.Header{"Content-Type": {"text/csv"}}
+
+	heatID := ts.createTestMovie(t, token, map[string]any{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}})
+
+	body := strings.Join([]string{
+		"title,year,runtime,genres",
+		"heat,1995,170,crime",
+		"Heat,1986,101,crime",
+		"Alien,1979,117,horror",
+		"ALIEN!,1979,117,horror",
+	}, "\n")
+
+	res := ts.doWithHeader(t, http.MethodPost, "/v1/movies/import", token, header, body)
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+	}
+
+	report := res.body["report"].(map[string]any)
+	if report["imported"] != 2.0 || report["failed"] != 2.0 {
+		t.Errorf("got report totals %v; want 2 imported and 2 failed", report)
+	}
+	rows := report["rows"].([]any)
+	if row := rows[0].(map[string]any); row["status"] != "failed" || row["duplicate_of"] != float64(heatID) {
+		t.Errorf("got row %v; want it failed as a duplicate of movie %d", row, heatID)
+	}
+	if row := rows[3].(map[string]any); fmt.Sprint(row["errors"]) != "map[title:has the same title and year as row 3]" {
+		t.Errorf("got row %v; want it failed as a duplicate of row 3", row)
+	}
+
+	res = ts.doWithHeader(t, http.MethodPost, "/v1/movies/import?allow_duplicate=true", token, header, body)
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+	}
+	if report := res.body["report"].(map[string]any); report["imported"] != 4.0 {
+		t.Errorf("got %v imported with allow_duplicate=true; want 4", report["imported"])
+	}
+
+	res = ts.doWithHeader(t, http.MethodPost, "/v1/movies/import?allow_duplicate=yes", token, header, body)
+	if res.status != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d; want %d", res.status, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestBatchDuplicateMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	heatID := ts.createTestMovie(t, token, map[string]any{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}})
+
+	heat := map[string]any{"title": "HEAT", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}}
+	alien := map[string]any{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"horror"}}
+
+	t.Run("existing movie", func(t *testing.T) {
+		res := ts.do(t, http.MethodPost, "/v1/movies/batch", token, map[string]any{"operations": []map[string]any{
+			{"op": "create", "movie": alien},
+			{"op": "create", "movie": heat},
+		}})
+		if res.status != http.StatusConflict {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusConflict, res.body)
+		}
+
+		result := res.body["results"].([]any)[1].(map[string]any)
+		if result["status"] != "failed" || result["movie"].(map[string]any)["id"] != float64(heatID) {
+			t.Errorf("got result %v; want it failed with movie %d", result, heatID)
+		}
+	})
+
+	t.Run("within the batch", func(t *testing.T) {
+		res := ts.do(t, http.MethodPost, "/v1/movies/batch", token, map[string]any{"operations": []map[string]any{
+			{"op": "create", "movie": alien},
+			{"op": "create", "movie": alien},
+		}})
+		if res.status != http.StatusConflict {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusConflict, res.body)
+		}
+
+		result := res.body["results"].([]any)[1].(map[string]any)
+		if fmt.Sprint(result["errors"]) != "map[movie:has the same title and year as operation 0]" {
+			t.Errorf("got result %v; want it failed as a duplicate of operation 0", result)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		res := ts.do(t, http.MethodPost, "/v1/movies/batch?allow_duplicate=true", token, map[string]any{"operations": []map[string]any{
+			{"op": "create", "movie": heat},
+			{"op": "create", "movie": heat},
+		}})
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+	})
+}
+
+func TestMergeMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	aliceToken := ts.newActivatedUser(t, "alice@example.com")
+	bobToken := ts.newActivatedUser(t, "bob@example.com")
+
+	movie := map[string]any{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}}
+	target := ts.createTestMovie(t, token, movie)
+	other := ts.createTestMovie(t, token, map[string]any{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"horror"}})
+
+	res := ts.do(t, http.MethodPost, "/v1/movies?allow_duplicate=true", token, movie)
+	if res.status != http.StatusCreated {
+		t.Fatalf("creating duplicate: got status %d, body %v", res.status, res.body)
+	}
+	duplicate := int64(res.body["movie"].(map[string]any)["id"].(float64))
+
+	// alice reviewed both copies, bob only the duplicate
+	for _, review := range []struct {
+		token   string
+		movieID int64
+		rating  int
+	}{{aliceToken, target, 8}, {aliceToken, duplicate, 2}, {bobToken, duplicate, 10}} {
+		res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/reviews", review.movieID), review.token, map[string]any{"rating": review.rating})
+		if res.status != http.StatusCreated {
+			t.Fatalf("creating review: got status %d, body %v", res.status, res.body)
+		}
+	}
+
+	res = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/external-ids/imdb", duplicate), token, map[string]any{"external_id": "tt0113277"})
+	if res.status != http.StatusOK {
+		t.Fatalf("setting external id: got status %d, body %v", res.status, res.body)
+	}
+
+	path := fmt.Sprintf("/v1/movies/%d/merge", target)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       any
+		wantStatus int
+	}{
+		{name: "missing movie", path: "/v1/movies/999/merge", body: map[string]any{"duplicate_ids": []int64{duplicate}}, wantStatus: http.StatusNotFound},
+		{name: "no duplicates", path: path, body: map[string]any{"duplicate_ids": []int64{}}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "into itself", path: path, body: map[string]any{"duplicate_ids": []int64{target}}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "missing duplicate", path: path, body: map[string]any{"duplicate_ids": []int64{duplicate, 999}}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "merge", path: path, body: map[string]any{"duplicate_ids": []int64{duplicate}}, wantStatus: http.StatusOK},
+		{name: "merge again", path: path, body: map[string]any{"duplicate_ids": []int64{duplicate}}, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, tt.path, token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	// the target kept alice's own review and took over bob's, and the imdb id
+	res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=external_ids", target), token, nil)
+	merged := res.body["movie"].(map[string]any)
+	if merged["rating_count"] != float64(2) || merged["average_rating"] != float64(9) {
+		t.Errorf("got rating_count %v and average_rating %v; want 2 and 9", merged["rating_count"], merged["average_rating"])
+	}
+	// one version for alice's review, and another for the merge
+	if merged["version"] != float64(3) {
+		t.Errorf("got version %v; want 3", merged["version"])
+	}
+	if externalIDs, _ := merged["external_ids"].([]any); len(externalIDs) != 1 {
+		t.Errorf("got external ids %v; want the imdb id of the duplicate", merged["external_ids"])
+	}
+
+	res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", duplicate), token, nil)
+	if res.status != http.StatusNotFound {
+		t.Errorf("got status %d for the duplicate; want %d", res.status, http.StatusNotFound)
+	}
+
+	res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", other), token, nil)
+	if res.status != http.StatusOK {
+		t.Errorf("got status %d for an unrelated movie; want %d", res.status, http.StatusOK)
+	}
+}
@@ -53,11 +53,37 @@ func (app *application) unprocessableEntityResponse(w http.ResponseWriter, r *ht
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, msg)
 }
 
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request, msg string) {
+	app.errorResponse(w, r, http.StatusNotAcceptable, msg)
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, msg string) {
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, msg)
+}
+
+func (app *application) contentTooLargeResponse(w http.ResponseWriter, r *http.Request, msg string) {
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, msg)
+}
+
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, msg string) {
+	app.errorResponse(w, r, http.StatusConflict, msg)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has been modified since it was last fetched, fetch it again and retry"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this request must include an If-Match header with the resource's ETag"
+	app.errorResponse(w, r, http.StatusPreconditionRequired, message)
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+const exportFormatJSON = "json"
+
+// exportContentTypes maps each export format to the Content-Type it is sent with.
+var exportContentTypes = map[string]string{
+	importFormatCSV:    "text/csv; charset=utf-8",
+	importFormatNDJSON: "application/x-ndjson",
+	exportFormatJSON:   "application/json",
+}
+
+// exportFlushEvery is the number of movies written between flushes of the response, so
+// that the client sees the export arrive while it is being read from the database.
+// Each flush also gives the export another serverWriteTimeout, so that a long export
+// isn't cut short by the server's write timeout while a stalled one still is.
+const exportFlushEvery = 100
+
+// exportMoviesHandler streams every movie matching the title, genres and genres_match
+// filters of listMoviesHandler, without pagination. The format comes from the format query string
+// parameter or, failing that, the Accept header. The movies are written out as they are
+// read, so nothing is sent until the first one arrives: an error before that gets the
+// usual error response, while one after it can no longer change the status code and is
+// logged, with the response cut short instead.
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MovieFilters
+		Format string
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	err := app.readTitleAndGenres(r.Context(), qs, &input.MovieFilters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	input.Format = app.readString(qs, "format", "")
+
+	v.Check(validator.PermittedValue(input.GenresMatch, data.GenresMatchAll, data.GenresMatchAny), "genres_match", "must be all or any")
+	if input.Format != "" {
+		v.Check(validator.PermittedValue(input.Format, importFormatCSV, importFormatNDJSON, exportFormatJSON), "format", "must be csv, ndjson or json")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Format == "" {
+		input.Format = exportFormatFromAccept(r.Header.Get("Accept"))
+		if input.Format == "" {
+			app.notAcceptableResponse(w, r, "the export can only be sent as text/csv, application/x-ndjson or application/json")
+			return
+		}
+	}
+
+	var exporter movieExporter
+	switch input.Format {
+	case importFormatCSV:
+		exporter = newCSVMovieExporter(w)
+	case importFormatNDJSON:
+		exporter = &ndjsonMovieExporter{w: w}
+	default:
+		exporter = &jsonMovieExporter{w: w}
+	}
+
+	rc := http.NewResponseController(w)
+	count := 0
+	started := false
+
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", exportContentTypes[input.Format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movies-%s.%s"`, time.Now().UTC().Format("20060102"), input.Format))
+		return exporter.begin()
+	}
+
+	err = extendExportDeadline(rc)
+	if err == nil {
+		err = app.models.Movies.Export(r.Context(), input.MovieFilters, func(movie *data.Movie) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+
+			if err := exporter.write(movie); err != nil {
+				return err
+			}
+
+			count++
+			if count%exportFlushEvery == 0 {
+				return flushExport(exporter, rc)
+			}
+			return nil
+		})
+	}
+	if err == nil && !started {
+		// an empty export
+		err = start()
+	}
+	if err == nil {
+		err = exporter.end()
+	}
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, fmt.Errorf("export cut short after %d movies: %w", count, err))
+	}
+}
+
+// flushExport pushes whatever the exporter has buffered out to the client, and extends
+// the deadline for the rest of the export.
+func flushExport(exporter movieExporter, rc *http.ResponseController) error {
+	if err := exporter.flush(); err != nil {
+		return err
+	}
+
+	err := extendExportDeadline(rc)
+	if err != nil {
+		return err
+	}
+
+	err = rc.Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// extendExportDeadline gives the export another serverWriteTimeout from now to write
+// its next movies in.
+func extendExportDeadline(rc *http.ResponseController) error {
+	err := rc.SetWriteDeadline(time.Now().Add(serverWriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// exportFormatFromAccept picks the export format for an Accept header, preferring the
+// media ranges with the highest quality value. It returns "" if none of them can be
+// served. A missing header accepts anything, which means JSON.
+func exportFormatFromAccept(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return exportFormatJSON
+	}
+
+	type mediaRange struct {
+		format  string
+		quality float64
+	}
+	var ranges []mediaRange
+
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		var format string
+		switch mediaType {
+		case "text/csv":
+			format = importFormatCSV
+		case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+			format = importFormatNDJSON
+		case "application/json", "application/*", "*/*":
+			format = exportFormatJSON
+		}
+
+		if format != "" && quality > 0 {
+			ranges = append(ranges, mediaRange{format: format, quality: quality})
+		}
+	}
+
+	if len(ranges) == 0 {
+		return ""
+	}
+
+	// the first of the most preferred ranges wins
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return ranges[0].format
+}
+
+// movieExporter writes movies out in one of the export formats.
+type movieExporter interface {
+	begin() error
+	write(movie *data.Movie) error
+	flush() error
+	end() error
+}
+
+// csvMovieExporter writes the columns of a CSV import, preceded by the id and followed
+// by the other exportOnlyColumns, which an import skips.
+type csvMovieExporter struct {
+	w *csv.Writer
+}
+
+func newCSVMovieExporter(w io.Writer) *csvMovieExporter {
+	return &csvMovieExporter{w: csv.NewWriter(w)}
+}
+
+func (e *csvMovieExporter) begin() error {
+	return e.w.Write([]string{"id", "title", "year", "runtime", "genres", "version", "created_at", "average_rating", "rating_count"})
+}
+
+func (e *csvMovieExporter) write(movie *data.Movie) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(movie.ID, 10),
+		movie.Title,
+		strconv.Itoa(int(movie.Year)),
+		strconv.Itoa(int(movie.Runtime)),
+		strings.Join(movie.Genres, ","),
+		strconv.Itoa(int(movie.Version)),
+		movie.CreatedAt.Format(time.RFC3339),
+		strconv.FormatFloat(movie.AverageRating, 'f', -1, 64),
+		strconv.Itoa(int(movie.RatingCount)),
+	})
+}
+
+func (e *csvMovieExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvMovieExporter) end() error {
+	return e.flush()
+}
+
+// ndjsonMovieExporter writes one movie object per line.
+type ndjsonMovieExporter struct {
+	w io.Writer
+}
+
+func (e *ndjsonMovieExporter) begin() error { return nil }
+
+func (e *ndjsonMovieExporter) write(movie *data.Movie) error {
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(append(js, '\n'))
+	return err
+}
+
+func (e *ndjsonMovieExporter) flush() error { return nil }
+
+func (e *ndjsonMovieExporter) end() error { return nil }
+
+// jsonMovieExporter writes a single {"movies": [...]} document, like the envelope of
+// listMoviesHandler, one array element at a time.
+type jsonMovieExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonMovieExporter) begin() error {
+	_, err := io.WriteString(e.w, `{"movies":[`)
+	return err
+}
+
+func (e *jsonMovieExporter) write(movie *data.Movie) error {
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+
+	if e.count > 0 {
+		js = append([]byte{','}, js...)
+	}
+	e.count++
+
+	_, err = e.w.Write(js)
+	return err
+}
+
+func (e *jsonMovieExporter) flush() error { return nil }
+
+func (e *jsonMovieExporter) end() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestExportMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.MoviesExport)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "adventure"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Casablanca", "year": 1942, "runtime": "102 mins", "genres": []string{"drama", "romance"}})
+	trashedID := ts.createTestMovie(t, token, map[string]any{"title": "Jaws", "year": 1975, "runtime": "124 mins", "genres": []string{"thriller"}})
+	ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", trashedID), token, nil)
+
+	tests := []struct {
+		name            string
+		path            string
+		token           string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{name: "without permission", path: "/v1/movies/export", token: readerToken, wantStatus: http.StatusForbidden},
+		{name: "invalid format", path: "/v1/movies/export?format=xml", token: token, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid genres_match", path: "/v1/movies/export?genres_match=some", token: token, wantStatus: http.StatusUnprocessableEntity},
+		{name: "not acceptable", path: "/v1/movies/export", token: token, accept: "application/xml", wantStatus: http.StatusNotAcceptable},
+		{name: "default", path: "/v1/movies/export", token: token, wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "accept csv", path: "/v1/movies/export", token: token, accept: "text/csv", wantStatus: http.StatusOK, wantContentType: "text/csv; charset=utf-8"},
+		{name: "accept quality", path: "/v1/movies/export", token: token, accept: "application/json;q=0.5, application/x-ndjson", wantStatus: http.StatusOK, wantContentType: "application/x-ndjson"},
+		{name: "format overrides accept", path: "/v1/movies/export?format=csv", token: token, accept: "application/json", wantStatus: http.StatusOK, wantContentType: "text/csv; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.accept != "" {
+				header.Set("Accept", tt.accept)
+			}
+
+			res := ts.doWithHeader(t, http.MethodGet, tt.path, tt.token, header, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %s)", res.status, tt.wantStatus, res.raw)
+			}
+			if tt.wantContentType != "" && res.header.Get("Content-Type") != tt.wantContentType {
+				t.Errorf("got Content-Type %q; want %q", res.header.Get("Content-Type"), tt.wantContentType)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies/export?format=json", token, nil)
+
+		var titles []string
+		for _, movie := range res.body["movies"].([]any) {
+			titles = append(titles, movie.(map[string]any)["title"].(string))
+		}
+		if want := []string{"Moana", "Casablanca"}; fmt.Sprint(titles) != fmt.Sprint(want) {
+			t.Errorf("got titles %q; want %q", titles, want)
+		}
+		if !strings.HasPrefix(res.header.Get("Content-Disposition"), `attachment; filename="movies-`) {
+			t.Errorf("got Content-Disposition %q", res.header.Get("Content-Disposition"))
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies/export?format=ndjson&genres=drama", token, nil)
+
+		lines := strings.Split(strings.TrimSpace(string(res.raw)), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("got %d lines; want 1 (body %s)", len(lines), res.raw)
+		}
+
+		var movie map[string]any
+		if err := json.Unmarshal([]byte(lines[0]), &movie); err != nil {
+			t.Fatal(err)
+		}
+		if movie["title"] != "Casablanca" || movie["runtime"] != "102 mins" {
+			t.Errorf("got movie %v", movie)
+		}
+	})
+
+	t.Run("any genre", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies/export?format=json&genres=animation,romance&genres_match=any", token, nil)
+
+		if movies := res.body["movies"].([]any); len(movies) != 2 {
+			t.Errorf("got %d movies; want 2", len(movies))
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies/export?format=csv&title=moana", token, nil)
+
+		records, err := csv.NewReader(strings.NewReader(string(res.raw))).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("got %d records; want a header and 1 movie (body %s)", len(records), res.raw)
+		}
+		if got := strings.Join(records[0][:5], ","); got != "id,title,year,runtime,genres" {
+			t.Errorf("got header %q", got)
+		}
+		if got := records[1][1:5]; fmt.Sprint(got) != "[Moana 2016 107 animation,adventure]" {
+			t.Errorf("got record %q", got)
+		}
+	})
+}
+
+func TestExportMoviesReimport(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.MoviesExport)
+	ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "adventure"}})
+
+	other := newTestServer(t)
+	otherToken := other.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	for _, format := range []string{"csv", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies/export?format="+format, token, nil)
+
+			res = other.doWithHeader(t, http.MethodPost, "/v1/movies/import?allow_duplicate=true&format="+format, otherToken, nil, string(res.raw))
+			if res.status != http.StatusOK {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+			}
+			if report := res.body["report"].(map[string]any); report["imported"] != 1.0 {
+				t.Errorf("got report %v; want the movie imported", report)
+			}
+		})
+	}
+}
+
+// slowExportMovies pauses before handing out each movie of an export, like a database
+// reading through a large catalogue.
+type slowExportMovies struct {
+	data.MovieRepository
+	pause time.Duration
+}
+
+func (m slowExportMovies) Export(ctx context.Context, movieFilters data.MovieFilters, fn func(movie *data.Movie) error) error {
+	return m.MovieRepository.Export(ctx, movieFilters, func(movie *data.Movie) error {
+		time.Sleep(m.pause)
+		return fn(movie)
+	})
+}
+
+// failingExportMovies fails an export before handing out any movie, like a query that
+// the database rejects.
+type failingExportMovies struct {
+	data.MovieRepository
+}
+
+func (m failingExportMovies) Export(ctx context.Context, movieFilters data.MovieFilters, fn func(movie *data.Movie) error) error {
+	return errors.New("canceling statement due to statement timeout")
+}
+
+func TestExportMoviesQueryFailure(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesExport)
+	ts.app.models.Movies = failingExportMovies{MovieRepository: ts.app.models.Movies}
+
+	res := ts.do(t, http.MethodGet, "/v1/movies/export?format=csv", token, nil)
+	if res.status != http.StatusInternalServerError {
+		t.Fatalf("got status %d; want %d (body %s)", res.status, http.StatusInternalServerError, res.raw)
+	}
+	if res.header.Get("Content-Disposition") != "" {
+		t.Errorf("got Content-Disposition %q on an error response", res.header.Get("Content-Disposition"))
+	}
+	if res.body["error"] == nil {
+		t.Errorf("got body %s; want an error", res.raw)
+	}
+}
+
+func TestExportMoviesOutlastsWriteTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesExport)
+
+	var movies []*data.Movie
+	for i := range 400 {
+		movies = append(movies, &data.Movie{Title: fmt.Sprintf("Movie %d", i+1), Year: 2000, Runtime: 90, Genres: []string{"drama"}})
+	}
+	if err := ts.app.models.Movies.InsertBatch(context.Background(), movies, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// the export takes 400ms or more, while each batch of exportFlushEvery movies takes
+	// a quarter of that
+	ts.app.models.Movies = slowExportMovies{MovieRepository: ts.app.models.Movies, pause: time.Millisecond}
+	slow := ts.withTimeouts(t, time.Second, 300*time.Millisecond)
+
+	res := slow.doWithHeader(t, http.MethodGet, "/v1/movies/export?format=ndjson", token, nil, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+	}
+	if lines := strings.Count(string(res.raw), "\n"); lines != 400 {
+		t.Errorf("got %d movies; want 400", lines)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieExternalIDs(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "The Shawshank Redemption", "year": 1994, "runtime": "142 mins", "genres": []string{"drama"}})
+	otherMovieID := ts.createTestMovie(t, token, map[string]any{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}})
+	path := fmt.Sprintf("/v1/movies/%d/external-ids", movieID)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "set without permission", method: http.MethodPut, path: path + "/imdb", token: readerToken, body: map[string]any{"external_id": "tt0111161"}, wantStatus: http.StatusForbidden},
+		{name: "set unknown source", method: http.MethodPut, path: path + "/letterboxd", token: token, body: map[string]any{"external_id": "shawshank"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set invalid id", method: http.MethodPut, path: path + "/imdb", token: token, body: map[string]any{"external_id": "111161"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set for missing movie", method: http.MethodPut, path: "/v1/movies/999/external-ids/imdb", token: token, body: map[string]any{"external_id": "tt0111161"}, wantStatus: http.StatusNotFound},
+		{name: "set", method: http.MethodPut, path: path + "/imdb", token: token, body: map[string]any{"external_id": "tt0111161"}, wantStatus: http.StatusOK},
+		{name: "set again", method: http.MethodPut, path: path + "/imdb", token: token, body: map[string]any{"external_id": "tt0111161"}, wantStatus: http.StatusOK},
+		{name: "set another source", method: http.MethodPut, path: path + "/tmdb", token: token, body: map[string]any{"external_id": "278"}, wantStatus: http.StatusOK},
+		{name: "set id of another movie", method: http.MethodPut, path: fmt.Sprintf("/v1/movies/%d/external-ids/imdb", otherMovieID), token: token, body: map[string]any{"external_id": "tt0111161"}, wantStatus: http.StatusConflict},
+		{name: "list", method: http.MethodGet, path: path, token: readerToken, wantStatus: http.StatusOK},
+		{name: "lookup", method: http.MethodGet, path: "/v1/movies/lookup?source=imdb&external_id=tt0111161", token: readerToken, wantStatus: http.StatusOK},
+		{name: "lookup unknown id", method: http.MethodGet, path: "/v1/movies/lookup?source=imdb&external_id=tt0000001", token: readerToken, wantStatus: http.StatusNotFound},
+		{name: "lookup without source", method: http.MethodGet, path: "/v1/movies/lookup?external_id=tt0111161", token: readerToken, wantStatus: http.StatusUnprocessableEntity},
+		{name: "delete", method: http.MethodDelete, path: path + "/tmdb", token: token, wantStatus: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: path + "/tmdb", token: token, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=external_ids", movieID), readerToken, nil)
+	externalIDs := res.body["movie"].(map[string]any)["external_ids"].([]any)
+	if len(externalIDs) != 1 || externalIDs[0].(map[string]any)["external_id"] != "tt0111161" {
+		t.Errorf("got external ids %v; want only tt0111161", externalIDs)
+	}
+
+	// the movie can't be looked up once it is in the trash
+	ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", movieID), token, nil)
+	res = ts.do(t, http.MethodGet, "/v1/movies/lookup?source=imdb&external_id=tt0111161", readerToken, nil)
+	if res.status != http.StatusNotFound {
+		t.Errorf("got status %d looking up a trashed movie; want %d", res.status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+// listGenresHandler sends the whole genre taxonomy, ordered by slug. There are few
+// enough genres that the list isn't paginated.
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genres.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showGenreHandler(w http.ResponseWriter, r *http.Request) {
+	genre, err := app.models.Genres.Get(r.Context(), chi.URLParamFromCtx(r.Context(), "slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Parent  string   `json:"parent"`
+		Aliases []string `json:"aliases"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Slug:    input.Slug,
+		Name:    input.Name,
+		Parent:  input.Parent,
+		Aliases: input.Aliases,
+	}
+	if genre.Aliases == nil {
+		genre.Aliases = []string{}
+	}
+
+	existing, err := app.models.Genres.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateGenre(v, genre, existing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(r.Context(), genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/genres/%s", genre.Slug))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"genre": genre}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateGenreHandler changes the name, parent or aliases of a genre. The slug can't be
+// changed, since movies refer to the genre by it; an empty parent removes the parent.
+func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+	genre, err := app.models.Genres.Get(r.Context(), chi.URLParamFromCtx(r.Context(), "slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name    *string  `json:"name"`
+		Parent  *string  `json:"parent"`
+		Aliases []string `json:"aliases"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name == nil && input.Parent == nil && input.Aliases == nil {
+		app.unprocessableEntityResponse(w, r, "provide at least one field to update")
+		return
+	}
+
+	if input.Name != nil {
+		genre.Name = *input.Name
+	}
+	if input.Parent != nil {
+		genre.Parent = *input.Parent
+	}
+	if input.Aliases != nil {
+		genre.Aliases = input.Aliases
+	}
+
+	existing, err := app.models.Genres.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateGenre(v, genre, existing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(r.Context(), genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteGenreHandler removes a genre that no movie uses, trashed movies included.
+func (app *application) deleteGenreHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.Genres.Delete(r.Context(), chi.URLParamFromCtx(r.Context(), "slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrGenreInUse):
+			app.conflictResponse(w, r, "the genre is still used by some movies")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "genre successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
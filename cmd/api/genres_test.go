@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestGenres(t *testing.T) {
+	ts := newTestServer(t)
+	adminToken := ts.newActivatedUser(t, "admin@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.MoviesAdmin)
+	writerToken := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+
+	noir := map[string]any{"slug": "neo-noir", "name": "Neo-Noir", "parent": "crime", "aliases": []string{"noir"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "list", method: http.MethodGet, path: "/v1/genres", token: readerToken, wantStatus: http.StatusOK},
+		{name: "show", method: http.MethodGet, path: "/v1/genres/sci-fi", token: readerToken, wantStatus: http.StatusOK},
+		{name: "show missing genre", method: http.MethodGet, path: "/v1/genres/space-opera", token: readerToken, wantStatus: http.StatusNotFound},
+		{name: "create without permission", method: http.MethodPost, path: "/v1/genres", token: writerToken, body: noir, wantStatus: http.StatusForbidden},
+		{name: "create with invalid slug", method: http.MethodPost, path: "/v1/genres", token: adminToken, body: map[string]any{"slug": "Neo Noir", "name": "Neo-Noir"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create with taken alias", method: http.MethodPost, path: "/v1/genres", token: adminToken, body: map[string]any{"slug": "anime", "name": "Anime", "aliases": []string{"Cartoon"}}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create with missing parent", method: http.MethodPost, path: "/v1/genres", token: adminToken, body: map[string]any{"slug": "anime", "name": "Anime", "parent": "cartoons"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create", method: http.MethodPost, path: "/v1/genres", token: adminToken, body: noir, wantStatus: http.StatusCreated},
+		{name: "create again", method: http.MethodPost, path: "/v1/genres", token: adminToken, body: noir, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update", method: http.MethodPatch, path: "/v1/genres/neo-noir", token: adminToken, body: map[string]any{"aliases": []string{"noir", "neonoir"}}, wantStatus: http.StatusOK},
+		{name: "update without fields", method: http.MethodPatch, path: "/v1/genres/neo-noir", token: adminToken, body: map[string]any{}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update into a cycle", method: http.MethodPatch, path: "/v1/genres/crime", token: adminToken, body: map[string]any{"parent": "neo-noir"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update missing genre", method: http.MethodPatch, path: "/v1/genres/space-opera", token: adminToken, body: map[string]any{"name": "Space Opera"}, wantStatus: http.StatusNotFound},
+		{name: "delete genre in use", method: http.MethodDelete, path: "/v1/genres/animation", token: adminToken, wantStatus: http.StatusConflict},
+		{name: "delete parent", method: http.MethodDelete, path: "/v1/genres/crime", token: adminToken, wantStatus: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/v1/genres/crime", token: adminToken, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	// deleting crime left its subgenre without a parent
+	res := ts.do(t, http.MethodGet, "/v1/genres/neo-noir", readerToken, nil)
+	genre := res.body["genre"].(map[string]any)
+	if _, ok := genre["parent"]; ok || fmt.Sprint(genre["aliases"]) != "[noir neonoir]" || genre["version"] != float64(2) {
+		t.Errorf("got genre %v; want version 2 without a parent", genre)
+	}
+}
+
+func TestMovieGenres(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	tests := []struct {
+		name       string
+		genres     []string
+		wantStatus int
+		wantGenres []string
+	}{
+		{name: "slugs", genres: []string{"sci-fi", "drama"}, wantStatus: http.StatusCreated, wantGenres: []string{"sci-fi", "drama"}},
+		{name: "names and aliases", genres: []string{"Science Fiction", "rom_com", "ANIMATED"}, wantStatus: http.StatusCreated, wantGenres: []string{"sci-fi", "romantic-comedy", "animation"}},
+		{name: "unknown genre", genres: []string{"drama", "space opera"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "same genre twice", genres: []string{"Sci-Fi", "scifi"}, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/movies", token, map[string]any{"title": tt.name, "year": 1979, "runtime": "117 mins", "genres": tt.genres})
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if _, ok := res.validationErrors()["genres"]; !ok {
+					t.Errorf("got errors %v; want one for genres", res.validationErrors())
+				}
+				return
+			}
+
+			if got := res.body["movie"].(map[string]any)["genres"]; fmt.Sprint(got) != fmt.Sprint(tt.wantGenres) {
+				t.Errorf("got genres %v; want %v", got, tt.wantGenres)
+			}
+		})
+	}
+
+	// the filter takes names and aliases too
+	res := ts.do(t, http.MethodGet, "/v1/movies?genres="+url.QueryEscape("Romantic Comedy,Sci Fi"), token, nil)
+	if got := len(res.body["movies"].([]any)); got != 1 {
+		t.Errorf("got %d movies; want 1", got)
+	}
+}
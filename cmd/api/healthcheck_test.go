@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "healthcheck", method: http.MethodGet, path: "/v1/healthcheck", wantStatus: http.StatusOK},
+		{name: "unknown route", method: http.MethodGet, path: "/v1/nothing-here", wantStatus: http.StatusNotFound, wantError: "the requested resource could not be found"},
+		{name: "unsupported method", method: http.MethodDelete, path: "/v1/healthcheck", wantStatus: http.StatusMethodNotAllowed, wantError: "the DELETE method is not supported for this resource"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, "", nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d", res.status, tt.wantStatus)
+			}
+			if res.errorMessage() != tt.wantError {
+				t.Errorf("got error %q; want %q", res.errorMessage(), tt.wantError)
+			}
+		})
+	}
+
+	t.Run("healthcheck reports the environment", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/healthcheck", "", nil)
+		if res.body["status"] != "available" {
+			t.Errorf("got status %v; want %q", res.body["status"], "available")
+		}
+		info := res.body["system_info"].(map[string]any)
+		if info["environment"] != "testing" {
+			t.Errorf("got environment %v; want %q", info["environment"], "testing")
+		}
+	})
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kayconfig/green-light-api/internal/data"
 	"github.com/kayconfig/green-light-api/internal/validator"
 )
 
@@ -67,10 +71,17 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 }
 
 func (app *application) readIDParam(r *http.Request) (int64, error) {
-	idParam := chi.URLParamFromCtx(r.Context(), "id")
+	return app.readNamedIDParam(r, "id")
+}
+
+// The readNamedIDParam() helper reads a positive integer id from the URL parameter
+// with the given name, for routes like /v1/movies/{id}/credits/{credit_id} that carry
+// more than one id.
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
+	idParam := chi.URLParamFromCtx(r.Context(), name)
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
@@ -92,6 +103,34 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	return nil
 }
 
+// The pickFields() helper returns the JSON object for value with only the given keys,
+// for responses with a sparse fieldset. Keys that are left out of the JSON (because
+// they are omitzero, say) stay out. With no fields it returns value as is.
+func pickFields(value any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return value, nil
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]json.RawMessage
+	err = json.Unmarshal(js, &object)
+	if err != nil {
+		return nil, err
+	}
+
+	picked := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if v, ok := object[field]; ok {
+			picked[field] = v
+		}
+	}
+	return picked, nil
+}
+
 // the readstring() helper returns a string value from the query string, or the provided
 // default value if no matching key could not be found
 func (app *application) readString(queryString url.Values, key string, defaultValue string) string {
@@ -138,6 +177,141 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// The readIDs() helper reads a comma-separated list of ids from the query string. It
+// returns nil if no matching key could be found, and records an error message in the
+// provided Validator instance if any of the ids isn't an integer.
+func (app *application) readIDs(qs url.Values, key string, v *validator.Validator) []int64 {
+	var ids []int64
+	for _, s := range app.readCSV(qs, key, nil) {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			v.AddError(key, "must be a comma-separated list of integers")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// The readTime() helper reads a timestamp from the query string, either in RFC 3339
+// format or as a plain date, which means midnight UTC. It returns the zero time if no
+// matching key could be found, and records an error message in the provided Validator
+// instance if the value couldn't be parsed.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	val := qs.Get(key)
+
+	if val == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+	return time.Time{}
+}
+
+// The readCursor() helper decodes an opaque pagination cursor from the query string.
+// It returns nil if no matching key could be found, and records an error message in
+// the provided Validator instance if the cursor is malformed.
+func (app *application) readCursor(qs url.Values, key string, v *validator.Validator) *data.Cursor {
+	val := qs.Get(key)
+
+	if val == "" {
+		return nil
+	}
+
+	cursor, err := data.DecodeCursor(val)
+	if err != nil {
+		v.AddError(key, "invalid cursor")
+		return nil
+	}
+
+	return cursor
+}
+
+// The etagMatches() helper reports whether an If-Match or If-None-Match header value
+// (a comma-separated list of entity tags, or "*") matches etag. If-None-Match uses the
+// weak comparison, which ignores the W/ prefix, while If-Match requires strong tags.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// The acceptedLanguages() helper returns the language ranges of an Accept-Language
+// header, e.g. "fr-CA, fr;q=0.9, en;q=0.5", most preferred first. The wildcard and
+// ranges with a quality of 0 are left out, as are ranges with a malformed quality.
+func acceptedLanguages(header string) []string {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			quality, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if tag == "" || tag == "*" || quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, languageRange{tag, quality})
+	}
+
+	slices.SortStableFunc(ranges, func(a, b languageRange) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+
+	languages := make([]string, len(ranges))
+	for i, r := range ranges {
+		languages[i] = r.tag
+	}
+	return languages
+}
+
+// minUploadRate is the slowest a client may upload a large body, in bytes per second.
+const minUploadRate = 64 << 10
+
+// allowLargeBody pushes the read and write deadlines of the request back by the time a
+// body of maxBytes takes to upload at minUploadRate, since the server timeouts would
+// otherwise cut it short. Connections that don't support deadlines are left alone.
+func (app *application) allowLargeBody(w http.ResponseWriter, maxBytes int64) error {
+	uploadTime := time.Duration(maxBytes/minUploadRate+1) * time.Second
+	rc := http.NewResponseController(w)
+
+	err := rc.SetReadDeadline(time.Now().Add(serverReadTimeout + uploadTime))
+	if err == nil {
+		err = rc.SetWriteDeadline(time.Now().Add(serverWriteTimeout + uploadTime))
+	}
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 	// Launch a background goroutine
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/imaging"
+	"github.com/kayconfig/green-light-api/internal/storage"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+// imageExtensions are the extensions the original images are stored with, by content
+// type. Thumbnails are always JPEGs.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (app *application) listMovieImagesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	images, err := app.models.Images.GetAllForMovie(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.setImageURLs(images...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"images": images}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	image, ok := app.readMovieImage(w, r)
+	if !ok {
+		return
+	}
+	app.setImageURLs(image)
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"image": image}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadMovieImageHandler takes a poster or backdrop for a movie as a
+// multipart/form-data body with two fields: kind, and image holding the file. The
+// format of the file is sniffed from its content, whatever the client says it is. The
+// original is stored along with JPEG thumbnails in the widths for its kind.
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// a large image takes longer to upload than the server's read timeout allows
+	err = app.allowLargeBody(w, app.config.images.maxBytes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	kind, content, err := app.readImageUpload(w, r)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.Is(err, http.ErrNotMultipart):
+			app.unsupportedMediaTypeResponse(w, r, "body must be multipart/form-data")
+		case errors.As(err, &maxBytesError):
+			app.contentTooLargeResponse(w, r, fmt.Sprintf("image must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	movieImage := &data.MovieImage{
+		MovieID:     id,
+		Kind:        kind,
+		ContentType: http.DetectContentType(content),
+		Size:        int64(len(content)),
+	}
+
+	v := validator.New()
+	if len(content) == 0 {
+		v.AddError("image", "must be provided")
+	} else if !validator.PermittedValue(movieImage.ContentType, data.ImageContentTypes...) {
+		app.unsupportedMediaTypeResponse(w, r, "image must be a JPEG, PNG or GIF")
+		return
+	}
+
+	// decoding the header first keeps a huge image from being decoded at all
+	if len(content) > 0 {
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			v.AddError("image", "could not be decoded")
+		}
+		movieImage.Width, movieImage.Height = config.Width, config.Height
+	}
+
+	if data.ValidateMovieImage(v, movieImage); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"image": "could not be decoded"})
+		return
+	}
+
+	err = app.storeImage(r.Context(), movieImage, content, img)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Images.Insert(r.Context(), movieImage)
+	if err != nil {
+		app.deleteImageFiles(movieImage)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.setImageURLs(movieImage)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/images/%d", id, movieImage.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": movieImage}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	image, ok := app.readMovieImage(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Images.Delete(r.Context(), image.MovieID, image.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteImageFiles(image)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readMovieImage looks up the image named by the {id} and {image_id} URL parameters.
+// The images of a movie in the trash are hidden along with it. If it returns false, an
+// error response has already been sent.
+func (app *application) readMovieImage(w http.ResponseWriter, r *http.Request) (*data.MovieImage, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	imageID, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	var image *data.MovieImage
+	_, err = app.models.Movies.Get(r.Context(), id)
+	if err == nil {
+		image, err = app.models.Images.Get(r.Context(), id, imageID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return image, true
+}
+
+// serveImageHandler serves the files of the local storage. It is only routed when
+// images are kept on the local filesystem; an S3 bucket serves its own. Only the files
+// of images whose movie is out of the trash are served, and caches have to check back
+// every time (cheaply, with If-Modified-Since), so that moving a movie to the trash
+// takes its images down at once.
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	local, ok := app.storage.(*storage.Local)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	fsys := os.DirFS(local.Dir())
+	key := chi.URLParamFromCtx(r.Context(), "*")
+
+	// fs.Stat rejects keys that aren't valid paths, such as ../secret
+	info, err := fs.Stat(fsys, key)
+	if err != nil || !info.Mode().IsRegular() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Images.GetByStorageKey(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, no-cache")
+	http.ServeFileFS(w, r, fsys, key)
+}
+
+// The readImageUpload() helper reads the kind and image fields of a multipart upload.
+// An image over the configured size is reported as an *http.MaxBytesError with that
+// size as its limit.
+func (app *application) readImageUpload(w http.ResponseWriter, r *http.Request) (string, []byte, error) {
+	maxBytes := app.config.images.maxBytes
+
+	// leave room for the kind field and the multipart boundaries
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+64<<10)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var kind string
+	var content []byte
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return "", nil, &http.MaxBytesError{Limit: maxBytes}
+			}
+			return "", nil, err
+		}
+
+		switch part.FormName() {
+		case "kind":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				return "", nil, err
+			}
+			kind = strings.TrimSpace(string(value))
+		case "image":
+			content, err = io.ReadAll(io.LimitReader(part, maxBytes+1))
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError) || int64(len(content)) > maxBytes:
+				return "", nil, &http.MaxBytesError{Limit: maxBytes}
+			case err != nil:
+				return "", nil, err
+			}
+		default:
+			return "", nil, fmt.Errorf("body contains unknown field %q", part.FormName())
+		}
+	}
+
+	return kind, content, nil
+}
+
+// The storeImage() helper puts the original image and its thumbnails in the storage,
+// under a fresh random key, and records their keys and sizes in movieImage. If any of
+// them can't be stored, the ones that were are deleted again.
+func (app *application) storeImage(ctx context.Context, movieImage *data.MovieImage, content []byte, img image.Image) error {
+	base := "images/" + strings.ToLower(rand.Text())
+	movieImage.StorageKey = base + imageExtensions[movieImage.ContentType]
+
+	err := app.storage.Put(ctx, movieImage.StorageKey, bytes.NewReader(content), int64(len(content)), movieImage.ContentType)
+	if err != nil {
+		return err
+	}
+
+	for _, width := range data.ThumbnailWidths[movieImage.Kind] {
+		if width >= movieImage.Width {
+			continue
+		}
+
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, imaging.Thumbnail(img, width), &jpeg.Options{Quality: 85})
+		if err == nil {
+			thumbnail := &data.ImageThumbnail{
+				Width:      width,
+				Height:     imaging.ScaledHeight(movieImage.Width, movieImage.Height, width),
+				StorageKey: fmt.Sprintf("%s_w%d.jpg", base, width),
+			}
+			err = app.storage.Put(ctx, thumbnail.StorageKey, &buf, int64(buf.Len()), "image/jpeg")
+			movieImage.Thumbnails = append(movieImage.Thumbnails, thumbnail)
+		}
+		if err != nil {
+			app.deleteImageFiles(movieImage)
+			return err
+		}
+	}
+
+	if movieImage.Thumbnails == nil {
+		movieImage.Thumbnails = []*data.ImageThumbnail{}
+	}
+	return nil
+}
+
+// The deleteImageFiles() helper deletes the original and the thumbnails of an image
+// from the storage. Failures are only logged: the image is gone from the database by
+// then, and a stray file does no harm.
+func (app *application) deleteImageFiles(movieImage *data.MovieImage) {
+	keys := []string{movieImage.StorageKey}
+	for _, thumbnail := range movieImage.Thumbnails {
+		keys = append(keys, thumbnail.StorageKey)
+	}
+
+	for _, key := range keys {
+		// the request may have been cancelled by now
+		err := app.storage.Delete(context.Background(), key)
+		if err != nil {
+			app.logger.Error("deleting image file", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// The setImageURLs() helper fills in the URLs of the images and their thumbnails,
+// which depend on where the storage is served from.
+func (app *application) setImageURLs(images ...*data.MovieImage) {
+	for _, image := range images {
+		image.URL = app.storage.URL(image.StorageKey)
+		for _, thumbnail := range image.Thumbnails {
+			thumbnail.URL = app.storage.URL(thumbnail.StorageKey)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/storage"
+)
+
+// testImage returns a width x height gradient encoded as a PNG, or as a JPEG if asked.
+func testImage(t *testing.T, width, height int, asJPEG bool) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// imageUpload returns a multipart/form-data body with the given fields, and the header
+// to send it with. A nil content leaves the image field out.
+func imageUpload(t *testing.T, kind string, content []byte) (string, http.Header) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if kind != "" {
+		writer.WriteField("kind", kind)
+	}
+	if content != nil {
+		part, err := writer.CreateFormFile("image", "upload.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	writer.Close()
+
+	header := make(http.Header)
+	header.Set("Content-Type", writer.FormDataContentType())
+	return buf.String(), header
+}
+
+// fetch downloads the file at url, relative to the test server unless it is absolute.
+func (ts *testServer) fetch(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	if strings.HasPrefix(url, "/") {
+		url = ts.URL + url
+	}
+
+	res, err := ts.Client().Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	return res.StatusCode, res.Header.Get("Content-Type")
+}
+
+func TestMovieImages(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Blade Runner", "year": 1982, "runtime": "117 mins", "genres": []string{"sci-fi"}})
+	path := fmt.Sprintf("/v1/movies/%d/images", movieID)
+
+	poster := testImage(t, 800, 1200, false)
+	backdrop := testImage(t, 400, 225, true)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		kind       string
+		content    []byte
+		rawBody    string
+		wantStatus int
+	}{
+		{name: "upload without permission", path: path, token: readerToken, kind: "poster", content: poster, wantStatus: http.StatusForbidden},
+		{name: "upload for missing movie", path: "/v1/movies/999/images", token: token, kind: "poster", content: poster, wantStatus: http.StatusNotFound},
+		{name: "upload JSON", path: path, token: token, rawBody: `{"kind": "poster"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "upload without image", path: path, token: token, kind: "poster", wantStatus: http.StatusUnprocessableEntity},
+		{name: "upload invalid kind", path: path, token: token, kind: "banner", content: poster, wantStatus: http.StatusUnprocessableEntity},
+		{name: "upload text", path: path, token: token, kind: "poster", content: []byte("not an image at all"), wantStatus: http.StatusUnsupportedMediaType},
+		{name: "upload truncated image", path: path, token: token, kind: "poster", content: poster[:64], wantStatus: http.StatusUnprocessableEntity},
+		{name: "upload too large", path: path, token: token, kind: "poster", content: append(bytes.Clone(poster), make([]byte, 1<<20)...), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "upload poster", path: path, token: token, kind: "poster", content: poster, wantStatus: http.StatusCreated},
+		{name: "upload backdrop", path: path, token: token, kind: "backdrop", content: backdrop, wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, header := imageUpload(t, tt.kind, tt.content)
+			if tt.rawBody != "" {
+				body = tt.rawBody
+				header.Set("Content-Type", "application/json")
+			}
+
+			res := ts.doWithHeader(t, http.MethodPost, tt.path, tt.token, header, body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, path, readerToken, nil)
+	images := res.body["images"].([]any)
+	if len(images) != 2 {
+		t.Fatalf("got images %v; want the poster and the backdrop", images)
+	}
+
+	// the poster gets every thumbnail, the backdrop only those narrower than it
+	first, second := images[0].(map[string]any), images[1].(map[string]any)
+	if first["kind"] != "poster" || first["content_type"] != "image/png" || first["width"] != 800.0 || first["height"] != 1200.0 {
+		t.Errorf("got first image %v; want the 800x1200 PNG poster", first)
+	}
+	if thumbnails := first["thumbnails"].([]any); len(thumbnails) != 3 || thumbnails[0].(map[string]any)["height"] != 231.0 {
+		t.Errorf("got poster thumbnails %v; want 3, the first 154x231", thumbnails)
+	}
+	if thumbnails := second["thumbnails"].([]any); second["kind"] != "backdrop" || len(thumbnails) != 1 {
+		t.Errorf("got backdrop %v; want 1 thumbnail", second)
+	}
+
+	// the files are served from the local storage
+	status, contentType := ts.fetch(t, first["url"].(string))
+	if status != http.StatusOK || contentType != "image/png" {
+		t.Errorf("fetching the poster: got %d %s; want 200 image/png", status, contentType)
+	}
+	if res, err := ts.Client().Get(ts.URL + first["url"].(string)); err != nil {
+		t.Fatal(err)
+	} else {
+		res.Body.Close()
+		if got := res.Header.Get("Cache-Control"); got != "public, no-cache" {
+			t.Errorf("fetching the poster: got Cache-Control %q; want %q", got, "public, no-cache")
+		}
+	}
+	thumbnailURL := first["thumbnails"].([]any)[0].(map[string]any)["url"].(string)
+	status, contentType = ts.fetch(t, thumbnailURL)
+	if status != http.StatusOK || contentType != "image/jpeg" {
+		t.Errorf("fetching a thumbnail: got %d %s; want 200 image/jpeg", status, contentType)
+	}
+	if status, _ := ts.fetch(t, "/v1/images/../go.mod"); status != http.StatusNotFound {
+		t.Errorf("fetching outside of the storage: got %d; want 404", status)
+	}
+
+	// and embedded in the movie on request
+	res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=images", movieID), readerToken, nil)
+	if embedded, _ := res.body["movie"].(map[string]any)["images"].([]any); len(embedded) != 2 {
+		t.Errorf("got embedded images %v; want 2", embedded)
+	}
+
+	imagePath := fmt.Sprintf("%s/%v", path, first["id"])
+	if res := ts.do(t, http.MethodGet, imagePath, readerToken, nil); res.status != http.StatusOK {
+		t.Errorf("showing the poster: got status %d", res.status)
+	}
+	if res := ts.do(t, http.MethodDelete, imagePath, readerToken, nil); res.status != http.StatusForbidden {
+		t.Errorf("deleting without permission: got status %d", res.status)
+	}
+	if res := ts.do(t, http.MethodDelete, imagePath, token, nil); res.status != http.StatusOK {
+		t.Errorf("deleting the poster: got status %d", res.status)
+	}
+	if res := ts.do(t, http.MethodDelete, imagePath, token, nil); res.status != http.StatusNotFound {
+		t.Errorf("deleting the poster again: got status %d", res.status)
+	}
+	if status, _ := ts.fetch(t, first["url"].(string)); status != http.StatusNotFound {
+		t.Errorf("fetching a deleted poster: got %d; want 404", status)
+	}
+	if status, _ := ts.fetch(t, thumbnailURL); status != http.StatusNotFound {
+		t.Errorf("fetching the thumbnail of a deleted poster: got %d; want 404", status)
+	}
+
+	// the images of a movie in the trash are hidden along with it
+	if res := ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", movieID), token, nil); res.status != http.StatusOK {
+		t.Fatalf("deleting the movie: got status %d", res.status)
+	}
+	backdropPath := fmt.Sprintf("%s/%v", path, second["id"])
+	if res := ts.do(t, http.MethodGet, backdropPath, readerToken, nil); res.status != http.StatusNotFound {
+		t.Errorf("showing the backdrop of a deleted movie: got status %d; want 404", res.status)
+	}
+	if res := ts.do(t, http.MethodDelete, backdropPath, token, nil); res.status != http.StatusNotFound {
+		t.Errorf("deleting the backdrop of a deleted movie: got status %d; want 404", res.status)
+	}
+	backdropThumbnailURL := second["thumbnails"].([]any)[0].(map[string]any)["url"].(string)
+	for _, url := range []string{second["url"].(string), backdropThumbnailURL} {
+		if status, _ := ts.fetch(t, url); status != http.StatusNotFound {
+			t.Errorf("fetching %s of a deleted movie: got %d; want 404", url, status)
+		}
+	}
+
+	// and come back with it
+	ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/restore", movieID), token, nil)
+	if status, _ := ts.fetch(t, backdropThumbnailURL); status != http.StatusOK {
+		t.Errorf("fetching the backdrop thumbnail of a restored movie: got %d; want 200", status)
+	}
+	ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", movieID), token, nil)
+
+	// purging the movie from the trash deletes the files of its images too
+	purged, err := ts.app.purgeDeleted(context.Background(), time.Now().Add(time.Minute))
+	if err != nil || purged != 1 {
+		t.Fatalf("purging: got %d, %v; want 1 movie", purged, err)
+	}
+	if status, _ := ts.fetch(t, second["url"].(string)); status != http.StatusNotFound {
+		t.Errorf("fetching the backdrop of a purged movie: got %d; want 404", status)
+	}
+}
+
+func TestMovieImagesSlowUpload(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	slow := ts.withTimeouts(t, 100*time.Millisecond, 200*time.Millisecond)
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Blade Runner", "year": 1982, "runtime": "117 mins", "genres": []string{"sci-fi"}})
+
+	// the upload takes longer to arrive than both server timeouts
+	upload, header := imageUpload(t, "poster", testImage(t, 40, 60, false))
+	body := &slowReader{data: []byte(upload), chunk: len(upload)/5 + 1, pause: 60 * time.Millisecond}
+
+	res := slow.doWithHeader(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/images", movieID), token, header, body)
+	if res.status != http.StatusCreated {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusCreated, res.body)
+	}
+}
+
+// s3StandIn is a minimal S3-compatible object store that keeps objects in memory. It
+// checks that requests carry a Signature Version 4 authorization for its access key.
+type s3StandIn struct {
+	mu      sync.Mutex
+	objects map[string]string // path -> content type
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-access-key/") ||
+		!strings.Contains(auth, "/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") ||
+		r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "<Error><Code>IncompleteBody</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		s.objects[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "<Error><Code>MethodNotAllowed</Code></Error>", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestMovieImagesS3(t *testing.T) {
+	standIn := &s3StandIn{objects: make(map[string]string)}
+	s3Server := httptest.NewServer(standIn)
+	t.Cleanup(s3Server.Close)
+
+	ts := newTestServer(t)
+	store, err := storage.NewS3(s3Server.URL, "eu-west-1", "posters", "test-access-key", "test-secret-key", "https://cdn.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.app.storage = store
+
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"horror"}})
+
+	body, header := imageUpload(t, "backdrop", testImage(t, 1000, 500, true))
+	res := ts.doWithHeader(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/images", movieID), token, header, body)
+	if res.status != http.StatusCreated {
+		t.Fatalf("uploading: got status %d (body %v)", res.status, res.body)
+	}
+
+	image := res.body["image"].(map[string]any)
+	url := image["url"].(string)
+	if !strings.HasPrefix(url, "https://cdn.example.com/images/") || !strings.HasSuffix(url, ".jpg") {
+		t.Errorf("got url %q; want one on the CDN", url)
+	}
+
+	standIn.mu.Lock()
+	if len(standIn.objects) != 3 {
+		t.Errorf("got objects %v; want the original and 2 thumbnails", standIn.objects)
+	}
+	key := strings.TrimPrefix(url, "https://cdn.example.com/")
+	if contentType := standIn.objects["/posters/"+key]; contentType != "image/jpeg" {
+		t.Errorf("got content type %q for the original; want image/jpeg", contentType)
+	}
+	standIn.mu.Unlock()
+
+	res = ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d/images/%v", movieID, image["id"]), token, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("deleting: got status %d (body %v)", res.status, res.body)
+	}
+
+	standIn.mu.Lock()
+	defer standIn.mu.Unlock()
+	if len(standIn.objects) != 0 {
+		t.Errorf("got objects %v after deleting; want none", standIn.objects)
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+const (
+	importFormatCSV    = "csv"
+	importFormatNDJSON = "ndjson"
+)
+
+// importColumns are the columns of a CSV import. The header row names them, in any
+// order, and genres holds a comma-separated list (quoted, like "drama,romance").
+var importColumns = []string{"title", "year", "runtime", "genres"}
+
+// exportOnlyColumns are the fields an export writes besides importColumns. They belong
+// to the movie in the database it was exported from, so an import skips them, which
+// lets an export be imported as it is.
+var exportOnlyColumns = []string{"id", "version", "created_at", "average_rating", "rating_count"}
+
+// importRow is a single movie read from an import, along with anything wrong with it.
+type importRow struct {
+	row   int
+	movie *data.Movie
+	v     *validator.Validator
+}
+
+type importRowResult struct {
+	Row         int               `json:"row"`
+	Status      string            `json:"status"` // "imported" or "failed"
+	MovieID     int64             `json:"movie_id,omitzero"`
+	DuplicateOf int64             `json:"duplicate_of,omitzero"` // the existing movie a duplicate row matches
+	Errors      map[string]string `json:"errors,omitempty"`
+}
+
+type importReport struct {
+	Total    int                `json:"total"`
+	Imported int                `json:"imported"`
+	Failed   int                `json:"failed"`
+	Rows     []*importRowResult `json:"rows"`
+}
+
+// importMoviesHandler adds movies in bulk from a CSV or NDJSON body, picked by the
+// Content-Type header or the format query string parameter. Rows that fail validation
+// or duplicate a movie (unless allow_duplicate=true) are skipped and reported, while the
+// rest are imported, so the response is 200 OK unless the body as a whole can't be read.
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	format := app.readString(r.URL.Query(), "format", "")
+	if format == "" {
+		format = importFormatFromContentType(r.Header.Get("Content-Type"))
+	}
+	if !validator.PermittedValue(format, importFormatCSV, importFormatNDJSON) {
+		app.unsupportedMediaTypeResponse(w, r, "the body must be text/csv or application/x-ndjson")
+		return
+	}
+
+	allowDuplicate := app.readString(r.URL.Query(), "allow_duplicate", "false")
+
+	v := validator.New()
+	if v.Check(validator.PermittedValue(allowDuplicate, "true", "false"), "allow_duplicate", "must be true or false"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.allowLargeBody(w, app.config.movies.importMaxBytes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.movies.importMaxBytes)
+
+	genres, err := app.models.Genres.GetIndex(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	rows, err := readMovieImport(r.Body, format, genres)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	report, err := app.importMovies(r.Context(), rows, app.contextGetUser(r).ID, allowDuplicate == "true")
+	if err != nil {
+		// the batches before the failed one are saved, so the client gets the report
+		// along with the error
+		app.logError(r, err)
+		message := "the server encountered a problem and could not finish the import"
+		err = app.writeJSON(w, http.StatusInternalServerError, envelope{"error": message, "report": report}, nil)
+		if err != nil {
+			app.logError(r, err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importMoviesFromFile is the command-line equivalent of importMoviesHandler, run with
+// the -import flag. The format defaults to the file extension, and the report is
+// written to stdout.
+func (app *application) importMoviesFromFile(path, format string, allowDuplicate bool) error {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+		if format == "jsonl" {
+			format = importFormatNDJSON
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	genres, err := app.models.Genres.GetIndex(context.Background())
+	if err != nil {
+		return err
+	}
+
+	rows, err := readMovieImport(file, format, genres)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	report, importErr := app.importMovies(context.Background(), rows, 0, allowDuplicate)
+	if importErr == nil {
+		app.logger.Info("import finished", "file", path, "imported", report.Imported, "failed", report.Failed)
+	}
+
+	// the report says what was saved even when a batch failed
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(envelope{"report": report})
+	return errors.Join(importErr, err)
+}
+
+// importMovies inserts the valid rows in batches of movies.importBatchSize, each batch
+// in its own transaction along with an insert revision for every movie, made on behalf
+// of userID (zero when run from the command line). Unless allowDuplicate is set, rows
+// with the same title and year as an existing movie, or as an earlier row, fail like
+// POST /v1/movies would refuse them. If a batch fails, the batches before it stay
+// imported and the import stops there: the error is returned along with a report that
+// has the rows of the failed batch, and those after it, as failed.
+func (app *application) importMovies(ctx context.Context, rows []importRow, userID int64, allowDuplicate bool) (*importReport, error) {
+	report := &importReport{Total: len(rows), Rows: []*importRowResult{}}
+
+	var movies []*data.Movie
+	var results []*importRowResult
+
+	// fail marks the rows of the current batch as failed, along with the error that
+	// stopped the import
+	fail := func(err error) error {
+		for _, result := range results {
+			result.Status = "failed"
+			result.Errors = map[string]string{"row": "could not be saved"}
+		}
+		report.Failed += len(results)
+		return err
+	}
+
+	flush := func() error {
+		if len(movies) == 0 {
+			return nil
+		}
+
+		err := app.models.Movies.InsertBatch(ctx, movies, userID)
+		if err != nil {
+			return fail(err)
+		}
+
+		for i, movie := range movies {
+			results[i].Status = "imported"
+			results[i].MovieID = movie.ID
+		}
+		report.Imported += len(movies)
+
+		movies, results = movies[:0], results[:0]
+		return nil
+	}
+
+	// the first row of each title and year, to catch duplicates that aren't saved yet
+	seen := make(map[string]int)
+
+	var err error
+	for _, row := range rows {
+		result := &importRowResult{Row: row.row}
+		report.Rows = append(report.Rows, result)
+
+		switch {
+		case !row.v.Valid():
+			result.Status = "failed"
+			result.Errors = row.v.Errors
+			report.Failed++
+			continue
+		case err != nil:
+			result.Status = "failed"
+			result.Errors = map[string]string{"row": "was not imported because an earlier batch failed"}
+			report.Failed++
+			continue
+		}
+
+		if !allowDuplicate {
+			key := data.DuplicateKey(row.movie)
+			if first, ok := seen[key]; ok {
+				result.Status = "failed"
+				result.Errors = map[string]string{"title": fmt.Sprintf("has the same title and year as row %d", first)}
+				report.Failed++
+				continue
+			}
+			seen[key] = row.row
+
+			duplicate, findErr := app.models.Movies.FindDuplicate(ctx, row.movie)
+			switch {
+			case findErr == nil:
+				result.Status = "failed"
+				result.DuplicateOf = duplicate.ID
+				result.Errors = map[string]string{"title": "a movie with the same title and year already exists"}
+				report.Failed++
+				continue
+			case !errors.Is(findErr, data.ErrRecordNotFound):
+				err = fail(findErr)
+				result.Status = "failed"
+				result.Errors = map[string]string{"row": "could not be saved"}
+				report.Failed++
+				continue
+			}
+		}
+
+		movies = append(movies, row.movie)
+		results = append(results, result)
+
+		if len(movies) >= app.config.movies.importBatchSize {
+			err = flush()
+		}
+	}
+
+	if err == nil {
+		err = flush()
+	}
+
+	return report, err
+}
+
+func importFormatFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return importFormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return importFormatNDJSON
+	default:
+		return ""
+	}
+}
+
+// readMovieImport reads every row of an import, normalizes its genres and runs it
+// through data.ValidateMovie. Problems with a single row, like a year that isn't a
+// number, are recorded against that row; only a body that can't be read at all returns
+// an error.
+func readMovieImport(src io.Reader, format string, genres data.GenreIndex) ([]importRow, error) {
+	switch format {
+	case importFormatCSV:
+		return readMovieCSV(src, genres)
+	case importFormatNDJSON:
+		return readMovieNDJSON(src, genres)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+func readMovieCSV(src io.Reader, genres data.GenreIndex) ([]importRow, error) {
+	reader := csv.NewReader(src)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if slices.Contains(exportOnlyColumns, name) {
+			continue
+		}
+		if !slices.Contains(importColumns, name) {
+			return nil, fmt.Errorf("header contains unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header must contain the %q column", name)
+		}
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		row := importRow{row: len(rows) + 1, movie: &data.Movie{}, v: validator.New()}
+		if err != nil {
+			// a row with the wrong number of fields is returned along with the error
+			if !errors.Is(err, csv.ErrFieldCount) {
+				return nil, err
+			}
+			row.v.AddError("row", fmt.Sprintf("must have %d fields", len(header)))
+			rows = append(rows, row)
+			continue
+		}
+
+		row.movie.Title = strings.TrimSpace(record[columns["title"]])
+
+		// empty cells are left as zero values, for data.ValidateMovie to report
+		if year := strings.TrimSpace(record[columns["year"]]); year != "" {
+			n, err := strconv.ParseInt(year, 10, 32)
+			if err != nil {
+				row.v.AddError("year", "must be an integer")
+			}
+			row.movie.Year = int32(n)
+		}
+
+		// the runtime may be given as "102" or, like in JSON, as "102 mins"
+		if runtime := strings.TrimSpace(record[columns["runtime"]]); runtime != "" {
+			n, err := strconv.ParseInt(strings.TrimSuffix(runtime, " mins"), 10, 32)
+			if err != nil {
+				row.v.AddError("runtime", data.ErrInvalidRuntimeFormat.Error())
+			}
+			row.movie.Runtime = data.Runtime(n)
+		}
+
+		if cell := strings.TrimSpace(record[columns["genres"]]); cell != "" {
+			for genre := range strings.SplitSeq(cell, ",") {
+				row.movie.Genres = append(row.movie.Genres, strings.TrimSpace(genre))
+			}
+		}
+
+		row.movie.Genres = genres.Normalize(row.movie.Genres)
+		data.ValidateMovie(row.v, row.movie, genres)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readMovieNDJSON(src io.Reader, genres data.GenreIndex) ([]importRow, error) {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1_048_576)
+
+	rows := []importRow{}
+	// rows are numbered by line, counting the blank ones that are skipped, so that a
+	// row can be found in the file by its number
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var input struct {
+			Title   string       `json:"title"`
+			Year    int32        `json:"year"`
+			Runtime data.Runtime `json:"runtime"`
+			Genres  []string     `json:"genres"`
+
+			// the exportOnlyColumns, which are skipped
+			ID            json.RawMessage `json:"id"`
+			Version       json.RawMessage `json:"version"`
+			CreatedAt     json.RawMessage `json:"created_at"`
+			AverageRating json.RawMessage `json:"average_rating"`
+			RatingCount   json.RawMessage `json:"rating_count"`
+		}
+
+		row := importRow{row: lineNumber, v: validator.New()}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+
+		err := decoder.Decode(&input)
+		if err != nil {
+			var syntaxError *json.SyntaxError
+			var unmarshalTypeError *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+				row.v.AddError("row", "contains badly-formed JSON")
+			case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+				row.v.AddError(unmarshalTypeError.Field, "has the wrong JSON type")
+			case errors.Is(err, data.ErrInvalidRuntimeFormat):
+				row.v.AddError("runtime", err.Error())
+			case strings.HasPrefix(err.Error(), "json: unknown field "):
+				row.v.AddError("row", "contains unknown key "+strings.TrimPrefix(err.Error(), "json: unknown field "))
+			default:
+				row.v.AddError("row", "must be a JSON object")
+			}
+		}
+
+		row.movie = &data.Movie{
+			Title:   input.Title,
+			Year:    input.Year,
+			Runtime: input.Runtime,
+			Genres:  genres.Normalize(input.Genres),
+		}
+
+		// there is no point validating the fields of a row that couldn't be decoded
+		if _, malformed := row.v.Errors["row"]; !malformed {
+			data.ValidateMovie(row.v, row.movie, genres)
+		}
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("row %d must not be larger than %d bytes", len(rows)+1, 1_048_576)
+		}
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestImportMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	csvBody := strings.Join([]string{
+		"title,year,runtime,genres",
+		`Moana,2016,107 mins,"animation,adventure"`,
+		`Casablanca,1942,102,"drama,romance"`,
+		`,2020,90,comedy`,
+		`Dune,not a year,155,sci-fi`,
+		`Heat,1995,170`,
+		`Alien,1979,117,"horror,sci-fi"`,
+	}, "\n")
+
+	ndjsonBody := strings.Join([]string{
+		`{"title": "Up", "year": 2009, "runtime": "96 mins", "genres": ["animation"]}`,
+		``,
+		`{"title": "Jaws", "year": "1975", "runtime": "124 mins", "genres": ["thriller"]}`,
+		`{"title": "Rocky", "year": 1976, "runtime": 120, "genres": ["drama"]}`,
+		`{"title": "Psycho", "year": 1960, "runtime": "109 mins", "genres": ["horror"], "rating": 5}`,
+		`{"title": "Vertigo"`,
+	}, "\n")
+
+	csvHeader := http.Header{"Content-Type": {"text/csv; charset=utf-8"}}
+	ndjsonHeader := http.Header{"Content-Type": {"application/x-ndjson"}}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		header     http.Header
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "without permission", path: "/v1/movies/import", token: readerToken, header: csvHeader, body: csvBody, wantStatus: http.StatusForbidden},
+		{name: "unsupported content type", path: "/v1/movies/import", token: token, header: http.Header{"Content-Type": {"application/json"}}, body: "[]", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "unknown format", path: "/v1/movies/import?format=xml", token: token, header: csvHeader, body: csvBody, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "empty body", path: "/v1/movies/import", token: token, header: csvHeader, body: "", wantStatus: http.StatusBadRequest, wantError: "body must not be empty"},
+		{name: "missing column", path: "/v1/movies/import", token: token, header: csvHeader, body: "title,year,runtime\nUp,2009,96", wantStatus: http.StatusBadRequest, wantError: `header must contain the "genres" column`},
+		{name: "unknown column", path: "/v1/movies/import", token: token, header: csvHeader, body: "title,year,runtime,genres,rating\n", wantStatus: http.StatusBadRequest, wantError: `header contains unknown column "rating"`},
+		{name: "too large", path: "/v1/movies/import", token: token, header: csvHeader, body: "title,year,runtime,genres\n" + strings.Repeat("Up,2009,96,animation\n", 60_000), wantStatus: http.StatusBadRequest, wantError: "body must not be larger than 1048576 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.doWithHeader(t, http.MethodPost, tt.path, tt.token, tt.header, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantError != "" && res.errorMessage() != tt.wantError {
+				t.Errorf("got error %q; want %q", res.errorMessage(), tt.wantError)
+			}
+		})
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		res := ts.doWithHeader(t, http.MethodPost, "/v1/movies/import", token, csvHeader, csvBody)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+
+		report := res.body["report"].(map[string]any)
+		if report["total"] != 6.0 || report["imported"] != 3.0 || report["failed"] != 3.0 {
+			t.Errorf("got report totals %v", report)
+		}
+
+		wantErrors := map[int]map[string]any{
+			3: {"title": "must be provided"},
+			4: {"year": "must be an integer"},
+			5: {"row": "must have 4 fields"},
+		}
+		for _, row := range report["rows"].([]any) {
+			row := row.(map[string]any)
+			n := int(row["row"].(float64))
+
+			if want, ok := wantErrors[n]; ok {
+				if row["status"] != "failed" || fmt.Sprint(row["errors"]) != fmt.Sprint(want) {
+					t.Errorf("got row %v; want it to fail with %v", row, want)
+				}
+				continue
+			}
+			if row["status"] != "imported" || row["movie_id"] == nil {
+				t.Errorf("got row %v; want it imported", row)
+			}
+		}
+
+		res = ts.do(t, http.MethodGet, "/v1/movies?sort=title", token, nil)
+		var titles []string
+		for _, movie := range res.body["movies"].([]any) {
+			titles = append(titles, movie.(map[string]any)["title"].(string))
+		}
+		if want := []string{"Alien", "Casablanca", "Moana"}; fmt.Sprint(titles) != fmt.Sprint(want) {
+			t.Errorf("got titles %q; want %q", titles, want)
+		}
+
+		movieID := int64(report["rows"].([]any)[1].(map[string]any)["movie_id"].(float64))
+		res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieID), token, nil)
+		movie := res.body["movie"].(map[string]any)
+		if movie["title"] != "Casablanca" || movie["runtime"] != "102 mins" || fmt.Sprint(movie["genres"]) != "[drama romance]" {
+			t.Errorf("got movie %v", movie)
+		}
+
+		res = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d/revisions", movieID), token, nil)
+		revisions := res.body["revisions"].([]any)
+		if len(revisions) != 1 || revisions[0].(map[string]any)["action"] != "insert" {
+			t.Errorf("got revisions %v; want a single insert", revisions)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		res := ts.doWithHeader(t, http.MethodPost, "/v1/movies/import", token, ndjsonHeader, ndjsonBody)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+
+		report := res.body["report"].(map[string]any)
+		if report["total"] != 5.0 || report["imported"] != 1.0 || report["failed"] != 4.0 {
+			t.Errorf("got report totals %v", report)
+		}
+
+		// rows are numbered by line, so the blank second line leaves a gap
+		wantErrors := map[int]any{
+			1: nil,
+			3: map[string]any{"year": "has the wrong JSON type"},
+			4: map[string]any{"runtime": "invalid runtime format"},
+			5: map[string]any{"row": "contains unknown key \"rating\""},
+			6: map[string]any{"row": "contains badly-formed JSON"},
+		}
+		for _, row := range report["rows"].([]any) {
+			row := row.(map[string]any)
+			want, ok := wantErrors[int(row["row"].(float64))]
+			if !ok || fmt.Sprint(row["errors"]) != fmt.Sprint(want) {
+				t.Errorf("got row %v; want errors %v", row, want)
+			}
+		}
+	})
+
+	t.Run("format parameter", func(t *testing.T) {
+		body := `{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": ["crime"]}`
+		res := ts.doWithHeader(t, http.MethodPost, "/v1/movies/import?format=ndjson", token, http.Header{"Content-Type": {"text/plain"}}, body)
+		if res.status != http.StatusOK || res.body["report"].(map[string]any)["imported"] != 1.0 {
+			t.Errorf("got status %d, body %v", res.status, res.body)
+		}
+	})
+}
+
+// failingBatchMovies lets the first succeed calls to InsertBatch through and fails the
+// rest, like a database connection that goes away halfway through an import.
+type failingBatchMovies struct {
+	data.MovieRepository
+	succeed int
+}
+
+func (m *failingBatchMovies) InsertBatch(ctx context.Context, movies []*data.Movie, userID int64) error {
+	if m.succeed == 0 {
+		return errors.New("connection reset by peer")
+	}
+	m.succeed--
+	return m.MovieRepository.InsertBatch(ctx, movies, userID)
+}
+
+func TestImportMoviesBatchFailure(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	ts.app.models.Movies = &failingBatchMovies{MovieRepository: ts.app.models.Movies, succeed: 1}
+
+	// batches of 2: rows 1 and 2 are saved, rows 3 and 5 fail with their batch and row 6
+	// is never tried
+	body := strings.Join([]string{
+		"title,year,runtime,genres",
+		"Up,2009,96,animation",
+		"Heat,1995,170,crime",
+		"Jaws,1975,124,thriller",
+		",1976,120,drama",
+		"Rocky,1976,120,drama",
+		"Alien,1979,117,horror",
+	}, "\n")
+
+	res := ts.doWithHeader(t, http.MethodPost, "/v1/movies/import", token, http.Header{"Content-Type": {"text/csv"}}, body)
+	if res.status != http.StatusInternalServerError {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusInternalServerError, res.body)
+	}
+
+	report := res.body["report"].(map[string]any)
+	if report["total"] != 6.0 || report["imported"] != 2.0 || report["failed"] != 4.0 {
+		t.Errorf("got report totals %v", report)
+	}
+
+	wantErrors := []any{
+		nil,
+		nil,
+		map[string]any{"row": "could not be saved"},
+		map[string]any{"title": "must be provided"},
+		map[string]any{"row": "could not be saved"},
+		map[string]any{"row": "was not imported because an earlier batch failed"},
+	}
+	for i, row := range report["rows"].([]any) {
+		row := row.(map[string]any)
+		if fmt.Sprint(row["errors"]) != fmt.Sprint(wantErrors[i]) {
+			t.Errorf("got row %v; want errors %v", row, wantErrors[i])
+		}
+	}
+
+	res = ts.do(t, http.MethodGet, "/v1/movies?sort=title", token, nil)
+	if got := movieTitles(res); fmt.Sprint(got) != "[Heat Up]" {
+		t.Errorf("got movies %v; want the first batch, Heat and Up", got)
+	}
+}
+
+func TestImportMoviesSlowUpload(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	slow := ts.withTimeouts(t, 100*time.Millisecond, 200*time.Millisecond)
+
+	// the body takes longer to arrive than both server timeouts
+	body := &slowReader{
+		data:  []byte("title,year,runtime,genres\nUp,2009,96,animation\nHeat,1995,170,crime\n"),
+		chunk: 16,
+		pause: 60 * time.Millisecond,
+	}
+
+	res := slow.doWithHeader(t, http.MethodPost, "/v1/movies/import", token, http.Header{"Content-Type": {"text/csv"}}, body)
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+	}
+	if imported := res.body["report"].(map[string]any)["imported"]; imported != 2.0 {
+		t.Errorf("got %v movies imported; want 2", imported)
+	}
+}
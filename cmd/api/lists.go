@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	list := &data.List{
+		UserID:      app.contextGetUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Public:      input.Public,
+	}
+
+	v := validator.New()
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Insert(r.Context(), list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateListName):
+			v.AddError("name", "you already have a list with this name")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d", list.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"list": list}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r, false)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r, true)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Public      *bool   `json:"public"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name == nil && input.Description == nil && input.Public == nil {
+		app.unprocessableEntityResponse(w, r, "provide at least one field to update")
+		return
+	}
+
+	if input.Name != nil {
+		list.Name = *input.Name
+	}
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+	if input.Public != nil {
+		list.Public = *input.Public
+	}
+
+	v := validator.New()
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Update(r.Context(), list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateListName):
+			v.AddError("name", "you already have a list with this name")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.Delete(r.Context(), list.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listUserListsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "name")
+	input.SortSafeList = []string{"name", "created_at", "updated_at", "-name", "-created_at", "-updated_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	lists, metadata, err := app.models.Lists.GetAllForUser(r.Context(), app.contextGetUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listListMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "position")
+	input.SortSafeList = []string{"position", "added_at", "title", "year", "-position", "-added_at", "-title", "-year"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	list, ok := app.readList(w, r, false)
+	if !ok {
+		return
+	}
+
+	items, metadata, err := app.models.Lists.GetMovies(r.Context(), list.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addListMovieHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r, true)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MovieID  int64 `json:"movie_id"`
+		Position int   `json:"position"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.MovieID > 0, "movie_id", "must be provided")
+	v.Check(input.Position >= 0, "position", "must not be negative")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.AddMovie(r.Context(), list.ID, input.MovieID, input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateListMovie):
+			v.AddError("movie_id", "is already in the list")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusCreated, list.ID)
+}
+
+func (app *application) moveListMovieHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r, true)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Position int `json:"position"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Position >= 1, "position", "must be provided and greater than zero")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.MoveMovie(r.Context(), list.ID, movieID, input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusOK, list.ID)
+}
+
+func (app *application) removeListMovieHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r, true)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.RemoveMovie(r.Context(), list.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusOK, list.ID)
+}
+
+// readList looks up the list named by the {id} URL parameter on behalf of the current
+// user. Private lists of other users are reported as not found, so that their existence
+// is not leaked, and only the owner may modify a list. If it returns false, an error
+// response has already been sent.
+func (app *application) readList(w http.ResponseWriter, r *http.Request, modify bool) (*data.List, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	list, err := app.models.Lists.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if list.UserID != app.contextGetUser(r).ID {
+		switch {
+		case !list.Public:
+			app.notFoundResponse(w, r)
+			return nil, false
+		case modify:
+			app.notPermittedResponse(w, r)
+			return nil, false
+		}
+	}
+
+	return list, true
+}
+
+// writeListResponse sends the current state of a list after its movies have changed.
+func (app *application) writeListResponse(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	list, err := app.models.Lists.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, status, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
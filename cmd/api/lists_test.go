@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+// createTestList creates a list through the API and returns its id.
+func (ts *testServer) createTestList(t *testing.T, token string, list map[string]any) int64 {
+	t.Helper()
+
+	res := ts.do(t, http.MethodPost, "/v1/lists", token, list)
+	if res.status != http.StatusCreated {
+		t.Fatalf("creating list %v: got status %d, body %v", list["name"], res.status, res.body)
+	}
+
+	return int64(res.body["list"].(map[string]any)["id"].(float64))
+}
+
+// listMovieTitles returns the titles of the movies in a list, in list order.
+func (ts *testServer) listMovieTitles(t *testing.T, token string, listID int64) []string {
+	t.Helper()
+
+	res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/lists/%d/movies", listID), token, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("listing movies of list %d: got status %d, body %v", listID, res.status, res.body)
+	}
+
+	var titles []string
+	for i, item := range res.body["movies"].([]any) {
+		item := item.(map[string]any)
+		if item["position"] != float64(i+1) {
+			t.Errorf("got position %v at index %d", item["position"], i)
+		}
+		titles = append(titles, item["movie"].(map[string]any)["title"].(string))
+	}
+	return titles
+}
+
+func TestLists(t *testing.T) {
+	ts := newTestServer(t)
+	aliceToken := ts.newActivatedUser(t, "alice@example.com", data.PermissionsCode.MoviesWrite)
+	bobToken := ts.newActivatedUser(t, "bob@example.com")
+
+	watchlist := ts.createTestList(t, aliceToken, map[string]any{"name": "Watchlist"})
+	favourites := ts.createTestList(t, aliceToken, map[string]any{"name": "Favourites", "public": true})
+	watchlistPath := fmt.Sprintf("/v1/lists/%d", watchlist)
+	favouritesPath := fmt.Sprintf("/v1/lists/%d", favourites)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "create without name", method: http.MethodPost, path: "/v1/lists", token: aliceToken, body: map[string]any{"public": true}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create with duplicate name", method: http.MethodPost, path: "/v1/lists", token: aliceToken, body: map[string]any{"name": "Watchlist"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "same name for another user", method: http.MethodPost, path: "/v1/lists", token: bobToken, body: map[string]any{"name": "Watchlist"}, wantStatus: http.StatusCreated},
+		{name: "show own private list", method: http.MethodGet, path: watchlistPath, token: aliceToken, wantStatus: http.StatusOK},
+		{name: "show private list of another user", method: http.MethodGet, path: watchlistPath, token: bobToken, wantStatus: http.StatusNotFound},
+		{name: "show public list of another user", method: http.MethodGet, path: favouritesPath, token: bobToken, wantStatus: http.StatusOK},
+		{name: "update public list of another user", method: http.MethodPatch, path: favouritesPath, token: bobToken, body: map[string]any{"name": "Mine"}, wantStatus: http.StatusForbidden},
+		{name: "update without fields", method: http.MethodPatch, path: favouritesPath, token: aliceToken, body: map[string]any{}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update", method: http.MethodPatch, path: favouritesPath, token: aliceToken, body: map[string]any{"description": "All-time greats."}, wantStatus: http.StatusOK},
+		{name: "delete private list of another user", method: http.MethodDelete, path: watchlistPath, token: bobToken, wantStatus: http.StatusNotFound},
+		{name: "missing list", method: http.MethodGet, path: "/v1/lists/999", token: aliceToken, wantStatus: http.StatusNotFound},
+		{name: "anonymous", method: http.MethodGet, path: favouritesPath, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	t.Run("my lists", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/users/me/lists", aliceToken, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+
+		var got []string
+		for _, list := range res.body["lists"].([]any) {
+			got = append(got, list.(map[string]any)["name"].(string))
+		}
+		if want := []string{"Favourites", "Watchlist"}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got lists %q; want %q", got, want)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if res := ts.do(t, http.MethodDelete, favouritesPath, aliceToken, nil); res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+		if res := ts.do(t, http.MethodGet, favouritesPath, aliceToken, nil); res.status != http.StatusNotFound {
+			t.Errorf("got status %d after delete; want %d", res.status, http.StatusNotFound)
+		}
+	})
+}
+
+func TestListEntries(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "alice@example.com", data.PermissionsCode.MoviesWrite)
+	otherToken := ts.newActivatedUser(t, "bob@example.com")
+
+	var movies []int64
+	for _, title := range []string{"Alien", "Brazil", "Casablanca", "Dune"} {
+		movies = append(movies, ts.createTestMovie(t, token, map[string]any{"title": title, "year": 1980, "runtime": "120 mins", "genres": []string{"drama"}}))
+	}
+
+	listID := ts.createTestList(t, token, map[string]any{"name": "Watchlist", "public": true})
+	moviesPath := fmt.Sprintf("/v1/lists/%d/movies", listID)
+
+	tests := []struct {
+		name       string
+		body       any
+		token      string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "append", body: map[string]any{"movie_id": movies[0]}, token: token, wantStatus: http.StatusCreated, wantTitles: []string{"Alien"}},
+		{name: "append again", body: map[string]any{"movie_id": movies[1]}, token: token, wantStatus: http.StatusCreated, wantTitles: []string{"Alien", "Brazil"}},
+		{name: "insert at the top", body: map[string]any{"movie_id": movies[2], "position": 1}, token: token, wantStatus: http.StatusCreated, wantTitles: []string{"Casablanca", "Alien", "Brazil"}},
+		{name: "position past the end", body: map[string]any{"movie_id": movies[3], "position": 10}, token: token, wantStatus: http.StatusCreated, wantTitles: []string{"Casablanca", "Alien", "Brazil", "Dune"}},
+		{name: "duplicate", body: map[string]any{"movie_id": movies[0]}, token: token, wantStatus: http.StatusUnprocessableEntity},
+		{name: "missing movie", body: map[string]any{"movie_id": 999}, token: token, wantStatus: http.StatusUnprocessableEntity},
+		{name: "negative position", body: map[string]any{"movie_id": movies[0], "position": -1}, token: token, wantStatus: http.StatusUnprocessableEntity},
+		{name: "not the owner", body: map[string]any{"movie_id": movies[0]}, token: otherToken, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, moviesPath, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantTitles != nil {
+				if got := ts.listMovieTitles(t, token, listID); fmt.Sprint(got) != fmt.Sprint(tt.wantTitles) {
+					t.Errorf("got titles %q; want %q", got, tt.wantTitles)
+				}
+			}
+		})
+	}
+
+	t.Run("reorder", func(t *testing.T) {
+		path := fmt.Sprintf("%s/%d", moviesPath, movies[2])
+		res := ts.do(t, http.MethodPatch, path, token, map[string]any{"position": 3})
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+
+		want := []string{"Alien", "Brazil", "Casablanca", "Dune"}
+		if got := ts.listMovieTitles(t, token, listID); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got titles %q; want %q", got, want)
+		}
+
+		if res := ts.do(t, http.MethodPatch, path, token, map[string]any{"position": 0}); res.status != http.StatusUnprocessableEntity {
+			t.Errorf("position 0: got status %d; want %d", res.status, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		path := fmt.Sprintf("%s/%d", moviesPath, movies[1])
+		res := ts.do(t, http.MethodDelete, path, token, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+		if got := res.body["list"].(map[string]any)["movie_count"]; got != 3.0 {
+			t.Errorf("got movie_count %v; want 3", got)
+		}
+
+		if res := ts.do(t, http.MethodDelete, path, token, nil); res.status != http.StatusNotFound {
+			t.Errorf("removing again: got status %d; want %d", res.status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("sort by title", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, moviesPath+"?sort=-title", otherToken, nil)
+		first := res.body["movies"].([]any)[0].(map[string]any)
+		if first["movie"].(map[string]any)["title"] != "Dune" || first["position"] != 3.0 {
+			t.Errorf("got first item %v; want Dune at position 3", first)
+		}
+	})
+
+	t.Run("deleted movies are hidden from lists", func(t *testing.T) {
+		ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", movies[0]), token, nil)
+
+		want := []string{"Casablanca", "Dune"}
+		if got := ts.listMovieTitles(t, token, listID); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got titles %q; want %q", got, want)
+		}
+	})
+}
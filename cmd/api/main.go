@@ -17,6 +17,7 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/kayconfig/green-light-api/internal/data"
 	"github.com/kayconfig/green-light-api/internal/mailer"
+	"github.com/kayconfig/green-light-api/internal/storage"
 	"github.com/kayconfig/green-light-api/internal/vcs"
 	"github.com/kayconfig/green-light-api/migrations"
 	_ "github.com/lib/pq"
@@ -34,6 +35,7 @@ type config struct {
 		maxOpenConns int
 		maxIdleConns int
 		maxIdleTime  time.Duration
+		queryTimeout time.Duration
 	}
 	limiter struct {
 		rps     float64
@@ -50,14 +52,47 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	movies struct {
+		requireIfMatch     bool
+		trashRetention     time.Duration
+		trashPurgeInterval time.Duration
+		importMaxBytes     int64
+		importBatchSize    int
+	}
+	images struct {
+		maxBytes int64
+	}
+	storage struct {
+		backend string
+		dir     string
+		baseURL string
+		s3      struct {
+			endpoint  string
+			region    string
+			bucket    string
+			accessKey string
+			secretKey string
+		}
+	}
+	stats struct {
+		cacheTTL time.Duration
+	}
+}
+
+// mailSender is the part of *mailer.Mailer used by the handlers. Tests swap in an
+// implementation that records messages instead of talking to an SMTP server.
+type mailSender interface {
+	Send(recipient string, templateFile string, data any) error
 }
 
 type application struct {
-	config *config
-	logger *slog.Logger
-	models data.Models
-	mailer *mailer.Mailer
-	wg     sync.WaitGroup
+	config  *config
+	logger  *slog.Logger
+	models  data.Models
+	mailer  mailSender
+	storage storage.Storage
+	stats   statsCache
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -83,6 +118,7 @@ func main() {
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 15*time.Minute, "PostgreSQL max  connection idle time")
+	flag.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", 3*time.Second, "PostgreSQL per-query timeout")
 
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
@@ -105,6 +141,30 @@ func main() {
 		return nil
 	})
 
+	flag.BoolVar(&cfg.movies.requireIfMatch, "movies-require-if-match", false, "Reject movie updates and deletes without an If-Match header")
+	flag.DurationVar(&cfg.movies.trashRetention, "movies-trash-retention", 30*24*time.Hour, "How long deleted movies are kept in the trash (0 keeps them forever)")
+	flag.DurationVar(&cfg.movies.trashPurgeInterval, "movies-trash-purge-interval", time.Hour, "How often the trash is purged of expired movies")
+
+	flag.Int64Var(&cfg.movies.importMaxBytes, "movies-import-max-bytes", 10<<20, "Maximum size of a bulk movie import request body")
+	flag.IntVar(&cfg.movies.importBatchSize, "movies-import-batch-size", 500, "Number of movies inserted per transaction by a bulk import")
+
+	flag.Int64Var(&cfg.images.maxBytes, "images-max-bytes", 10<<20, "Maximum size of an uploaded movie image")
+
+	flag.StringVar(&cfg.storage.backend, "storage", "local", "Where uploaded images are kept (local|s3)")
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory uploaded images are kept in by the local storage")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "", "URL uploaded images are downloaded from (defaults to /v1/images for the local storage and the bucket for S3)")
+	flag.StringVar(&cfg.storage.s3.endpoint, "s3-endpoint", os.Getenv("S3_ENDPOINT"), "S3-compatible object store endpoint")
+	flag.StringVar(&cfg.storage.s3.region, "s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.storage.s3.bucket, "s3-bucket", os.Getenv("S3_BUCKET"), "S3 bucket uploaded images are kept in")
+	flag.StringVar(&cfg.storage.s3.accessKey, "s3-access-key", os.Getenv("S3_ACCESS_KEY"), "S3 access key id")
+	flag.StringVar(&cfg.storage.s3.secretKey, "s3-secret-key", os.Getenv("S3_SECRET_KEY"), "S3 secret access key")
+
+	flag.DurationVar(&cfg.stats.cacheTTL, "stats-cache-ttl", 5*time.Minute, "How long computed movie stats are served from the cache (0 disables the cache)")
+
+	importFile := flag.String("import", "", "Import movies from a CSV or NDJSON file and exit")
+	importFormat := flag.String("import-format", "", "Format of the -import file (csv|ndjson), defaults to its extension")
+	importAllowDuplicates := flag.Bool("import-allow-duplicates", false, "Import rows of the -import file that duplicate an existing movie")
+
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
@@ -114,6 +174,12 @@ func main() {
 		os.Exit(0)
 	}
 
+	// every query runs with this timeout, so anything but a positive one would make
+	// them all fail straight away
+	if cfg.db.queryTimeout <= 0 {
+		logErrAndExit(fmt.Errorf("invalid -db-query-timeout %s: must be greater than zero", cfg.db.queryTimeout))
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logErrAndExit(err)
@@ -145,11 +211,17 @@ func main() {
 		logErrAndExit(err)
 	}
 
+	store, err := openStorage(cfg)
+	if err != nil {
+		logErrAndExit(err)
+	}
+
 	app := &application{
-		config: &cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer,
+		config:  &cfg,
+		logger:  logger,
+		models:  data.NewModels(db, cfg.db.queryTimeout),
+		mailer:  mailer,
+		storage: store,
 	}
 
 	// run migration, if env=development
@@ -160,6 +232,14 @@ func main() {
 		}
 	}
 
+	if *importFile != "" {
+		err = app.importMoviesFromFile(*importFile, *importFormat, *importAllowDuplicates)
+		if err != nil {
+			logErrAndExit(err)
+		}
+		return
+	}
+
 	err = app.serve()
 	if err != nil {
 		logErrAndExit(err)
@@ -201,3 +281,20 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// openStorage returns the storage configured for uploaded images.
+func openStorage(cfg config) (storage.Storage, error) {
+	switch cfg.storage.backend {
+	case "local":
+		baseURL := cfg.storage.baseURL
+		if baseURL == "" {
+			baseURL = "/v1/images"
+		}
+		return storage.NewLocal(cfg.storage.dir, baseURL)
+	case "s3":
+		s3 := cfg.storage.s3
+		return storage.NewS3(s3.endpoint, s3.region, s3.bucket, s3.accessKey, s3.secretKey, cfg.storage.baseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.storage.backend)
+	}
+}
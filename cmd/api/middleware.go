@@ -132,7 +132,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		user, err := app.models.Users.GetForToken(r.Context(), data.ScopeAuthentication, token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -178,7 +178,7 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
 
-		userPermissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		userPermissions, err := app.models.Permissions.GetAllForUser(r.Context(), user.ID)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
@@ -209,7 +209,7 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 					// determine if the request is pre-flight
 					if r.Method == "OPTIONS" && w.Header().Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Acess-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 						w.WriteHeader(http.StatusOK)
 						return
 					}
@@ -256,14 +256,17 @@ func (m *metricsResponseWriter) Unwrap() http.ResponseWriter {
 	return m.wrapped
 }
 
-func (app *application) metrics(next http.Handler) http.Handler {
-	var (
-		totalRequestReceived            = expvar.NewInt("total_requests_received")
-		totalResponsesSent              = expvar.NewInt("total_responses_sent")
-		totalProcessingTimeMilliseconds = expvar.NewInt("total_processing_time_μs")
-		totalResponseSentByStatus       = expvar.NewMap("total_repsonses_sent_by_status")
-	)
+// expvar panics when a variable is published twice, so the metrics are declared at
+// package level rather than inside metrics(). That way routes() can safely be called
+// more than once, e.g. by the tests.
+var (
+	totalRequestReceived            = expvar.NewInt("total_requests_received")
+	totalResponsesSent              = expvar.NewInt("total_responses_sent")
+	totalProcessingTimeMilliseconds = expvar.NewInt("total_processing_time_μs")
+	totalResponseSentByStatus       = expvar.NewMap("total_repsonses_sent_by_status")
+)
 
+func (app *application) metrics(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
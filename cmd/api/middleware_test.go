@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestAuthorization(t *testing.T) {
+	ts := newTestServer(t)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+	ts.registerUser(t, "inactive@example.com", "pa55word1234")
+	inactiveToken := ts.authenticate(t, "inactive@example.com", "pa55word1234")
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		wantStatus int
+	}{
+		{name: "anonymous user", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", method: http.MethodGet, header: "Basic " + readerToken, wantStatus: http.StatusUnauthorized},
+		{name: "malformed header", method: http.MethodGet, header: "Bearer", wantStatus: http.StatusUnauthorized},
+		{name: "malformed token", method: http.MethodGet, header: "Bearer abc", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, header: "Bearer ABCDEFGHIJKLMNOPQRSTUVWXYZ", wantStatus: http.StatusUnauthorized},
+		{name: "inactive user", method: http.MethodGet, header: "Bearer " + inactiveToken, wantStatus: http.StatusForbidden},
+		{name: "missing permission", method: http.MethodPost, header: "Bearer " + readerToken, wantStatus: http.StatusForbidden},
+		{name: "permitted", method: http.MethodGet, header: "Bearer " + readerToken, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+"/v1/movies", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d; want %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	t.Run("invalid token asks for a bearer token", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", nil)
+		if got := res.header.Get("WWW-Authenticate"); got != "Bearer" {
+			t.Errorf("got WWW-Authenticate %q; want %q", got, "Bearer")
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.config.limiter.enabled = true
+	ts.app.config.limiter.rps = 1
+	ts.app.config.limiter.burst = 2
+
+	for i, wantStatus := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		res := ts.do(t, http.MethodGet, "/v1/healthcheck", "", nil)
+		if res.status != wantStatus {
+			t.Fatalf("request %d: got status %d; want %d", i+1, res.status, wantStatus)
+		}
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	handler := app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d; want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("Connection"); got != "close" {
+		t.Errorf("got Connection %q; want %q", got, "close")
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	ts := newTestServer(t)
+	writerToken := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	res := ts.do(t, http.MethodPost, "/v1/movies", writerToken, map[string]any{
+		"title":   "Moana",
+		"year":    2016,
+		"runtime": "107 mins",
+		"genres":  []string{"animation", "adventure"},
+	})
+	if res.status != http.StatusCreated {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusCreated, res.body)
+	}
+}
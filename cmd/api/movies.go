@@ -1,11 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 
 	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/jsonpatch"
 	"github.com/kayconfig/green-light-api/internal/validator"
 )
 
@@ -31,11 +39,34 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	// a movie with the same title and year is most likely the same film created twice,
+	// unless the client says otherwise
+	allowDuplicate := app.readString(r.URL.Query(), "allow_duplicate", "false")
+	v.Check(validator.PermittedValue(allowDuplicate, "true", "false"), "allow_duplicate", "must be true or false")
+
+	err = app.validateMovie(r.Context(), v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.Movies.Insert(movie)
+
+	if allowDuplicate == "false" {
+		duplicate, err := app.models.Movies.FindDuplicate(r.Context(), movie)
+		switch {
+		case err == nil:
+			app.duplicateMovieResponse(w, r, duplicate)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Movies.Insert(r.Context(), movie, app.newMovieRevision(r, data.RevisionInsert, nil, movie))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -47,6 +78,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	// ID for our new movie in the URL
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("ETag", movieETag(movie))
 	err = app.writeJSON(w, http.StatusCreated, envelope{
 		"movie": movie,
 	}, headers)
@@ -61,7 +93,22 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	// related resources the client asked to have embedded in the movie, and the only
+	// fields it wants
+	v := validator.New()
+	qs := r.URL.Query()
+	include := app.readCSV(qs, "include", []string{})
+	for _, relation := range include {
+		v.Check(validator.PermittedValue(relation, movieIncludes...), "include", "invalid include value")
+	}
+	fields := app.readCSV(qs, "fields", []string{})
+	data.ValidateFields(v, fields, data.MovieFields)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.GetFields(r.Context(), id, fields)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -71,21 +118,77 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	headers := make(http.Header)
+
+	localized, err := app.localizeTitles(w, r, []*data.Movie{movie})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// embedded relations and alternate titles change without bumping the movie's
+	// version, so the ETag only describes the plain, full movie representation
+	if len(include) == 0 && len(fields) == 0 && !localized {
+		// if the client already has this version of the movie, there is no need to
+		// send it again
+		etag := movieETag(movie)
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		headers.Set("ETag", etag)
+	}
+
+	err = app.includeMovieRelations(r.Context(), []*data.Movie{movie}, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	output, err := pickMovieFields(movie, fields, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Encode the struct to JSON and send it as the HTTP response.
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": output}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 
 }
 
+// The content types updateMovieHandler accepts, besides plain application/json which
+// is treated as a merge patch.
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// movieDocument is the JSON document a movie patch is applied to: the fields a client
+// can change, along with the version so that a JSON Patch can test it.
+type movieDocument struct {
+	Title   string       `json:"title"`
+	Year    int32        `json:"year"`
+	Runtime data.Runtime `json:"runtime"`
+	Genres  []string     `json:"genres"`
+	Version int32        `json:"version"`
+}
+
+// updateMovieHandler applies a JSON Merge Patch (RFC 7396) or, with a Content-Type of
+// application/json-patch+json, a JSON Patch (RFC 6902) to a movie. A merge patch sets
+// the fields it names and removes those set to null, while a JSON Patch can also add
+// or remove single genres and test values before changing them. Either way the
+// outcome is validated as a whole, so removing a required field fails validation.
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -95,72 +198,272 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+
+	if !app.checkIfMatch(w, r, movie) {
+		return
 	}
-	err = app.readJSON(w, r, &input)
+
+	// keep the current values for the revision history
+	before := *movie
+
+	doc, err := toJSONValue(movieDocument{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  movie.Genres,
+		Version: movie.Version,
+	})
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	shouldUpdate := false
+	mediaType := "application/json"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+
+	switch mediaType {
+	case "application/json", mergePatchContentType:
+		var patch map[string]any
+		err = app.readJSON(w, r, &patch)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if len(patch) == 0 {
+			app.unprocessableEntityResponse(w, r, "provide at least one field to update")
+			return
+		}
+
+		doc = jsonpatch.MergePatch(doc, patch)
+
+	case jsonPatchContentType:
+		var ops []jsonpatch.Operation
+		err = app.readJSON(w, r, &ops)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if len(ops) == 0 {
+			app.unprocessableEntityResponse(w, r, "provide at least one operation")
+			return
+		}
+
+		doc, err = jsonpatch.Apply(doc, ops)
+		if err != nil {
+			switch {
+			case errors.Is(err, jsonpatch.ErrTestFailed):
+				app.conflictResponse(w, r, err.Error())
+			default:
+				app.unprocessableEntityResponse(w, r, err.Error())
+			}
+			return
+		}
+
+	default:
+		w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		app.unsupportedMediaTypeResponse(w, r, fmt.Sprintf("the patch must be sent as %s or %s", mergePatchContentType, jsonPatchContentType))
+		return
+	}
 
-	if input.Title != nil {
-		movie.Title = *input.Title
-		shouldUpdate = true
+	v := validator.New()
+	patched := decodeMovieDocument(doc, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
-	if input.Year != nil {
-		movie.Year = *input.Year
-		shouldUpdate = true
+
+	// a patch that changes the version was made against another version of the movie
+	if patched.Version != movie.Version {
+		app.movieConflictResponse(w, r)
+		return
 	}
 
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
-		shouldUpdate = true
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.Runtime = patched.Runtime
+	movie.Genres = patched.Genres
+
+	app.saveMovieUpdate(w, r, &before, movie)
+}
+
+// replaceMovieHandler replaces every field of a movie a client can change, so unlike a
+// patch the body must hold them all, as when the movie was created.
+func (app *application) replaceMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	if input.Genres != nil {
-		movie.Genres = input.Genres
-		shouldUpdate = true
+	if !app.checkIfMatch(w, r, movie) {
+		return
 	}
 
-	if !shouldUpdate {
-		app.unprocessableEntityResponse(w, r,
-			"provide at least one field to update")
+	before := *movie
+
+	var input struct {
+		Title   string       `json:"title"`
+		Year    int32        `json:"year"`
+		Runtime data.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
+	movie.Title = input.Title
+	movie.Year = input.Year
+	movie.Runtime = input.Runtime
+	movie.Genres = input.Genres
+
+	app.saveMovieUpdate(w, r, &before, movie)
+}
+
+// The saveMovieUpdate() helper validates and saves the changes made to a movie by an
+// update or replacement, records them in the revision history and sends the movie back.
+func (app *application) saveMovieUpdate(w http.ResponseWriter, r *http.Request, before, movie *data.Movie) {
 	// validate the updated movie record, sending the client a 422 Unprocessable Entity
 	// response if any checks fail.
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	err := app.validateMovie(r.Context(), v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(r.Context(), movie, app.newMovieRevision(r, data.RevisionUpdate, before, movie))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
-			return
+			app.movieConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// validateMovie replaces the genre names and aliases of the movie by their slugs and
+// runs it through data.ValidateMovie. The error is only for failing to load the genres.
+func (app *application) validateMovie(ctx context.Context, v *validator.Validator, movie *data.Movie) error {
+	genres, err := app.models.Genres.GetIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	movie.Genres = genres.Normalize(movie.Genres)
+	data.ValidateMovie(v, movie, genres)
+	return nil
+}
+
+// readGenres reads a comma-separated list of genres from the query string, like
+// readCSV(), and replaces the names and aliases of known genres by their slugs.
+func (app *application) readGenres(ctx context.Context, qs url.Values, key string) ([]string, error) {
+	genres := app.readCSV(qs, key, []string{})
+	if len(genres) == 0 {
+		return genres, nil
+	}
+
+	index, err := app.models.Genres.GetIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.Normalize(genres), nil
+}
+
+// readTitleAndGenres reads the title and genres filters of the movies, which the
+// listing shares with the stats and the export.
+func (app *application) readTitleAndGenres(ctx context.Context, qs url.Values, f *data.MovieFilters) error {
+	genres, err := app.readGenres(ctx, qs, "genres")
+	if err != nil {
+		return err
+	}
+
+	f.Title = app.readString(qs, "title", "")
+	f.Genres = genres
+	f.GenresMatch = app.readString(qs, "genres_match", data.GenresMatchAll)
+	return nil
+}
+
+// toJSONValue converts a value to the generic form encoding/json decodes JSON into,
+// which is what the jsonpatch package works on.
+func toJSONValue(value any) (any, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	err = json.Unmarshal(js, &doc)
+	return doc, err
+}
+
+// decodeMovieDocument turns a patched movie document back into a movieDocument. A
+// document that doesn't fit, because a field has the wrong type or the patch added a
+// field of its own, is reported in v. Fields the patch removed are left empty, for
+// ValidateMovie to complain about.
+func decodeMovieDocument(doc any, v *validator.Validator) movieDocument {
+	var patched movieDocument
+
+	if _, ok := doc.(map[string]any); !ok {
+		v.AddError("patch", "must leave the movie a JSON object")
+		return patched
+	}
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		v.AddError("patch", "must leave the movie valid JSON")
+		return patched
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(js))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&patched)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+			v.AddError(unmarshalTypeError.Field, "has the wrong JSON type")
+		case errors.Is(err, data.ErrInvalidRuntimeFormat):
+			v.AddError("runtime", err.Error())
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			v.AddError("patch", "must not add the unknown key "+strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			v.AddError("patch", "must leave the movie a valid JSON object")
+		}
+	}
+
+	return patched
+}
+
 func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -168,7 +471,11 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	// the current movie is needed for the revision history, and to check against the
+	// If-Match header when the client sends one. The version is checked again by
+	// DeleteVersion(), so a change that sneaks in between the two queries is still
+	// reported as a conflict.
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -179,7 +486,30 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	revision := app.newMovieRevision(r, data.RevisionDelete, movie, nil)
+
+	if r.Header.Get("If-Match") != "" || app.config.movies.requireIfMatch {
+		if !app.checkIfMatch(w, r, movie) {
+			return
+		}
+
+		err = app.models.Movies.DeleteVersion(r.Context(), movie.ID, movie.Version, revision)
+	} else {
+		err = app.models.Movies.Delete(r.Context(), id, revision)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.movieConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully moved to the trash"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -189,37 +519,307 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	// expected values from the request query string
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieFilters
 		data.Filters
 	}
 
 	v := validator.New()
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.IDs = app.readIDs(qs, "ids", v)
+	err := app.readTitleAndGenres(r.Context(), qs, &input.MovieFilters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	input.PersonID = int64(app.readInt(qs, "person_id", 0, v))
+	v.Check(input.PersonID >= 0, "person_id", "must be a positive integer value")
+	input.CollectionID = int64(app.readInt(qs, "collection", 0, v))
+	v.Check(input.CollectionID >= 0, "collection", "must be a positive integer value")
+
+	// ranges, where either end can be left open
+	input.YearMin = int32(app.readInt(qs, "year_min", 0, v))
+	input.YearMax = int32(app.readInt(qs, "year_max", 0, v))
+	input.RuntimeMin = data.Runtime(app.readInt(qs, "runtime_min", 0, v))
+	input.RuntimeMax = data.Runtime(app.readInt(qs, "runtime_max", 0, v))
+	input.CreatedAfter = app.readTime(qs, "created_after", v)
+	input.CreatedBefore = app.readTime(qs, "created_before", v)
+	input.UpdatedAfter = app.readTime(qs, "updated_after", v)
+	input.UpdatedBefore = app.readTime(qs, "updated_before", v)
+
+	// ranked full-text search
+	input.Search = app.readString(qs, "q", "")
+	input.SearchMode = app.readString(qs, "search_mode", data.SearchModePlain)
+	input.SearchLanguage = app.readString(qs, "language", "english")
 
 	input.Page = app.readInt(qs, "page", 1, v)
-	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	// a batch of ids comes back in one page unless asked otherwise
+	input.PageSize = app.readInt(qs, "page_size", max(20, len(input.IDs)), v)
+
+	// searches are sorted best match first unless asked otherwise
+	defaultSort := "-created_at"
+	if input.Search != "" {
+		defaultSort = "relevance"
+	}
+	input.Sort = app.readString(qs, "sort", defaultSort)
+	input.SortSafeList = []string{"created_at", "title", "year", "runtime", "average_rating", "rating_count", "relevance", "-created_at", "-title", "-year", "-runtime", "-average_rating", "-rating_count", "-relevance"}
 
-	input.Sort = app.readString(qs, "sort", "-created_at")
-	input.SortSafeList = []string{"created_at", "title", "year", "runtime", "-created_at", "-title", "-year", "-runtime"}
+	// the presence of the cursor parameter (even an empty one, for the first page)
+	// switches the listing from page numbers to keyset pagination
+	input.Keyset = qs.Has("cursor")
+	input.Cursor = app.readCursor(qs, "cursor", v)
 
+	input.Facets = app.readCSV(qs, "facets", []string{})
+	input.FacetSafeList = data.MovieFacets
+
+	input.Fields = app.readCSV(qs, "fields", []string{})
+	input.FieldSafeList = append(slices.Clone(data.MovieFields), "relevance", "headline")
+
+	include := app.readCSV(qs, "include", []string{})
+	for _, relation := range include {
+		v.Check(validator.PermittedValue(relation, movieIncludes...), "include", "invalid include value")
+	}
+
+	if strings.TrimPrefix(input.Sort, "-") == "relevance" {
+		v.Check(input.Search != "", "sort", "relevance can only be used with a search (q)")
+		v.Check(!input.Keyset, "sort", "relevance can't be used with cursor pagination")
+	}
+
+	data.ValidateMovieFilters(v, input.MovieFilters)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(r.Context(), input.MovieFilters, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "invalid cursor")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// facets count every matching movie, not just those on this page
+	if len(input.Facets) > 0 {
+		metadata.Facets, err = app.models.Movies.Facets(r.Context(), input.MovieFilters, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.includeMovieRelations(r.Context(), movies, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.localizeTitles(w, r, movies)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	output := make([]any, len(movies))
+	for i, movie := range movies {
+		output[i], err = pickMovieFields(movie, input.Fields, include)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": output, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 
 	}
 }
+
+// suggestMoviesHandler autocompletes movie titles from what the user has typed so far,
+// returning the most similar titles first. It is meant to be called on every keystroke,
+// so it only returns the id, title and year of each movie.
+func (app *application) suggestMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	search := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(search != "", "q", "must be provided")
+	v.Check(len(search) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Suggest(r.Context(), search, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// movieIncludes are the related resources that can be embedded in a movie with the
+// include query string parameter.
+var movieIncludes = []string{"credits", "external_ids", "titles", "releases", "images"}
+
+// The includeMovieRelations() helper embeds the related resources named in include in
+// each of the movies, fetching them for all the movies at once.
+func (app *application) includeMovieRelations(ctx context.Context, movies []*data.Movie, include []string) error {
+	if len(include) == 0 || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	if slices.Contains(include, "credits") {
+		credits, err := app.models.People.GetCreditsForMovies(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, movie := range movies {
+			movie.Credits = credits[movie.ID]
+		}
+	}
+
+	if slices.Contains(include, "external_ids") {
+		externalIDs, err := app.models.ExternalIDs.GetAllForMovies(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, movie := range movies {
+			movie.ExternalIDs = externalIDs[movie.ID]
+		}
+	}
+
+	if slices.Contains(include, "titles") {
+		titles, err := app.models.Titles.GetAllForMovies(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, movie := range movies {
+			movie.Titles = titles[movie.ID]
+		}
+	}
+
+	if slices.Contains(include, "releases") {
+		releases, err := app.models.Releases.GetAllForMovies(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, movie := range movies {
+			movie.Releases = releases[movie.ID]
+		}
+	}
+
+	if slices.Contains(include, "images") {
+		images, err := app.models.Images.GetAllForMovies(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, movie := range movies {
+			app.setImageURLs(images[movie.ID]...)
+			movie.Images = images[movie.ID]
+		}
+	}
+	return nil
+}
+
+// The localizeTitles() helper sets the display title of each movie to the alternate
+// title that best suits the Accept-Language header of the request, if it has one, and
+// reports whether any movie got one. Since the response depends on the header, it
+// adds it to Vary.
+func (app *application) localizeTitles(w http.ResponseWriter, r *http.Request, movies []*data.Movie) (bool, error) {
+	w.Header().Add("Vary", "Accept-Language")
+
+	languages := acceptedLanguages(r.Header.Get("Accept-Language"))
+	if len(languages) == 0 || len(movies) == 0 {
+		return false, nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	titles, err := app.models.Titles.GetAllForMovies(r.Context(), ids)
+	if err != nil {
+		return false, err
+	}
+
+	localized := false
+	for _, movie := range movies {
+		if title := data.PickTitle(titles[movie.ID], languages); title != nil {
+			movie.DisplayTitle = title.Title
+			localized = true
+		}
+	}
+	return localized, nil
+}
+
+// The pickMovieFields() helper applies a sparse fieldset to a movie. The embedded
+// relations are kept, since the client asked for them separately, and so is the
+// display title if the title was asked for.
+func pickMovieFields(movie *data.Movie, fields, include []string) (any, error) {
+	if len(fields) > 0 {
+		fields = append(slices.Clone(fields), include...)
+		if slices.Contains(fields, "title") {
+			fields = append(fields, "display_title")
+		}
+	}
+	return pickFields(movie, fields)
+}
+
+// The movieETag() helper returns the entity tag for a movie. The version number is
+// bumped on every update, so together with the id it identifies the representation.
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d-%d"`, movie.ID, movie.Version)
+}
+
+// The checkIfMatch() helper enforces the If-Match precondition against the current
+// version of a movie. If the request must not go ahead it sends a 428 Precondition
+// Required (when the header is missing but required) or 412 Precondition Failed
+// response and returns false.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, movie *data.Movie) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		if app.config.movies.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	if !etagMatches(ifMatch, movieETag(movie), false) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// The movieConflictResponse() helper reports an ErrEditConflict from the movie model.
+// A client that sent If-Match asked for the change to apply to a specific version only,
+// so a concurrent change means its precondition failed.
+func (app *application) movieConflictResponse(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("If-Match") != "" {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+	app.editConflictResponse(w, r)
+}
@@ -0,0 +1,804 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+// createTestMovie creates a movie through the API and returns its id.
+func (ts *testServer) createTestMovie(t *testing.T, token string, movie map[string]any) int64 {
+	t.Helper()
+
+	res := ts.do(t, http.MethodPost, "/v1/movies", token, movie)
+	if res.status != http.StatusCreated {
+		t.Fatalf("creating movie %v: got status %d, body %v", movie["title"], res.status, res.body)
+	}
+
+	return int64(res.body["movie"].(map[string]any)["id"].(float64))
+}
+
+func TestCreateMovie(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	tests := []struct {
+		name        string
+		body        any
+		wantStatus  int
+		wantErrKeys []string
+	}{
+		{
+			name:       "valid movie",
+			body:       map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "adventure"}},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:        "missing fields",
+			body:        map[string]any{},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrKeys: []string{"title", "year", "runtime", "genres"},
+		},
+		{
+			name:        "invalid values",
+			body:        map[string]any{"title": "Moana", "year": 1700, "runtime": "-1 mins", "genres": []string{"animation", "animation"}},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrKeys: []string{"year", "runtime", "genres"},
+		},
+		{
+			name:       "invalid runtime format",
+			body:       map[string]any{"title": "Moana", "year": 2016, "runtime": 107, "genres": []string{"animation"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "incorrect JSON type",
+			body:       map[string]any{"title": 42, "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown field",
+			body:       map[string]any{"title": "Moana", "rating": "PG"},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/movies", token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			for _, key := range tt.wantErrKeys {
+				if _, ok := res.validationErrors()[key]; !ok {
+					t.Errorf("missing validation error for %q in %v", key, res.body)
+				}
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			movie := res.body["movie"].(map[string]any)
+			wantLocation := fmt.Sprintf("/v1/movies/%d", int64(movie["id"].(float64)))
+			if got := res.header.Get("Location"); got != wantLocation {
+				t.Errorf("got Location %q; want %q", got, wantLocation)
+			}
+			if movie["runtime"] != "107 mins" {
+				t.Errorf("got runtime %v; want %q", movie["runtime"], "107 mins")
+			}
+			if movie["version"] != float64(1) {
+				t.Errorf("got version %v; want 1", movie["version"])
+			}
+		})
+	}
+}
+
+func TestShowMovie(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "existing movie", path: fmt.Sprintf("/v1/movies/%d", id), wantStatus: http.StatusOK},
+		{name: "missing movie", path: fmt.Sprintf("/v1/movies/%d", id+100), wantStatus: http.StatusNotFound},
+		{name: "negative id", path: "/v1/movies/-1", wantStatus: http.StatusNotFound},
+		{name: "non-numeric id", path: "/v1/movies/abc", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, tt.path, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus == http.StatusOK && res.body["movie"].(map[string]any)["title"] != "Moana" {
+				t.Errorf("got movie %v; want Moana", res.body["movie"])
+			}
+		})
+	}
+}
+
+func TestUpdateMovie(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	tests := []struct {
+		name        string
+		path        string
+		body        any
+		wantStatus  int
+		wantVersion float64
+		wantErrKeys []string
+	}{
+		{name: "update title", path: path, body: map[string]any{"title": "Moana 2"}, wantStatus: http.StatusOK, wantVersion: 2},
+		{name: "update genres", path: path, body: map[string]any{"genres": []string{"animation", "musical"}}, wantStatus: http.StatusOK, wantVersion: 3},
+		{name: "no fields", path: path, body: map[string]any{}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid year", path: path, body: map[string]any{"year": 3000}, wantStatus: http.StatusUnprocessableEntity, wantErrKeys: []string{"year"}},
+		{name: "badly-formed JSON", path: path, body: `{"title": `, wantStatus: http.StatusBadRequest},
+		{name: "missing movie", path: fmt.Sprintf("/v1/movies/%d", id+100), body: map[string]any{"title": "Moana 3"}, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPatch, tt.path, token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			for _, key := range tt.wantErrKeys {
+				if _, ok := res.validationErrors()[key]; !ok {
+					t.Errorf("missing validation error for %q in %v", key, res.body)
+				}
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := res.body["movie"].(map[string]any)["version"]; got != tt.wantVersion {
+					t.Errorf("got version %v; want %v", got, tt.wantVersion)
+				}
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, path, token, nil)
+	movie := res.body["movie"].(map[string]any)
+	if movie["title"] != "Moana 2" || len(movie["genres"].([]any)) != 2 {
+		t.Errorf("updates were not persisted: %v", movie)
+	}
+}
+
+func TestDeleteMovie(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "existing movie", path: path, wantStatus: http.StatusOK},
+		{name: "already deleted", path: path, wantStatus: http.StatusNotFound},
+		{name: "invalid id", path: "/v1/movies/abc", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodDelete, tt.path, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	if res := ts.do(t, http.MethodGet, path, token, nil); res.status != http.StatusNotFound {
+		t.Errorf("got status %d for deleted movie; want %d", res.status, http.StatusNotFound)
+	}
+}
+
+func TestListMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	for _, movie := range []map[string]any{
+		{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "adventure"}},
+		{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action", "adventure"}},
+		{"title": "Deadpool", "year": 2016, "runtime": "108 mins", "genres": []string{"action", "comedy"}},
+		{"title": "The Breakfast Club", "year": 1985, "runtime": "97 mins", "genres": []string{"drama"}},
+	} {
+		ts.createTestMovie(t, token, movie)
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantTitles   []string
+		wantTotal    float64
+		wantLastPage float64
+	}{
+		{name: "default sort", query: "", wantStatus: http.StatusOK, wantTitles: []string{"The Breakfast Club", "Deadpool", "Black Panther", "Moana"}, wantTotal: 4, wantLastPage: 1},
+		{name: "title search", query: "?title=breakfast+club", wantStatus: http.StatusOK, wantTitles: []string{"The Breakfast Club"}, wantTotal: 1, wantLastPage: 1},
+		{name: "genres filter", query: "?genres=adventure&sort=title", wantStatus: http.StatusOK, wantTitles: []string{"Black Panther", "Moana"}, wantTotal: 2, wantLastPage: 1},
+		{name: "sort by year then id", query: "?sort=-year", wantStatus: http.StatusOK, wantTitles: []string{"Black Panther", "Moana", "Deadpool", "The Breakfast Club"}, wantTotal: 4, wantLastPage: 1},
+		{name: "pagination", query: "?sort=runtime&page=2&page_size=3", wantStatus: http.StatusOK, wantTitles: []string{"Black Panther"}, wantTotal: 4, wantLastPage: 2},
+		{name: "no matches", query: "?title=missing", wantStatus: http.StatusOK, wantTitles: []string{}},
+		{name: "invalid sort", query: "?sort=id", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid page", query: "?page=0&page_size=101", wantStatus: http.StatusUnprocessableEntity},
+		{name: "non-numeric page", query: "?page=abc", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies"+tt.query, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			movies := res.body["movies"].([]any)
+			titles := make([]string, len(movies))
+			for i, movie := range movies {
+				titles[i] = movie.(map[string]any)["title"].(string)
+			}
+			if fmt.Sprint(titles) != fmt.Sprint(tt.wantTitles) {
+				t.Errorf("got titles %q; want %q", titles, tt.wantTitles)
+			}
+
+			metadata := res.body["metadata"].(map[string]any)
+			if metadata["total_records"] != nil || tt.wantTotal != 0 {
+				if metadata["total_records"] != tt.wantTotal || metadata["last_page"] != tt.wantLastPage {
+					t.Errorf("got metadata %v; want total_records %v and last_page %v", metadata, tt.wantTotal, tt.wantLastPage)
+				}
+			}
+		})
+	}
+}
+
+func TestListMoviesByCursor(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	for _, movie := range []map[string]any{
+		{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "adventure"}},
+		{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action", "adventure"}},
+		{"title": "Deadpool", "year": 2016, "runtime": "108 mins", "genres": []string{"action", "comedy"}},
+		{"title": "The Breakfast Club", "year": 1985, "runtime": "97 mins", "genres": []string{"drama"}},
+		{"title": "Arrival", "year": 2016, "runtime": "116 mins", "genres": []string{"drama", "sci-fi"}},
+	} {
+		ts.createTestMovie(t, token, movie)
+	}
+
+	titlesOf := func(res testResponse) []string {
+		var titles []string
+		for _, movie := range res.body["movies"].([]any) {
+			titles = append(titles, movie.(map[string]any)["title"].(string))
+		}
+		return titles
+	}
+
+	// walking every sort in the safelist forwards and then backwards must visit the
+	// movies in the same order as the offset paginated listing
+	for _, sort := range []string{"created_at", "title", "year", "runtime", "-created_at", "-title", "-year", "-runtime"} {
+		t.Run(sort, func(t *testing.T) {
+			want := titlesOf(ts.do(t, http.MethodGet, "/v1/movies?sort="+sort, token, nil))
+
+			var forward []string
+			var pages []testResponse
+			cursor := ""
+			for {
+				res := ts.do(t, http.MethodGet, "/v1/movies?page_size=2&sort="+sort+"&cursor="+cursor, token, nil)
+				if res.status != http.StatusOK {
+					t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+				}
+				forward = append(forward, titlesOf(res)...)
+				pages = append(pages, res)
+
+				next, _ := res.body["metadata"].(map[string]any)["next_cursor"].(string)
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if fmt.Sprint(forward) != fmt.Sprint(want) {
+				t.Fatalf("got titles %q going forward; want %q", forward, want)
+			}
+
+			if _, ok := pages[0].body["metadata"].(map[string]any)["prev_cursor"]; ok {
+				t.Error("first page has a prev_cursor")
+			}
+
+			var backward []string
+			res := pages[len(pages)-1]
+			for {
+				backward = append(titlesOf(res), backward...)
+
+				prev, _ := res.body["metadata"].(map[string]any)["prev_cursor"].(string)
+				if prev == "" {
+					break
+				}
+				res = ts.do(t, http.MethodGet, "/v1/movies?page_size=2&sort="+sort+"&cursor="+prev, token, nil)
+			}
+			if fmt.Sprint(backward) != fmt.Sprint(want) {
+				t.Fatalf("got titles %q going backward; want %q", backward, want)
+			}
+		})
+	}
+
+	first := ts.do(t, http.MethodGet, "/v1/movies?page_size=2&sort=title&cursor=", token, nil)
+	next := first.body["metadata"].(map[string]any)["next_cursor"].(string)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "malformed cursor", query: "?cursor=not-a-cursor"},
+		{name: "cursor for another sort", query: "?sort=-title&cursor=" + next},
+		{name: "cursor with page", query: "?sort=title&page=2&cursor=" + next},
+		{name: "tampered cursor value", query: "?sort=year&cursor=" + data.Cursor{Sort: "year", Value: "abc", ID: 1}.Encode()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies"+tt.query, token, nil)
+			if res.status != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusUnprocessableEntity, res.body)
+			}
+		})
+	}
+}
+
+func TestMovieConditionalRequests(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	etagV1 := fmt.Sprintf(`"%d-1"`, id)
+	etagV2 := fmt.Sprintf(`"%d-2"`, id)
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		body       any
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "show sends etag", method: http.MethodGet, wantStatus: http.StatusOK, wantETag: etagV1},
+		{name: "show with matching If-None-Match", method: http.MethodGet, header: "If-None-Match", value: etagV1, wantStatus: http.StatusNotModified, wantETag: etagV1},
+		{name: "show with weak If-None-Match", method: http.MethodGet, header: "If-None-Match", value: "W/" + etagV1, wantStatus: http.StatusNotModified, wantETag: etagV1},
+		{name: "show with stale If-None-Match", method: http.MethodGet, header: "If-None-Match", value: `"1-0"`, wantStatus: http.StatusOK, wantETag: etagV1},
+		{name: "update with stale If-Match", method: http.MethodPatch, header: "If-Match", value: etagV2, body: map[string]any{"title": "Moana 2"}, wantStatus: http.StatusPreconditionFailed},
+		{name: "update with weak If-Match", method: http.MethodPatch, header: "If-Match", value: "W/" + etagV1, body: map[string]any{"title": "Moana 2"}, wantStatus: http.StatusPreconditionFailed},
+		{name: "update with matching If-Match", method: http.MethodPatch, header: "If-Match", value: etagV1, body: map[string]any{"title": "Moana 2"}, wantStatus: http.StatusOK, wantETag: etagV2},
+		{name: "delete with stale If-Match", method: http.MethodDelete, header: "If-Match", value: etagV1, wantStatus: http.StatusPreconditionFailed},
+		{name: "delete with matching If-Match", method: http.MethodDelete, header: "If-Match", value: etagV2, wantStatus: http.StatusOK},
+		{name: "delete missing movie with If-Match", method: http.MethodDelete, header: "If-Match", value: "*", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			if tt.header != "" {
+				header.Set(tt.header, tt.value)
+			}
+
+			res := ts.doWithHeader(t, tt.method, path, token, header, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if got := res.header.Get("ETag"); got != tt.wantETag {
+				t.Errorf("got ETag %q; want %q", got, tt.wantETag)
+			}
+		})
+	}
+
+	t.Run("If-Match required", func(t *testing.T) {
+		ts.app.config.movies.requireIfMatch = true
+		defer func() { ts.app.config.movies.requireIfMatch = false }()
+
+		id := ts.createTestMovie(t, token, map[string]any{"title": "Up", "year": 2009, "runtime": "96 mins", "genres": []string{"animation"}})
+		path := fmt.Sprintf("/v1/movies/%d", id)
+
+		for _, method := range []string{http.MethodPatch, http.MethodPut, http.MethodDelete} {
+			res := ts.do(t, method, path, token, map[string]any{"title": "Up!"})
+			if res.status != http.StatusPreconditionRequired {
+				t.Errorf("%s: got status %d; want %d", method, res.status, http.StatusPreconditionRequired)
+			}
+		}
+	})
+}
+
+func TestPatchMovie(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "musical"}})
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	mergePatch := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	jsonPatch := http.Header{"Content-Type": {"application/json-patch+json"}}
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		body        string
+		wantStatus  int
+		wantErrKeys []string
+		wantGenres  []string
+	}{
+		{name: "merge patch", header: mergePatch, body: `{"title": "Moana!", "genres": ["animation", "musical", "adventure"]}`, wantStatus: http.StatusOK, wantGenres: []string{"animation", "musical", "adventure"}},
+		{name: "merge patch removing a required field", header: mergePatch, body: `{"genres": null}`, wantStatus: http.StatusUnprocessableEntity, wantErrKeys: []string{"genres"}},
+		{name: "merge patch with unknown field", header: mergePatch, body: `{"rating": 5}`, wantStatus: http.StatusUnprocessableEntity, wantErrKeys: []string{"patch"}},
+		{name: "merge patch with stale version", header: mergePatch, body: `{"title": "Moana", "version": 1}`, wantStatus: http.StatusConflict},
+		{name: "remove a genre", header: jsonPatch, body: `[{"op": "remove", "path": "/genres/1"}]`, wantStatus: http.StatusOK, wantGenres: []string{"animation", "adventure"}},
+		{name: "add a genre", header: jsonPatch, body: `[{"op": "add", "path": "/genres/-", "value": "family"}]`, wantStatus: http.StatusOK, wantGenres: []string{"animation", "adventure", "family"}},
+		{name: "test and replace", header: jsonPatch, body: `[{"op": "test", "path": "/version", "value": 4}, {"op": "replace", "path": "/genres/0", "value": "comedy"}]`, wantStatus: http.StatusOK, wantGenres: []string{"comedy", "adventure", "family"}},
+		{name: "failed test", header: jsonPatch, body: `[{"op": "test", "path": "/version", "value": 1}, {"op": "remove", "path": "/genres/0"}]`, wantStatus: http.StatusConflict},
+		{name: "missing path", header: jsonPatch, body: `[{"op": "remove", "path": "/genres/9"}]`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown op", header: jsonPatch, body: `[{"op": "append", "path": "/genres", "value": "drama"}]`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "wrong type", header: jsonPatch, body: `[{"op": "replace", "path": "/year", "value": "2016"}]`, wantStatus: http.StatusUnprocessableEntity, wantErrKeys: []string{"year"}},
+		{name: "removing a required field", header: jsonPatch, body: `[{"op": "remove", "path": "/title"}]`, wantStatus: http.StatusUnprocessableEntity, wantErrKeys: []string{"title"}},
+		{name: "empty patch", header: jsonPatch, body: `[]`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "badly-formed patch", header: jsonPatch, body: `{"op": "remove"}`, wantStatus: http.StatusBadRequest},
+		{name: "unsupported content type", header: http.Header{"Content-Type": {"text/plain"}}, body: `{"title": "Moana"}`, wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.doWithHeader(t, http.MethodPatch, path, token, tt.header, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			for _, key := range tt.wantErrKeys {
+				if _, ok := res.validationErrors()[key]; !ok {
+					t.Errorf("missing validation error for %q in %v", key, res.body)
+				}
+			}
+			if tt.wantGenres != nil {
+				if got := res.body["movie"].(map[string]any)["genres"]; fmt.Sprint(got) != fmt.Sprint(tt.wantGenres) {
+					t.Errorf("got genres %v; want %v", got, tt.wantGenres)
+				}
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType && res.header.Get("Accept-Patch") == "" {
+				t.Error("got no Accept-Patch header")
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, path, token, nil)
+	movie := res.body["movie"].(map[string]any)
+	if movie["title"] != "Moana!" || movie["version"] != 5.0 {
+		t.Errorf("got movie %v; want the successful patches applied", movie)
+	}
+}
+
+func TestReplaceMovie(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	id := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	tests := []struct {
+		name        string
+		path        string
+		body        any
+		wantStatus  int
+		wantErrKeys []string
+	}{
+		{name: "replace", path: path, body: map[string]any{"title": "Moana 2", "year": 2024, "runtime": "100 mins", "genres": []string{"adventure"}}, wantStatus: http.StatusOK},
+		{name: "missing fields", path: path, body: map[string]any{"title": "Moana 3"}, wantStatus: http.StatusUnprocessableEntity, wantErrKeys: []string{"year", "runtime", "genres"}},
+		{name: "unknown field", path: path, body: map[string]any{"title": "Moana 3", "version": 1}, wantStatus: http.StatusBadRequest},
+		{name: "missing movie", path: fmt.Sprintf("/v1/movies/%d", id+100), body: map[string]any{"title": "Moana 3"}, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPut, tt.path, token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			for _, key := range tt.wantErrKeys {
+				if _, ok := res.validationErrors()[key]; !ok {
+					t.Errorf("missing validation error for %q in %v", key, res.body)
+				}
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, path, token, nil)
+	movie := res.body["movie"].(map[string]any)
+	if movie["title"] != "Moana 2" || movie["year"] != 2024.0 || fmt.Sprint(movie["genres"]) != "[adventure]" || movie["version"] != 2.0 {
+		t.Errorf("got movie %v; want it replaced", movie)
+	}
+}
+
+func TestSearchMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	for _, movie := range []map[string]any{
+		{"title": "Star Wars", "year": 1977, "runtime": "121 mins", "genres": []string{"sci-fi", "adventure"}},
+		{"title": "Star Trek", "year": 2009, "runtime": "127 mins", "genres": []string{"sci-fi"}},
+		{"title": "Wars of the Roses", "year": 1989, "runtime": "116 mins", "genres": []string{"comedy"}},
+		{"title": "Adventure Time Movie", "year": 2020, "runtime": "90 mins", "genres": []string{"animation"}},
+	} {
+		ts.createTestMovie(t, token, movie)
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		wantStatus    int
+		wantTitles    []string
+		wantHeadlines []string
+	}{
+		{name: "plain", query: "?q=star", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars", "Star Trek"}, wantHeadlines: []string{"<mark>Star</mark> Wars", "<mark>Star</mark> Trek"}},
+		{name: "title ranks above genres", query: "?q=adventure", wantStatus: http.StatusOK, wantTitles: []string{"Adventure Time Movie", "Star Wars"}, wantHeadlines: []string{"<mark>Adventure</mark> Time Movie", "Star Wars"}},
+		{name: "least relevant first", query: "?q=adventure&sort=-relevance", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars", "Adventure Time Movie"}},
+		{name: "other sort", query: "?q=star&sort=-year", wantStatus: http.StatusOK, wantTitles: []string{"Star Trek", "Star Wars"}},
+		{name: "combined with filters", query: "?q=star&genres=adventure", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars"}},
+		{name: "prefix", query: "?q=sta+tre&search_mode=prefix", wantStatus: http.StatusOK, wantTitles: []string{"Star Trek"}, wantHeadlines: []string{"<mark>Star</mark> <mark>Trek</mark>"}},
+		{name: "phrase", query: "?q=star+wars&search_mode=phrase", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars"}},
+		{name: "phrase out of order", query: "?q=wars+star&search_mode=phrase", wantStatus: http.StatusOK, wantTitles: []string{}},
+		{name: "websearch exclusion", query: "?q=wars+-star&search_mode=websearch", wantStatus: http.StatusOK, wantTitles: []string{"Wars of the Roses"}},
+		{name: "simple language", query: "?q=roses&language=simple", wantStatus: http.StatusOK, wantTitles: []string{"Wars of the Roses"}},
+		{name: "fuzzy", query: "?q=strar+trek&search_mode=fuzzy", wantStatus: http.StatusOK, wantTitles: []string{"Star Trek"}, wantHeadlines: []string{""}},
+		{name: "fuzzy partial title", query: "?q=adventur&search_mode=fuzzy", wantStatus: http.StatusOK, wantTitles: []string{"Adventure Time Movie"}},
+		{name: "relevance without search", query: "?sort=relevance", wantStatus: http.StatusUnprocessableEntity},
+		{name: "relevance with cursor", query: "?q=star&cursor=", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid mode", query: "?q=star&search_mode=regex", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid language", query: "?q=star&language=klingon", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies"+tt.query, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var titles, headlines []string
+			for _, movie := range res.body["movies"].([]any) {
+				movie := movie.(map[string]any)
+				titles = append(titles, movie["title"].(string))
+				headline, _ := movie["headline"].(string)
+				headlines = append(headlines, headline)
+
+				if movie["relevance"] == nil {
+					t.Errorf("got no relevance for %q", movie["title"])
+				}
+			}
+			if fmt.Sprint(titles) != fmt.Sprint(tt.wantTitles) {
+				t.Errorf("got titles %q; want %q", titles, tt.wantTitles)
+			}
+			if tt.wantHeadlines != nil && fmt.Sprint(headlines) != fmt.Sprint(tt.wantHeadlines) {
+				t.Errorf("got headlines %q; want %q", headlines, tt.wantHeadlines)
+			}
+		})
+	}
+}
+
+func TestFilterMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	for _, movie := range []map[string]any{
+		{"title": "Star Wars", "year": 1977, "runtime": "121 mins", "genres": []string{"sci-fi", "adventure"}},
+		{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"sci-fi", "horror"}},
+		{"title": "Heat", "year": 1995, "runtime": "170 mins", "genres": []string{"crime"}},
+		{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation", "adventure"}},
+	} {
+		ts.createTestMovie(t, token, movie)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "year range", query: "?year_min=1978&year_max=2000", wantStatus: http.StatusOK, wantTitles: []string{"Alien", "Heat"}},
+		{name: "open year range", query: "?year_min=1995", wantStatus: http.StatusOK, wantTitles: []string{"Heat", "Moana"}},
+		{name: "runtime range", query: "?runtime_min=110&runtime_max=125", wantStatus: http.StatusOK, wantTitles: []string{"Alien", "Star Wars"}},
+		{name: "all genres", query: "?genres=sci-fi,adventure", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars"}},
+		{name: "any genre", query: "?genres=horror,crime&genres_match=any", wantStatus: http.StatusOK, wantTitles: []string{"Alien", "Heat"}},
+		{name: "created after", query: "?created_after=2000-01-01", wantStatus: http.StatusOK, wantTitles: []string{"Alien", "Heat", "Moana", "Star Wars"}},
+		{name: "updated before", query: "?updated_before=2000-01-01T00:00:00Z", wantStatus: http.StatusOK, wantTitles: []string{}},
+		{name: "inverted year range", query: "?year_min=2000&year_max=1990", wantStatus: http.StatusUnprocessableEntity},
+		{name: "inverted date range", query: "?created_after=2020-01-01&created_before=2019-01-01", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid date", query: "?created_after=yesterday", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid genres match", query: "?genres_match=none", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid facet", query: "?facets=title", wantStatus: http.StatusUnprocessableEntity},
+		{name: "duplicate facet", query: "?facets=year,year", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies?sort=title"+strings.Replace(tt.query, "?", "&", 1), token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			titles := []string{}
+			for _, movie := range res.body["movies"].([]any) {
+				titles = append(titles, movie.(map[string]any)["title"].(string))
+			}
+			if fmt.Sprint(titles) != fmt.Sprint(tt.wantTitles) {
+				t.Errorf("got titles %q; want %q", titles, tt.wantTitles)
+			}
+		})
+	}
+
+	t.Run("facets", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies?year_max=2000&page_size=1&facets=genres,year", token, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+
+		facets := res.body["metadata"].(map[string]any)["facets"].(map[string]any)
+		wantGenres := "[map[count:2 value:sci-fi] map[count:1 value:adventure] map[count:1 value:crime] map[count:1 value:horror]]"
+		if got := fmt.Sprint(facets["genres"]); got != wantGenres {
+			t.Errorf("got genres facet %s; want %s", got, wantGenres)
+		}
+		wantYears := "[map[count:1 value:1995] map[count:1 value:1979] map[count:1 value:1977]]"
+		if got := fmt.Sprint(facets["year"]); got != wantYears {
+			t.Errorf("got year facet %s; want %s", got, wantYears)
+		}
+
+		res = ts.do(t, http.MethodGet, "/v1/movies", token, nil)
+		if _, ok := res.body["metadata"].(map[string]any)["facets"]; ok {
+			t.Errorf("got facets without asking for them")
+		}
+	})
+}
+
+func TestMovieFields(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	director := ts.createTestPerson(t, token, map[string]any{"name": "Ryan Coogler"})
+	ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/credits", movieID), token, map[string]any{"person_id": director, "role": "director"})
+
+	keys := func(object any) string {
+		var keys []string
+		for key := range object.(map[string]any) {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return fmt.Sprint(keys)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantKeys   []string // of each movie
+	}{
+		{name: "show", path: fmt.Sprintf("/v1/movies/%d?fields=id,title", movieID), wantStatus: http.StatusOK, wantKeys: []string{"id", "title"}},
+		{name: "show with include", path: fmt.Sprintf("/v1/movies/%d?fields=title&include=credits", movieID), wantStatus: http.StatusOK, wantKeys: []string{"credits", "title"}},
+		{name: "list", path: "/v1/movies?fields=id,title", wantStatus: http.StatusOK, wantKeys: []string{"id", "title"}},
+		{name: "list sorted by another field", path: "/v1/movies?fields=title&sort=year&cursor=", wantStatus: http.StatusOK, wantKeys: []string{"title"}},
+		{name: "list search", path: "/v1/movies?q=moana&fields=title,relevance", wantStatus: http.StatusOK, wantKeys: []string{"relevance", "title"}},
+		{name: "unknown field", path: "/v1/movies?fields=id,password", wantStatus: http.StatusUnprocessableEntity},
+		{name: "duplicate field", path: fmt.Sprintf("/v1/movies/%d?fields=id,id", movieID), wantStatus: http.StatusUnprocessableEntity},
+		{name: "search field on show", path: fmt.Sprintf("/v1/movies/%d?fields=relevance", movieID), wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown include", path: "/v1/movies?include=reviews", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, tt.path, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			movies, ok := res.body["movies"].([]any)
+			if !ok {
+				movies = []any{res.body["movie"]}
+			}
+			if len(movies) == 0 {
+				t.Fatal("got no movies")
+			}
+			for _, movie := range movies {
+				if got := keys(movie); got != fmt.Sprint(tt.wantKeys) {
+					t.Errorf("got fields %s; want %v", got, tt.wantKeys)
+				}
+			}
+		})
+	}
+
+	t.Run("list with include", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies?sort=title&include=credits", token, nil)
+		movies := res.body["movies"].([]any)
+		credits, _ := movies[0].(map[string]any)["credits"].([]any)
+		if len(credits) != 1 || credits[0].(map[string]any)["name"] != "Ryan Coogler" {
+			t.Errorf("got credits %v; want the director", credits)
+		}
+		if _, ok := movies[1].(map[string]any)["credits"]; ok {
+			t.Errorf("got credits for a movie without any")
+		}
+	})
+
+	t.Run("no etag for a fieldset", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?fields=title", movieID), token, nil)
+		if etag := res.header.Get("ETag"); etag != "" {
+			t.Errorf("got ETag %q; want none", etag)
+		}
+	})
+}
+
+func TestSuggestMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	for _, movie := range []map[string]any{
+		{"title": "Star Wars", "year": 1977, "runtime": "121 mins", "genres": []string{"sci-fi"}},
+		{"title": "Star Trek", "year": 2009, "runtime": "127 mins", "genres": []string{"sci-fi"}},
+		{"title": "Stardust", "year": 2007, "runtime": "127 mins", "genres": []string{"fantasy"}},
+		{"title": "Casablanca", "year": 1942, "runtime": "102 mins", "genres": []string{"drama"}},
+	} {
+		ts.createTestMovie(t, token, movie)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "best match first", query: "?q=star", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars", "Star Trek", "Stardust"}},
+		{name: "whole title", query: "?q=star+trek", wantStatus: http.StatusOK, wantTitles: []string{"Star Trek"}},
+		{name: "typo", query: "?q=casablanka", wantStatus: http.StatusOK, wantTitles: []string{"Casablanca"}},
+		{name: "limit", query: "?q=star&limit=2", wantStatus: http.StatusOK, wantTitles: []string{"Star Wars", "Star Trek"}},
+		{name: "no matches", query: "?q=zzz", wantStatus: http.StatusOK, wantTitles: []string{}},
+		{name: "missing query", query: "", wantStatus: http.StatusUnprocessableEntity},
+		{name: "limit too large", query: "?q=star&limit=21", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/v1/movies/suggest"+tt.query, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			titles := []string{}
+			for _, suggestion := range res.body["suggestions"].([]any) {
+				suggestion := suggestion.(map[string]any)
+				titles = append(titles, suggestion["title"].(string))
+
+				if similarity, _ := suggestion["similarity"].(float64); similarity <= 0 || similarity > 1 {
+					t.Errorf("got similarity %v for %q", suggestion["similarity"], suggestion["title"])
+				}
+			}
+			if fmt.Sprint(titles) != fmt.Sprint(tt.wantTitles) {
+				t.Errorf("got titles %q; want %q", titles, tt.wantTitles)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+// createTestPerson creates a person through the API and returns their id.
+func (ts *testServer) createTestPerson(t *testing.T, token string, person map[string]any) int64 {
+	t.Helper()
+
+	res := ts.do(t, http.MethodPost, "/v1/people", token, person)
+	if res.status != http.StatusCreated {
+		t.Fatalf("creating person %v: got status %d, body %v", person["name"], res.status, res.body)
+	}
+
+	return int64(res.body["person"].(map[string]any)["id"].(float64))
+}
+
+func TestPeople(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	id := ts.createTestPerson(t, token, map[string]any{"name": "Ryan Coogler", "birth_year": 1986})
+	ts.createTestPerson(t, token, map[string]any{"name": "Chadwick Boseman", "birth_year": 1976})
+	path := fmt.Sprintf("/v1/people/%d", id)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "create without name", method: http.MethodPost, path: "/v1/people", token: token, body: map[string]any{"birth_year": 1986}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create with future birth year", method: http.MethodPost, path: "/v1/people", token: token, body: map[string]any{"name": "Nobody", "birth_year": 3000}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "create without permission", method: http.MethodPost, path: "/v1/people", token: readerToken, body: map[string]any{"name": "Nobody"}, wantStatus: http.StatusForbidden},
+		{name: "show", method: http.MethodGet, path: path, token: readerToken, wantStatus: http.StatusOK},
+		{name: "show missing person", method: http.MethodGet, path: "/v1/people/999", token: readerToken, wantStatus: http.StatusNotFound},
+		{name: "update", method: http.MethodPatch, path: path, token: token, body: map[string]any{"biography": "Director and writer."}, wantStatus: http.StatusOK},
+		{name: "update without fields", method: http.MethodPatch, path: path, token: token, body: map[string]any{}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update missing person", method: http.MethodPatch, path: "/v1/people/999", token: token, body: map[string]any{"name": "Nobody"}, wantStatus: http.StatusNotFound},
+		{name: "list", method: http.MethodGet, path: "/v1/people?name=coogler", token: readerToken, wantStatus: http.StatusOK},
+		{name: "list with invalid sort", method: http.MethodGet, path: "/v1/people?sort=biography", token: readerToken, wantStatus: http.StatusUnprocessableEntity},
+		{name: "delete", method: http.MethodDelete, path: path, token: token, wantStatus: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: path, token: token, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, "/v1/people?sort=-birth_year", readerToken, nil)
+	people := res.body["people"].([]any)
+	if len(people) != 1 || people[0].(map[string]any)["name"] != "Chadwick Boseman" {
+		t.Errorf("got people %v; want only Chadwick Boseman", people)
+	}
+}
+
+func TestMovieCredits(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action"}})
+	otherMovieID := ts.createTestMovie(t, token, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	director := ts.createTestPerson(t, token, map[string]any{"name": "Ryan Coogler"})
+	actor := ts.createTestPerson(t, token, map[string]any{"name": "Chadwick Boseman"})
+	creditsPath := fmt.Sprintf("/v1/movies/%d/credits", movieID)
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+	}{
+		{name: "actor", body: map[string]any{"person_id": actor, "role": "actor", "character": "T'Challa", "billing_order": 1}, wantStatus: http.StatusCreated},
+		{name: "director", body: map[string]any{"person_id": director, "role": "director"}, wantStatus: http.StatusCreated},
+		{name: "writer", body: map[string]any{"person_id": director, "role": "writer"}, wantStatus: http.StatusCreated},
+		{name: "duplicate credit", body: map[string]any{"person_id": director, "role": "director"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown role", body: map[string]any{"person_id": director, "role": "caterer"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "character for a director", body: map[string]any{"person_id": director, "role": "director", "character": "Himself"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown person", body: map[string]any{"person_id": 999, "role": "actor"}, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, creditsPath, token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	t.Run("credits on missing movie", func(t *testing.T) {
+		res := ts.do(t, http.MethodPost, "/v1/movies/999/credits", token, map[string]any{"person_id": actor, "role": "actor"})
+		if res.status != http.StatusNotFound {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("embedded in movie", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=credits", movieID), token, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+
+		var got []string
+		for _, credit := range res.body["movie"].(map[string]any)["credits"].([]any) {
+			credit := credit.(map[string]any)
+			got = append(got, fmt.Sprintf("%s:%s", credit["role"], credit["name"]))
+		}
+		want := []string{"director:Ryan Coogler", "writer:Ryan Coogler", "actor:Chadwick Boseman"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got credits %q; want %q", got, want)
+		}
+	})
+
+	t.Run("invalid include", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=reviews", movieID), token, nil)
+		if res.status != http.StatusUnprocessableEntity {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("filter movies by person", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies?person_id=%d", actor), token, nil)
+		movies := res.body["movies"].([]any)
+		if len(movies) != 1 || movies[0].(map[string]any)["title"] != "Black Panther" {
+			t.Errorf("got movies %v; want only Black Panther", movies)
+		}
+	})
+
+	t.Run("delete credit", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, creditsPath, token, nil)
+		creditID := int64(res.body["credits"].([]any)[0].(map[string]any)["id"].(float64))
+
+		wrongMovie := fmt.Sprintf("/v1/movies/%d/credits/%d", otherMovieID, creditID)
+		if res := ts.do(t, http.MethodDelete, wrongMovie, token, nil); res.status != http.StatusNotFound {
+			t.Errorf("deleting through another movie: got status %d; want %d", res.status, http.StatusNotFound)
+		}
+
+		path := fmt.Sprintf("%s/%d", creditsPath, creditID)
+		if res := ts.do(t, http.MethodDelete, path, token, nil); res.status != http.StatusOK {
+			t.Errorf("got status %d; want %d", res.status, http.StatusOK)
+		}
+
+		res = ts.do(t, http.MethodGet, creditsPath, token, nil)
+		if got := len(res.body["credits"].([]any)); got != 2 {
+			t.Errorf("got %d credits; want 2", got)
+		}
+	})
+
+	t.Run("deleting a person removes their credits", func(t *testing.T) {
+		ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/people/%d", actor), token, nil)
+
+		res := ts.do(t, http.MethodGet, creditsPath, token, nil)
+		if got := len(res.body["credits"].([]any)); got != 1 {
+			t.Errorf("got %d credits; want 1", got)
+		}
+	})
+}
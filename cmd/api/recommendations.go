@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+// similarMoviesHandler lists the movies most like the given one, going by their genres,
+// years and titles, with how similar they are as their relevance.
+func (app *application) similarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	filters, include, v := app.readRankedMoviesQuery(r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetSimilar(r.Context(), movie, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeRankedMovies(w, r, movies, metadata, include)
+}
+
+// recommendationsHandler lists the movies the user might like, going by the movies
+// they have rated and put on their lists, with how strongly each is recommended as its
+// relevance.
+func (app *application) recommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	filters, include, v := app.readRankedMoviesQuery(r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetRecommended(r.Context(), app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeRankedMovies(w, r, movies, metadata, include)
+}
+
+// The readRankedMoviesQuery() helper reads the pagination and include parameters of a
+// listing of movies that always comes best match first.
+func (app *application) readRankedMoviesQuery(r *http.Request) (data.Filters, []string, *validator.Validator) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         "-relevance",
+		SortSafeList: []string{"-relevance"},
+	}
+	data.ValidateFilters(v, filters)
+
+	include := app.readCSV(qs, "include", []string{})
+	for _, relation := range include {
+		v.Check(validator.PermittedValue(relation, movieIncludes...), "include", "invalid include value")
+	}
+
+	return filters, include, v
+}
+
+func (app *application) writeRankedMovies(w http.ResponseWriter, r *http.Request, movies []*data.Movie, metadata data.Metadata, include []string) {
+	err := app.includeMovieRelations(r.Context(), movies, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.localizeTitles(w, r, movies)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+// movieTitles returns the titles of the movies in a listing response, in order.
+func movieTitles(res testResponse) []string {
+	var titles []string
+	for _, movie := range res.body["movies"].([]any) {
+		titles = append(titles, movie.(map[string]any)["title"].(string))
+	}
+	return titles
+}
+
+func TestSimilarMovies(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	matrixID := ts.createTestMovie(t, token, map[string]any{"title": "The Matrix", "year": 1999, "runtime": "136 mins", "genres": []string{"sci-fi", "action"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Blade Runner", "year": 1982, "runtime": "117 mins", "genres": []string{"sci-fi", "drama"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Inception", "year": 2010, "runtime": "148 mins", "genres": []string{"sci-fi", "action", "thriller"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "The Matrix Reloaded", "year": 2003, "runtime": "138 mins", "genres": []string{"action", "sci-fi"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Notting Hill", "year": 1999, "runtime": "124 mins", "genres": []string{"romance", "comedy"}})
+	trashedID := ts.createTestMovie(t, token, map[string]any{"title": "Terminator 2: Judgment Day", "year": 1991, "runtime": "137 mins", "genres": []string{"sci-fi", "action"}})
+	if res := ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", trashedID), token, nil); res.status != http.StatusOK {
+		t.Fatalf("deleting movie: got status %d", res.status)
+	}
+
+	path := fmt.Sprintf("/v1/movies/%d/similar", matrixID)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "most similar first", path: path, wantStatus: http.StatusOK, wantTitles: []string{"The Matrix Reloaded", "Inception", "Blade Runner"}},
+		{name: "second page", path: path + "?page=2&page_size=1", wantStatus: http.StatusOK, wantTitles: []string{"Inception"}},
+		{name: "with relations", path: path + "?include=credits", wantStatus: http.StatusOK, wantTitles: []string{"The Matrix Reloaded", "Inception", "Blade Runner"}},
+		{name: "invalid page size", path: path + "?page_size=1000", wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid include", path: path + "?include=trailers", wantStatus: http.StatusUnprocessableEntity},
+		{name: "missing movie", path: "/v1/movies/999/similar", wantStatus: http.StatusNotFound},
+		{name: "movie in the trash", path: fmt.Sprintf("/v1/movies/%d/similar", trashedID), wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, tt.path, token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := movieTitles(res); !slices.Equal(got, tt.wantTitles) {
+				t.Errorf("got movies %v; want %v", got, tt.wantTitles)
+			}
+			if total := res.body["metadata"].(map[string]any)["total_records"]; total != 3.0 {
+				t.Errorf("got %v total records; want 3", total)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, path, token, nil)
+	movies := res.body["movies"].([]any)
+	first, last := movies[0].(map[string]any)["relevance"].(float64), movies[2].(map[string]any)["relevance"].(float64)
+	if first > 1 || last <= 0 || first <= last {
+		t.Errorf("got relevances from %v to %v; want them decreasing between 0 and 1", first, last)
+	}
+}
+
+func TestRecommendations(t *testing.T) {
+	ts := newTestServer(t)
+	writerToken := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	aliceToken := ts.newActivatedUser(t, "alice@example.com", data.PermissionsCode.ReviewsWrite)
+	bobToken := ts.newActivatedUser(t, "bob@example.com")
+
+	alien := ts.createTestMovie(t, writerToken, map[string]any{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": []string{"horror", "sci-fi"}})
+	mammaMia := ts.createTestMovie(t, writerToken, map[string]any{"title": "Mamma Mia!", "year": 2008, "runtime": "108 mins", "genres": []string{"musical", "comedy"}})
+	theThing := ts.createTestMovie(t, writerToken, map[string]any{"title": "The Thing", "year": 1982, "runtime": "109 mins", "genres": []string{"horror"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Aliens", "year": 1986, "runtime": "137 mins", "genres": []string{"action", "sci-fi"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Halloween", "year": 1978, "runtime": "91 mins", "genres": []string{"horror"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Scary Movie", "year": 2000, "runtime": "88 mins", "genres": []string{"horror", "comedy"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "The Mask", "year": 1994, "runtime": "101 mins", "genres": []string{"comedy"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Grease", "year": 1978, "runtime": "110 mins", "genres": []string{"musical", "romance"}})
+
+	// alice loves Alien, hates Mamma Mia! and wants to see The Thing
+	for movieID, rating := range map[int64]int{alien: 9, mammaMia: 2} {
+		res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/reviews", movieID), aliceToken, map[string]any{"rating": rating})
+		if res.status != http.StatusCreated {
+			t.Fatalf("reviewing movie %d: got status %d, body %v", movieID, res.status, res.body)
+		}
+	}
+	listID := ts.createTestList(t, aliceToken, map[string]any{"name": "Watchlist"})
+	if res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/lists/%d/movies", listID), aliceToken, map[string]any{"movie_id": theThing}); res.status != http.StatusCreated {
+		t.Fatalf("adding to list: got status %d, body %v", res.status, res.body)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "from ratings and lists", path: "/v1/users/me/recommendations", token: aliceToken, wantStatus: http.StatusOK, wantTitles: []string{"Halloween", "Aliens", "Scary Movie"}},
+		{name: "first page", path: "/v1/users/me/recommendations?page_size=2", token: aliceToken, wantStatus: http.StatusOK, wantTitles: []string{"Halloween", "Aliens"}},
+		{name: "without activity", path: "/v1/users/me/recommendations", token: bobToken, wantStatus: http.StatusOK},
+		{name: "invalid page", path: "/v1/users/me/recommendations?page=0", token: aliceToken, wantStatus: http.StatusUnprocessableEntity},
+		{name: "anonymous", path: "/v1/users/me/recommendations", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, tt.path, tt.token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := movieTitles(res); !slices.Equal(got, tt.wantTitles) {
+				t.Errorf("got movies %v; want %v", got, tt.wantTitles)
+			}
+		})
+	}
+}
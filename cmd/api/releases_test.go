@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieReleases(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "The Dark Knight", "year": 2008, "runtime": "152 mins", "genres": []string{"action"}})
+	path := fmt.Sprintf("/v1/movies/%d/releases", movieID)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "set without permission", method: http.MethodPut, path: path + "/US", token: readerToken, body: map[string]any{"release_date": "2008-07-18", "certification": "PG-13"}, wantStatus: http.StatusForbidden},
+		{name: "set invalid country", method: http.MethodPut, path: path + "/USA", token: token, body: map[string]any{"release_date": "2008-07-18"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set invalid date", method: http.MethodPut, path: path + "/US", token: token, body: map[string]any{"release_date": "18/07/2008"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set without date", method: http.MethodPut, path: path + "/US", token: token, body: map[string]any{"certification": "PG-13"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set for missing movie", method: http.MethodPut, path: "/v1/movies/999/releases/US", token: token, body: map[string]any{"release_date": "2008-07-18"}, wantStatus: http.StatusNotFound},
+		{name: "set", method: http.MethodPut, path: path + "/US", token: token, body: map[string]any{"release_date": "2008-07-18", "certification": "PG-13"}, wantStatus: http.StatusOK},
+		{name: "set lowercase country", method: http.MethodPut, path: path + "/gb", token: token, body: map[string]any{"release_date": "2008-07-24", "certification": "12A"}, wantStatus: http.StatusOK},
+		{name: "set without certification", method: http.MethodPut, path: path + "/AU", token: token, body: map[string]any{"release_date": "2008-07-16"}, wantStatus: http.StatusOK},
+		{name: "replace", method: http.MethodPut, path: path + "/GB", token: token, body: map[string]any{"release_date": "2008-07-25", "certification": "12A"}, wantStatus: http.StatusOK},
+		{name: "list", method: http.MethodGet, path: path, token: readerToken, wantStatus: http.StatusOK},
+		{name: "delete", method: http.MethodDelete, path: path + "/AU", token: token, wantStatus: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: path + "/AU", token: token, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	// releases come earliest first
+	res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=releases", movieID), readerToken, nil)
+	releases := res.body["movie"].(map[string]any)["releases"].([]any)
+	if len(releases) != 2 {
+		t.Fatalf("got releases %v; want US and GB", releases)
+	}
+	first, second := releases[0].(map[string]any), releases[1].(map[string]any)
+	if first["country"] != "US" || first["certification"] != "PG-13" || second["country"] != "GB" || second["release_date"] != "2008-07-25" {
+		t.Errorf("got releases %v; want US on 2008-07-18 then GB on 2008-07-25", releases)
+	}
+}
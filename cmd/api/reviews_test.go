@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieReviews(t *testing.T) {
+	ts := newTestServer(t)
+	writerToken := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	aliceToken := ts.newActivatedUser(t, "alice@example.com")
+	bobToken := ts.newActivatedUser(t, "bob@example.com")
+
+	movieID := ts.createTestMovie(t, writerToken, map[string]any{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action"}})
+	otherMovieID := ts.createTestMovie(t, writerToken, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	reviewsPath := fmt.Sprintf("/v1/movies/%d/reviews", movieID)
+
+	res := ts.do(t, http.MethodPost, reviewsPath, aliceToken, map[string]any{"rating": 9, "body": "Wakanda forever."})
+	if res.status != http.StatusCreated {
+		t.Fatalf("creating review: got status %d, body %v", res.status, res.body)
+	}
+	reviewID := int64(res.body["review"].(map[string]any)["id"].(float64))
+	reviewPath := fmt.Sprintf("%s/%d", reviewsPath, reviewID)
+
+	if got := res.header.Get("Location"); got != reviewPath {
+		t.Errorf("got Location %q; want %q", got, reviewPath)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "second review by the same user", method: http.MethodPost, path: reviewsPath, token: aliceToken, body: map[string]any{"rating": 3}, wantStatus: http.StatusConflict},
+		{name: "rating too high", method: http.MethodPost, path: reviewsPath, token: bobToken, body: map[string]any{"rating": 11}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "missing rating", method: http.MethodPost, path: reviewsPath, token: bobToken, body: map[string]any{"body": "No score."}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "review missing movie", method: http.MethodPost, path: "/v1/movies/999/reviews", token: bobToken, body: map[string]any{"rating": 5}, wantStatus: http.StatusNotFound},
+		{name: "rating only", method: http.MethodPost, path: reviewsPath, token: bobToken, body: map[string]any{"rating": 6}, wantStatus: http.StatusCreated},
+		{name: "show", method: http.MethodGet, path: reviewPath, token: bobToken, wantStatus: http.StatusOK},
+		{name: "show through another movie", method: http.MethodGet, path: fmt.Sprintf("/v1/movies/%d/reviews/%d", otherMovieID, reviewID), token: bobToken, wantStatus: http.StatusNotFound},
+		{name: "update by another user", method: http.MethodPatch, path: reviewPath, token: bobToken, body: map[string]any{"rating": 1}, wantStatus: http.StatusForbidden},
+		{name: "update without fields", method: http.MethodPatch, path: reviewPath, token: aliceToken, body: map[string]any{}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "update", method: http.MethodPatch, path: reviewPath, token: aliceToken, body: map[string]any{"rating": 10}, wantStatus: http.StatusOK},
+		{name: "delete by another user", method: http.MethodDelete, path: reviewPath, token: bobToken, wantStatus: http.StatusForbidden},
+		{name: "list with invalid sort", method: http.MethodGet, path: reviewsPath + "?sort=body", token: bobToken, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	t.Run("aggregates on movie", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieID), bobToken, nil)
+		movie := res.body["movie"].(map[string]any)
+		if movie["average_rating"] != 8.0 || movie["rating_count"] != 2.0 {
+			t.Errorf("got average_rating %v, rating_count %v; want 8, 2", movie["average_rating"], movie["rating_count"])
+		}
+	})
+
+	t.Run("sort movies by rating", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies?sort=-average_rating", bobToken, nil)
+		movies := res.body["movies"].([]any)
+		if len(movies) != 2 || movies[0].(map[string]any)["title"] != "Black Panther" {
+			t.Errorf("got movies %v; want Black Panther first", movies)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, reviewsPath+"?sort=-rating", bobToken, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+
+		var got []string
+		for _, review := range res.body["reviews"].([]any) {
+			review := review.(map[string]any)
+			got = append(got, fmt.Sprintf("%s:%v", review["user_name"], review["rating"]))
+		}
+		if want := []string{"Test User:10", "Test User:6"}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got reviews %q; want %q", got, want)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		moviePath := fmt.Sprintf("/v1/movies/%d", movieID)
+		etag := ts.do(t, http.MethodGet, moviePath, bobToken, nil).header.Get("ETag")
+
+		if res := ts.do(t, http.MethodDelete, reviewPath, aliceToken, nil); res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+
+		// the ratings changed, so the ETag taken before the delete is stale
+		res := ts.doWithHeader(t, http.MethodGet, moviePath, bobToken, http.Header{"If-None-Match": {etag}}, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d with the old ETag; want %d", res.status, http.StatusOK)
+		}
+		movie := res.body["movie"].(map[string]any)
+		if movie["average_rating"] != 6.0 || movie["rating_count"] != 1.0 {
+			t.Errorf("got average_rating %v, rating_count %v; want 6, 1", movie["average_rating"], movie["rating_count"])
+		}
+	})
+
+	t.Run("updating the movie keeps its rating", func(t *testing.T) {
+		path := fmt.Sprintf("/v1/movies/%d", movieID)
+		res := ts.do(t, http.MethodPatch, path, writerToken, map[string]any{"year": 2019})
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusOK)
+		}
+		if got := res.body["movie"].(map[string]any)["rating_count"]; got != 1.0 {
+			t.Errorf("got rating_count %v; want 1", got)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+func (app *application) listMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "-created_at")
+	input.SortSafeList = []string{"created_at", "version", "-created_at", "-version"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	revisions, metadata, err := app.models.Revisions.GetAllForMovie(r.Context(), id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertMovieHandler puts the fields of a movie back to how they were after the given
+// revision. The revert is an ordinary update, so it bumps the version, is subject to
+// the same optimistic locking (and If-Match header) and is recorded as a new revision.
+func (app *application) revertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	revisionID, err := app.readNamedIDParam(r, "revision_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.checkIfMatch(w, r, movie) {
+		return
+	}
+
+	revision, err := app.models.Revisions.Get(r.Context(), id, revisionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	before := *movie
+	revision.Snapshot.ApplyTo(movie)
+
+	// the genres of an old revision may have been renamed or deleted since
+	v := validator.New()
+
+	err = app.validateMovie(r.Context(), v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.Update(r.Context(), movie, app.newMovieRevision(r, data.RevisionRevert, &before, movie))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.movieConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// newMovieRevision describes the change from before to after, made by the user of the
+// request, for a model method to record along with the change itself.
+func (app *application) newMovieRevision(r *http.Request, action string, before, after *data.Movie) *data.MovieRevision {
+	revision := data.NewMovieRevision(action, before, after)
+	revision.UserID = app.contextGetUser(r).ID
+	return revision
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieRevisions(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action"}})
+	path := fmt.Sprintf("/v1/movies/%d", movieID)
+
+	ts.do(t, http.MethodPatch, path, token, map[string]any{"title": "Black Panther: Wakanda Forever", "year": 2022})
+	ts.do(t, http.MethodPatch, path, token, map[string]any{"runtime": "161 mins"})
+
+	res := ts.do(t, http.MethodGet, path+"/revisions", readerToken, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+	}
+
+	revisions := res.body["revisions"].([]any)
+	if len(revisions) != 3 {
+		t.Fatalf("got %d revisions; want 3", len(revisions))
+	}
+
+	// newest first
+	var actions []string
+	for _, revision := range revisions {
+		revision := revision.(map[string]any)
+		actions = append(actions, fmt.Sprintf("%s@%v", revision["action"], revision["version"]))
+	}
+	if want := []string{"update@3", "update@2", "insert@1"}; fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Errorf("got revisions %q; want %q", actions, want)
+	}
+
+	titleUpdate := revisions[1].(map[string]any)
+	changes := titleUpdate["changes"].(map[string]any)
+	if len(changes) != 2 {
+		t.Errorf("got changes %v; want title and year only", changes)
+	}
+	title := changes["title"].(map[string]any)
+	if title["old"] != "Black Panther" || title["new"] != "Black Panther: Wakanda Forever" {
+		t.Errorf("got title change %v", title)
+	}
+	if titleUpdate["user_id"] == nil {
+		t.Errorf("got no user_id on %v", titleUpdate)
+	}
+
+	insertID := int64(revisions[2].(map[string]any)["id"].(float64))
+	revertPath := fmt.Sprintf("%s/revisions/%d/revert", path, insertID)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "without permission", path: revertPath, token: readerToken, wantStatus: http.StatusForbidden},
+		{name: "missing revision", path: path + "/revisions/999/revert", token: token, wantStatus: http.StatusNotFound},
+		{name: "revision of another movie", path: fmt.Sprintf("/v1/movies/999/revisions/%d/revert", insertID), token: token, wantStatus: http.StatusNotFound},
+		{name: "stale If-Match", path: revertPath, token: token, header: http.Header{"If-Match": {fmt.Sprintf(`"%d-1"`, movieID)}}, wantStatus: http.StatusPreconditionFailed},
+		{name: "revert", path: revertPath, token: token, header: http.Header{"If-Match": {fmt.Sprintf(`"%d-3"`, movieID)}}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.doWithHeader(t, http.MethodPost, tt.path, tt.token, tt.header, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	t.Run("reverted movie", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, path, readerToken, nil)
+		movie := res.body["movie"].(map[string]any)
+		if movie["title"] != "Black Panther" || movie["runtime"] != "134 mins" || movie["version"] != 4.0 {
+			t.Errorf("got movie %v; want the original values at version 4", movie)
+		}
+
+		res = ts.do(t, http.MethodGet, path+"/revisions?sort=-version", readerToken, nil)
+		latest := res.body["revisions"].([]any)[0].(map[string]any)
+		if latest["action"] != "revert" || len(latest["changes"].(map[string]any)) != 3 {
+			t.Errorf("got latest revision %v; want a revert of title, year and runtime", latest)
+		}
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		ts.do(t, http.MethodDelete, path, token, nil)
+		ts.do(t, http.MethodPost, path+"/restore", token, nil)
+
+		res := ts.do(t, http.MethodGet, path+"/revisions?page_size=2", readerToken, nil)
+		var actions []string
+		for _, revision := range res.body["revisions"].([]any) {
+			actions = append(actions, revision.(map[string]any)["action"].(string))
+		}
+		if want := []string{"restore", "delete"}; fmt.Sprint(actions) != fmt.Sprint(want) {
+			t.Errorf("got latest revisions %q; want %q", actions, want)
+		}
+	})
+	t.Run("revert to a deleted genre", func(t *testing.T) {
+		adminToken := ts.newActivatedUser(t, "admin@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.MoviesAdmin)
+		ts.do(t, http.MethodPost, "/v1/genres", adminToken, map[string]any{"slug": "afrofuturism", "name": "Afrofuturism"})
+
+		movieID := ts.createTestMovie(t, token, map[string]any{"title": "Space Is the Place", "year": 1974, "runtime": "82 mins", "genres": []string{"afrofuturism"}})
+		path := fmt.Sprintf("/v1/movies/%d", movieID)
+		ts.do(t, http.MethodPatch, path, token, map[string]any{"genres": []string{"sci-fi"}})
+		ts.do(t, http.MethodDelete, "/v1/genres/afrofuturism", adminToken, nil)
+
+		res := ts.do(t, http.MethodGet, path+"/revisions?sort=version", readerToken, nil)
+		insertID := int64(res.body["revisions"].([]any)[0].(map[string]any)["id"].(float64))
+
+		res = ts.do(t, http.MethodPost, fmt.Sprintf("%s/revisions/%d/revert", path, insertID), token, nil)
+		if res.status != http.StatusUnprocessableEntity {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusUnprocessableEntity, res.body)
+		}
+		if _, ok := res.validationErrors()["genres"]; !ok {
+			t.Errorf("got errors %v; want one for genres", res.validationErrors())
+		}
+	})
+}
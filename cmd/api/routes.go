@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/storage"
 )
 
 func (app *application) routes() http.Handler {
@@ -17,13 +18,122 @@ func (app *application) routes() http.Handler {
 	router.Group(func(movieRouter chi.Router) {
 		movieRouter.Use(app.requireActivatedUser)
 
+		movieRouter.Get("/v1/movies/export", app.requirePermission(data.PermissionsCode.MoviesExport, app.exportMoviesHandler))
+		movieRouter.Get("/v1/movies/suggest", app.requirePermission(data.PermissionsCode.MoviesRead, app.suggestMoviesHandler))
+		movieRouter.Get("/v1/movies/lookup", app.requirePermission(data.PermissionsCode.MoviesRead, app.lookupMovieHandler))
+		movieRouter.Get("/v1/movies/trash", app.requirePermission(data.PermissionsCode.MoviesAdmin, app.listTrashHandler))
 		movieRouter.Get("/v1/movies/{id}", app.requirePermission(data.PermissionsCode.MoviesRead, app.showMovieHandler))
 		movieRouter.Get("/v1/movies", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMoviesHandler))
 		movieRouter.Post("/v1/movies", app.requirePermission(data.PermissionsCode.MoviesWrite, app.createMovieHandler))
+		movieRouter.Post("/v1/movies/import", app.requirePermission(data.PermissionsCode.MoviesWrite, app.importMoviesHandler))
+		movieRouter.Post("/v1/movies/batch", app.requirePermission(data.PermissionsCode.MoviesWrite, app.batchMoviesHandler))
 		movieRouter.Patch("/v1/movies/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.updateMovieHandler))
+		movieRouter.Put("/v1/movies/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.replaceMovieHandler))
 		movieRouter.Delete("/v1/movies/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteMovieHandler))
+		movieRouter.Post("/v1/movies/{id}/restore", app.requirePermission(data.PermissionsCode.MoviesWrite, app.restoreMovieHandler))
+		movieRouter.Post("/v1/movies/{id}/merge", app.requirePermission(data.PermissionsCode.MoviesWrite, app.mergeMoviesHandler))
+
+		// similar movies and recommendations
+		movieRouter.Get("/v1/movies/{id}/similar", app.requirePermission(data.PermissionsCode.MoviesRead, app.similarMoviesHandler))
+		movieRouter.Get("/v1/users/me/recommendations", app.requirePermission(data.PermissionsCode.MoviesRead, app.recommendationsHandler))
+
+		// stats
+		movieRouter.Get("/v1/stats/movies", app.requirePermission(data.PermissionsCode.MoviesRead, app.movieStatsHandler))
+
+		// external ids
+		movieRouter.Get("/v1/movies/{id}/external-ids", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieExternalIDsHandler))
+		movieRouter.Put("/v1/movies/{id}/external-ids/{source}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.setMovieExternalIDHandler))
+		movieRouter.Delete("/v1/movies/{id}/external-ids/{source}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteMovieExternalIDHandler))
+
+		// localized titles and releases
+		movieRouter.Get("/v1/movies/{id}/titles", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieTitlesHandler))
+		movieRouter.Put("/v1/movies/{id}/titles/{locale}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.setMovieTitleHandler))
+		movieRouter.Delete("/v1/movies/{id}/titles/{locale}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteMovieTitleHandler))
+		movieRouter.Get("/v1/movies/{id}/releases", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieReleasesHandler))
+		movieRouter.Put("/v1/movies/{id}/releases/{country}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.setMovieReleaseHandler))
+		movieRouter.Delete("/v1/movies/{id}/releases/{country}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteMovieReleaseHandler))
+
+		// posters and backdrops
+		movieRouter.Get("/v1/movies/{id}/images", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieImagesHandler))
+		movieRouter.Post("/v1/movies/{id}/images", app.requirePermission(data.PermissionsCode.MoviesWrite, app.uploadMovieImageHandler))
+		movieRouter.Get("/v1/movies/{id}/images/{image_id}", app.requirePermission(data.PermissionsCode.MoviesRead, app.showMovieImageHandler))
+		movieRouter.Delete("/v1/movies/{id}/images/{image_id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteMovieImageHandler))
+
+		// revision history
+		movieRouter.Get("/v1/movies/{id}/revisions", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieRevisionsHandler))
+		movieRouter.Post("/v1/movies/{id}/revisions/{revision_id}/revert", app.requirePermission(data.PermissionsCode.MoviesWrite, app.revertMovieHandler))
+
+		// cast and crew
+		movieRouter.Get("/v1/movies/{id}/credits", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieCreditsHandler))
+		movieRouter.Post("/v1/movies/{id}/credits", app.requirePermission(data.PermissionsCode.MoviesWrite, app.createMovieCreditHandler))
+		movieRouter.Delete("/v1/movies/{id}/credits/{credit_id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteMovieCreditHandler))
+
+		// ratings and reviews
+		movieRouter.Get("/v1/movies/{id}/reviews", app.requirePermission(data.PermissionsCode.MoviesRead, app.listMovieReviewsHandler))
+		movieRouter.Post("/v1/movies/{id}/reviews", app.requirePermission(data.PermissionsCode.ReviewsWrite, app.createMovieReviewHandler))
+		movieRouter.Get("/v1/movies/{id}/reviews/{review_id}", app.requirePermission(data.PermissionsCode.MoviesRead, app.showMovieReviewHandler))
+		movieRouter.Patch("/v1/movies/{id}/reviews/{review_id}", app.requirePermission(data.PermissionsCode.ReviewsWrite, app.updateMovieReviewHandler))
+		movieRouter.Delete("/v1/movies/{id}/reviews/{review_id}", app.requirePermission(data.PermissionsCode.ReviewsWrite, app.deleteMovieReviewHandler))
+	})
+
+	// people
+	router.Group(func(peopleRouter chi.Router) {
+		peopleRouter.Use(app.requireActivatedUser)
+
+		peopleRouter.Get("/v1/people", app.requirePermission(data.PermissionsCode.MoviesRead, app.listPeopleHandler))
+		peopleRouter.Get("/v1/people/{id}", app.requirePermission(data.PermissionsCode.MoviesRead, app.showPersonHandler))
+		peopleRouter.Post("/v1/people", app.requirePermission(data.PermissionsCode.MoviesWrite, app.createPersonHandler))
+		peopleRouter.Patch("/v1/people/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.updatePersonHandler))
+		peopleRouter.Delete("/v1/people/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deletePersonHandler))
 	})
 
+	// genre taxonomy
+	router.Group(func(genreRouter chi.Router) {
+		genreRouter.Use(app.requireActivatedUser)
+
+		genreRouter.Get("/v1/genres", app.requirePermission(data.PermissionsCode.MoviesRead, app.listGenresHandler))
+		genreRouter.Get("/v1/genres/{slug}", app.requirePermission(data.PermissionsCode.MoviesRead, app.showGenreHandler))
+		genreRouter.Post("/v1/genres", app.requirePermission(data.PermissionsCode.MoviesAdmin, app.createGenreHandler))
+		genreRouter.Patch("/v1/genres/{slug}", app.requirePermission(data.PermissionsCode.MoviesAdmin, app.updateGenreHandler))
+		genreRouter.Delete("/v1/genres/{slug}", app.requirePermission(data.PermissionsCode.MoviesAdmin, app.deleteGenreHandler))
+	})
+
+	// collections
+	router.Group(func(collectionRouter chi.Router) {
+		collectionRouter.Use(app.requireActivatedUser)
+
+		collectionRouter.Get("/v1/collections", app.requirePermission(data.PermissionsCode.MoviesRead, app.listCollectionsHandler))
+		collectionRouter.Get("/v1/collections/{id}", app.requirePermission(data.PermissionsCode.MoviesRead, app.showCollectionHandler))
+		collectionRouter.Post("/v1/collections", app.requirePermission(data.PermissionsCode.MoviesWrite, app.createCollectionHandler))
+		collectionRouter.Patch("/v1/collections/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.updateCollectionHandler))
+		collectionRouter.Delete("/v1/collections/{id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.deleteCollectionHandler))
+		collectionRouter.Get("/v1/collections/{id}/movies", app.requirePermission(data.PermissionsCode.MoviesRead, app.listCollectionMoviesHandler))
+		collectionRouter.Post("/v1/collections/{id}/movies", app.requirePermission(data.PermissionsCode.MoviesWrite, app.addCollectionMovieHandler))
+		collectionRouter.Patch("/v1/collections/{id}/movies/{movie_id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.moveCollectionMovieHandler))
+		collectionRouter.Delete("/v1/collections/{id}/movies/{movie_id}", app.requirePermission(data.PermissionsCode.MoviesWrite, app.removeCollectionMovieHandler))
+	})
+
+	// watchlists and custom lists
+	router.Group(func(listRouter chi.Router) {
+		listRouter.Use(app.requireActivatedUser)
+
+		listRouter.Get("/v1/users/me/lists", app.listUserListsHandler)
+		listRouter.Post("/v1/lists", app.createListHandler)
+		listRouter.Get("/v1/lists/{id}", app.showListHandler)
+		listRouter.Patch("/v1/lists/{id}", app.updateListHandler)
+		listRouter.Delete("/v1/lists/{id}", app.deleteListHandler)
+		listRouter.Get("/v1/lists/{id}/movies", app.listListMoviesHandler)
+		listRouter.Post("/v1/lists/{id}/movies", app.addListMovieHandler)
+		listRouter.Patch("/v1/lists/{id}/movies/{movie_id}", app.moveListMovieHandler)
+		listRouter.Delete("/v1/lists/{id}/movies/{movie_id}", app.removeListMovieHandler)
+	})
+
+	// uploaded images are public, so that they can be linked to; they're only served
+	// from here when they are kept on the local filesystem
+	if _, ok := app.storage.(*storage.Local); ok {
+		router.Get("/v1/images/*", app.serveImageHandler)
+	}
+
 	// users
 	router.Post("/v1/users", app.registerUserHandler)
 	router.Post("/v1/users/verification", app.sendActivationTokenHandler)
@@ -12,18 +12,32 @@ import (
 	"time"
 )
 
+// The server timeouts suit requests with small JSON bodies. Handlers that read or write
+// a lot more than that push their own deadlines back.
+const (
+	serverReadTimeout  = 5 * time.Second
+	serverWriteTimeout = 10 * time.Second
+)
+
 func (app *application) serve() error {
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.port),
 		Handler:      app.routes(),
 		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
 	shutdownErrorChan := make(chan error)
 
+	// the trash purge runs as a background task so that shutdown waits for it to stop
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	app.background(func() {
+		app.purgeTrash(purgeCtx)
+	})
+
 	// start a background goroutine.
 	go func() {
 		// create a quit channel which carries os.Signal values.
@@ -51,6 +65,8 @@ func (app *application) serve() error {
 		}
 		app.logger.Info("completing background tasks", "addr", srv.Addr)
 
+		stopPurge()
+
 		app.wg.Wait()
 		shutdownErrorChan <- nil
 	}()
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+// maxStatsCacheEntries caps the number of filter combinations the stats are cached for,
+// since every title searched for gets its own entry.
+const maxStatsCacheEntries = 1000
+
+// statsCache keeps computed movie stats for the configured TTL, keyed by the filters
+// they were computed for. The zero value is an empty cache.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	stats   *data.MovieStats
+	expires time.Time
+}
+
+// get returns the stats cached for the key, unless they have expired.
+func (c *statsCache) get(key string) (*data.MovieStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.stats, true
+}
+
+// put caches the stats for the key until the TTL runs out. Expired entries are dropped
+// first, and the stats aren't cached at all when the cache is still full.
+func (c *statsCache) put(key string, stats *data.MovieStats, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]statsCacheEntry)
+	}
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) >= maxStatsCacheEntries {
+		return
+	}
+
+	c.entries[key] = statsCacheEntry{stats: stats, expires: now.Add(ttl)}
+}
+
+// statsCacheKey identifies the movies the filters match, so that the same genres in a
+// different order, or a genres_match without genres, share an entry.
+func statsCacheKey(f data.MovieFilters) string {
+	if len(f.Genres) == 0 {
+		return fmt.Sprintf("%q", f.Title)
+	}
+
+	genres := slices.Clone(f.Genres)
+	slices.Sort(genres)
+	return fmt.Sprintf("%q %s %s", f.Title, f.GenresMatch, strings.Join(genres, ","))
+}
+
+// movieStatsHandler sums up the movies matching the title and genres filters of the
+// listing. The stats are cached for -stats-cache-ttl, so they can be that much out of
+// date; generated_at says when they were computed.
+func (app *application) movieStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var input data.MovieFilters
+
+	err := app.readTitleAndGenres(r.Context(), r.URL.Query(), &input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(validator.PermittedValue(input.GenresMatch, data.GenresMatchAll, data.GenresMatchAny), "genres_match", "must be all or any"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ttl := app.config.stats.cacheTTL
+	key := statsCacheKey(input)
+
+	stats, ok := app.stats.get(key)
+	if !ok || ttl <= 0 {
+		stats, err = app.models.Movies.Stats(r.Context(), input)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if ttl > 0 {
+			app.stats.put(key, stats, ttl)
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stats": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
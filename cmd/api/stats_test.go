@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieStats(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.ReviewsWrite)
+
+	matrixID := ts.createTestMovie(t, token, map[string]any{"title": "The Matrix", "year": 1999, "runtime": "136 mins", "genres": []string{"sci-fi", "action"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Blade Runner", "year": 1982, "runtime": "117 mins", "genres": []string{"sci-fi", "drama"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Notting Hill", "year": 1999, "runtime": "124 mins", "genres": []string{"romance", "comedy"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Die Hard", "year": 1988, "runtime": "132 mins", "genres": []string{"action", "thriller"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "The Lunch Date", "year": 1989, "runtime": "12 mins", "genres": []string{"drama"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "Lawrence of Arabia", "year": 1962, "runtime": "222 mins", "genres": []string{"adventure", "drama"}})
+	trashedID := ts.createTestMovie(t, token, map[string]any{"title": "The Matrix Revolutions", "year": 2003, "runtime": "129 mins", "genres": []string{"sci-fi", "action"}})
+	if res := ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", trashedID), token, nil); res.status != http.StatusOK {
+		t.Fatalf("deleting movie: got status %d", res.status)
+	}
+	if res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/reviews", matrixID), token, map[string]any{"rating": 9}); res.status != http.StatusCreated {
+		t.Fatalf("reviewing movie: got status %d, body %v", res.status, res.body)
+	}
+
+	stats := func(t *testing.T, path string) map[string]any {
+		t.Helper()
+
+		res := ts.do(t, http.MethodGet, path, token, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+		return res.body["stats"].(map[string]any)
+	}
+
+	t.Run("all movies", func(t *testing.T) {
+		got := stats(t, "/v1/stats/movies")
+
+		wantTotals := map[string]any{"movies": 6.0, "rated_movies": 1.0, "ratings": 1.0}
+		if !reflect.DeepEqual(got["totals"], wantTotals) {
+			t.Errorf("got totals %v; want %v", got["totals"], wantTotals)
+		}
+
+		wantDecades := []any{
+			map[string]any{"value": 1960.0, "count": 1.0},
+			map[string]any{"value": 1980.0, "count": 3.0},
+			map[string]any{"value": 1990.0, "count": 2.0},
+		}
+		if !reflect.DeepEqual(got["decades"], wantDecades) {
+			t.Errorf("got decades %v; want %v", got["decades"], wantDecades)
+		}
+
+		if first := got["genres"].([]any)[0]; !reflect.DeepEqual(first, map[string]any{"value": "drama", "count": 3.0}) {
+			t.Errorf("got most common genre %v; want drama with 3 movies", first)
+		}
+
+		runtime := got["runtime"].(map[string]any)
+		if runtime["min"] != "12 mins" || runtime["max"] != "222 mins" || runtime["average"] != "124 mins" {
+			t.Errorf("got runtimes from %v to %v, averaging %v; want from 12 mins to 222 mins, averaging 124 mins", runtime["min"], runtime["max"], runtime["average"])
+		}
+
+		var counts []float64
+		for _, bucket := range runtime["histogram"].([]any) {
+			counts = append(counts, bucket.(map[string]any)["count"].(float64))
+		}
+		if want := []float64{1, 0, 0, 1, 3, 0, 1}; !reflect.DeepEqual(counts, want) {
+			t.Errorf("got runtime histogram %v; want %v", counts, want)
+		}
+		last := runtime["histogram"].([]any)[6].(map[string]any)
+		if _, ok := last["max"]; ok || last["min"] != "180 mins" {
+			t.Errorf("got last runtime bucket %v; want it open-ended from 180 mins", last)
+		}
+
+		weeks := got["recently_added"].([]any)
+		if len(weeks) != 12 {
+			t.Fatalf("got %d weeks recently added; want 12", len(weeks))
+		}
+		if this := weeks[11].(map[string]any)["count"]; this != 6.0 {
+			t.Errorf("got %v movies added this week; want 6", this)
+		}
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		tests := []struct {
+			path       string
+			wantMovies float64
+		}{
+			{path: "/v1/stats/movies?genres=sci-fi", wantMovies: 2},
+			{path: "/v1/stats/movies?genres=sci-fi,romance&genres_match=any", wantMovies: 3},
+			{path: "/v1/stats/movies?title=matrix", wantMovies: 1},
+			{path: "/v1/stats/movies?title=casablanca", wantMovies: 0},
+		}
+
+		for _, tt := range tests {
+			got := stats(t, tt.path)
+			if movies := got["totals"].(map[string]any)["movies"]; movies != tt.wantMovies {
+				t.Errorf("%s: got %v movies; want %v", tt.path, movies, tt.wantMovies)
+			}
+		}
+	})
+
+	t.Run("invalid genres match", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/stats/movies?genres=drama&genres_match=some", token, nil)
+		if res.status != http.StatusUnprocessableEntity {
+			t.Fatalf("got status %d; want %d", res.status, http.StatusUnprocessableEntity)
+		}
+		if _, ok := res.validationErrors()["genres_match"]; !ok {
+			t.Errorf("got errors %v; want one for genres_match", res.validationErrors())
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		before := stats(t, "/v1/stats/movies?genres=drama,sci-fi&genres_match=any")
+		ts.createTestMovie(t, token, map[string]any{"title": "Gattaca", "year": 1997, "runtime": "106 mins", "genres": []string{"sci-fi", "drama"}})
+
+		after := stats(t, "/v1/stats/movies?genres=sci-fi,drama&genres_match=any")
+		if !reflect.DeepEqual(after, before) {
+			t.Errorf("got stats %v; want the cached %v", after, before)
+		}
+
+		ts.app.config.stats.cacheTTL = 0
+		defer func() { ts.app.config.stats.cacheTTL = time.Minute }()
+
+		after = stats(t, "/v1/stats/movies?genres=sci-fi,drama&genres_match=any")
+		if movies := after["totals"].(map[string]any)["movies"]; movies != 5.0 {
+			t.Errorf("got %v movies with the cache disabled; want 5", movies)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/stats/movies", "", nil)
+		if res.status != http.StatusUnauthorized {
+			t.Errorf("got status %d; want %d", res.status, http.StatusUnauthorized)
+		}
+	})
+}
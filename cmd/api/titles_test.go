@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieTitles(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	readerToken := ts.newActivatedUser(t, "reader@example.com")
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Spirited Away", "year": 2001, "runtime": "125 mins", "genres": []string{"animation"}})
+	path := fmt.Sprintf("/v1/movies/%d/titles", movieID)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "set without permission", method: http.MethodPut, path: path + "/fr", token: readerToken, body: map[string]any{"title": "Le Voyage de Chihiro"}, wantStatus: http.StatusForbidden},
+		{name: "set invalid locale", method: http.MethodPut, path: path + "/french", token: token, body: map[string]any{"title": "Le Voyage de Chihiro"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set empty title", method: http.MethodPut, path: path + "/fr", token: token, body: map[string]any{"title": ""}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "set for missing movie", method: http.MethodPut, path: "/v1/movies/999/titles/fr", token: token, body: map[string]any{"title": "Le Voyage de Chihiro"}, wantStatus: http.StatusNotFound},
+		{name: "set", method: http.MethodPut, path: path + "/fr", token: token, body: map[string]any{"title": "Le Voyage de Chihiro"}, wantStatus: http.StatusOK},
+		{name: "set with region in lowercase", method: http.MethodPut, path: path + "/pt-br", token: token, body: map[string]any{"title": "A Viagem de Chihiro"}, wantStatus: http.StatusOK},
+		{name: "set another", method: http.MethodPut, path: path + "/de", token: token, body: map[string]any{"title": "Chihiros Reise ins Zauberland"}, wantStatus: http.StatusOK},
+		{name: "list", method: http.MethodGet, path: path, token: readerToken, wantStatus: http.StatusOK},
+		{name: "delete", method: http.MethodDelete, path: path + "/de", token: token, wantStatus: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: path + "/de", token: token, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=titles", movieID), readerToken, nil)
+	titles := res.body["movie"].(map[string]any)["titles"].([]any)
+	if len(titles) != 2 || titles[1].(map[string]any)["locale"] != "pt-BR" {
+		t.Errorf("got titles %v; want fr and pt-BR", titles)
+	}
+}
+
+func TestDisplayTitle(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Spirited Away", "year": 2001, "runtime": "125 mins", "genres": []string{"animation"}})
+	for locale, title := range map[string]string{"fr": "Le Voyage de Chihiro", "pt-BR": "A Viagem de Chihiro", "ja": "Sen to Chihiro no Kamikakushi"} {
+		res := ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/titles/%s", movieID, locale), token, map[string]any{"title": title})
+		if res.status != http.StatusOK {
+			t.Fatalf("setting title: got status %d, body %v", res.status, res.body)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{name: "no header", acceptLanguage: "", want: ""},
+		{name: "exact match", acceptLanguage: "fr", want: "Le Voyage de Chihiro"},
+		{name: "region falls back to language", acceptLanguage: "fr-CA", want: "Le Voyage de Chihiro"},
+		{name: "language picks a region", acceptLanguage: "pt", want: "A Viagem de Chihiro"},
+		{name: "quality order", acceptLanguage: "fr;q=0.5, ja", want: "Sen to Chihiro no Kamikakushi"},
+		{name: "unknown language", acceptLanguage: "es, en;q=0.8", want: ""},
+		{name: "refused language", acceptLanguage: "fr;q=0, es", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			if tt.acceptLanguage != "" {
+				header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			res := ts.doWithHeader(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieID), token, header, nil)
+			movie := res.body["movie"].(map[string]any)
+			if got, _ := movie["display_title"].(string); got != tt.want {
+				t.Errorf("got display_title %q; want %q", got, tt.want)
+			}
+			if movie["title"] != "Spirited Away" {
+				t.Errorf("got title %v; want the original title", movie["title"])
+			}
+			// the ETag doesn't cover the alternate titles
+			if hasETag := res.header.Get("ETag") != ""; hasETag != (tt.want == "") {
+				t.Errorf("got ETag %q with display_title %q", res.header.Get("ETag"), tt.want)
+			}
+
+			res = ts.doWithHeader(t, http.MethodGet, "/v1/movies?fields=id,title", token, header, nil)
+			movie = res.body["movies"].([]any)[0].(map[string]any)
+			if got, _ := movie["display_title"].(string); got != tt.want {
+				t.Errorf("got display_title %q in the listing; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchAlternateTitles(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Spirited Away", "year": 2001, "runtime": "125 mins", "genres": []string{"animation"}})
+	ts.createTestMovie(t, token, map[string]any{"title": "My Neighbor Totoro", "year": 1988, "runtime": "86 mins", "genres": []string{"animation"}})
+	res := ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/titles/fr", movieID), token, map[string]any{"title": "Le Voyage de Chihiro"})
+	if res.status != http.StatusOK {
+		t.Fatalf("setting title: got status %d, body %v", res.status, res.body)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "title filter", path: "/v1/movies?title=chihiro", want: 1},
+		{name: "title filter on the original title", path: "/v1/movies?title=spirited", want: 1},
+		{name: "full-text search", path: "/v1/movies?q=voyage+chihiro&language=simple", want: 1},
+		{name: "fuzzy search", path: "/v1/movies?q=voyage+de+chihro&search_mode=fuzzy", want: 1},
+		{name: "no match", path: "/v1/movies?title=ponyo", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, tt.path, token, nil)
+			if res.status != http.StatusOK {
+				t.Fatalf("got status %d (body %v)", res.status, res.body)
+			}
+			if got := len(res.body["movies"].([]any)); got != tt.want {
+				t.Errorf("got %d movies; want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCreateAuthenticationToken(t *testing.T) {
+	ts := newTestServer(t)
+	ts.newActivatedUser(t, "alice@example.com")
+
+	tests := []struct {
+		name       string
+		email      string
+		password   string
+		wantStatus int
+	}{
+		{name: "valid credentials", email: "alice@example.com", password: "pa55word1234", wantStatus: http.StatusCreated},
+		{name: "email is case-insensitive", email: "ALICE@example.com", password: "pa55word1234", wantStatus: http.StatusCreated},
+		{name: "wrong password", email: "alice@example.com", password: "wrongpa55word", wantStatus: http.StatusUnauthorized},
+		{name: "unknown email", email: "bob@example.com", password: "pa55word1234", wantStatus: http.StatusUnauthorized},
+		{name: "invalid email", email: "alice", password: "pa55word1234", wantStatus: http.StatusUnprocessableEntity},
+		{name: "missing password", email: "alice@example.com", password: "", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", map[string]string{
+				"email":    tt.email,
+				"password": tt.password,
+			})
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			token := res.body["authentication_token"].(map[string]any)
+			if plaintext, _ := token["token"].(string); len(plaintext) != 26 {
+				t.Errorf("got token %q; want 26 characters", plaintext)
+			}
+			if _, ok := token["expiry"]; !ok {
+				t.Error("missing token expiry")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Movies.Restore(r.Context(), id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.SortSafeList = []string{"deleted_at", "title", "-deleted_at", "-title"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAllDeleted(r.Context(), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTrash permanently deletes the movies that have been in the trash for longer than
+// the configured retention, once straight away and then on every purge interval, until
+// ctx is cancelled. It does nothing if either the retention or the interval is zero.
+func (app *application) purgeTrash(ctx context.Context) {
+	retention, interval := app.config.movies.trashRetention, app.config.movies.trashPurgeInterval
+	if retention <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := app.purgeDeleted(ctx, time.Now().Add(-retention))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			app.logger.Error("purging trash", "error", err.Error())
+		case purged > 0:
+			app.logger.Info("purged trash", "movies", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The purgeDeleted() helper permanently deletes the movies that were moved to the trash
+// before the given time, and the files of their images, which the database doesn't
+// know how to delete.
+func (app *application) purgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	images, err := app.models.Images.GetAllDeletedBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := app.models.Movies.PurgeDeleted(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, image := range images {
+		app.deleteImageFiles(image)
+	}
+	return purged, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/data"
+)
+
+func TestMovieTrash(t *testing.T) {
+	ts := newTestServer(t)
+	writerToken := ts.newActivatedUser(t, "writer@example.com", data.PermissionsCode.MoviesWrite)
+	adminToken := ts.newActivatedUser(t, "admin@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.MoviesAdmin)
+
+	movieID := ts.createTestMovie(t, writerToken, map[string]any{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action"}})
+	ts.createTestMovie(t, writerToken, map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	path := fmt.Sprintf("/v1/movies/%d", movieID)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{name: "delete", method: http.MethodDelete, path: path, token: writerToken, wantStatus: http.StatusOK},
+		{name: "show deleted", method: http.MethodGet, path: path, token: writerToken, wantStatus: http.StatusNotFound},
+		{name: "delete again", method: http.MethodDelete, path: path, token: writerToken, wantStatus: http.StatusNotFound},
+		{name: "update deleted", method: http.MethodPatch, path: path, token: writerToken, wantStatus: http.StatusNotFound},
+		{name: "trash without admin permission", method: http.MethodGet, path: "/v1/movies/trash", token: writerToken, wantStatus: http.StatusForbidden},
+		{name: "trash", method: http.MethodGet, path: "/v1/movies/trash", token: adminToken, wantStatus: http.StatusOK},
+		{name: "trash with invalid sort", method: http.MethodGet, path: "/v1/movies/trash?sort=year", token: adminToken, wantStatus: http.StatusUnprocessableEntity},
+		{name: "restore missing movie", method: http.MethodPost, path: "/v1/movies/999/restore", token: writerToken, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, tt.token, nil)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+		})
+	}
+
+	t.Run("hidden from listing", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies", writerToken, nil)
+		movies := res.body["movies"].([]any)
+		if len(movies) != 1 || movies[0].(map[string]any)["title"] != "Moana" {
+			t.Errorf("got movies %v; want only Moana", movies)
+		}
+	})
+
+	t.Run("listed in trash", func(t *testing.T) {
+		res := ts.do(t, http.MethodGet, "/v1/movies/trash", adminToken, nil)
+		movies := res.body["movies"].([]any)
+		if len(movies) != 1 {
+			t.Fatalf("got %d movies in the trash; want 1", len(movies))
+		}
+		if movies[0].(map[string]any)["deleted_at"] == nil {
+			t.Errorf("got no deleted_at in %v", movies[0])
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		res := ts.do(t, http.MethodPost, path+"/restore", writerToken, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("got status %d; want %d (body %v)", res.status, http.StatusOK, res.body)
+		}
+		if _, ok := res.body["movie"].(map[string]any)["deleted_at"]; ok {
+			t.Errorf("got deleted_at on a restored movie")
+		}
+		if version := res.body["movie"].(map[string]any)["version"]; version != 2.0 {
+			t.Errorf("got version %v on a restored movie; want 2", version)
+		}
+
+		// an ETag from before the delete no longer matches
+		header := http.Header{"If-Match": {fmt.Sprintf(`"%d-1"`, movieID)}}
+		if res := ts.doWithHeader(t, http.MethodPatch, path, writerToken, header, map[string]any{"title": "Black Panther II"}); res.status != http.StatusPreconditionFailed {
+			t.Errorf("update with pre-delete If-Match: got status %d; want %d", res.status, http.StatusPreconditionFailed)
+		}
+
+		if res := ts.do(t, http.MethodGet, path, writerToken, nil); res.status != http.StatusOK {
+			t.Errorf("show after restore: got status %d; want %d", res.status, http.StatusOK)
+		}
+		if res := ts.do(t, http.MethodPost, path+"/restore", writerToken, nil); res.status != http.StatusNotFound {
+			t.Errorf("restoring again: got status %d; want %d", res.status, http.StatusNotFound)
+		}
+	})
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.newActivatedUser(t, "admin@example.com", data.PermissionsCode.MoviesWrite, data.PermissionsCode.MoviesAdmin)
+
+	movieID := ts.createTestMovie(t, token, map[string]any{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": []string{"action"}})
+	if res := ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", movieID), token, nil); res.status != http.StatusOK {
+		t.Fatalf("deleting movie: got status %d", res.status)
+	}
+
+	ts.app.config.movies.trashRetention = time.Nanosecond
+	ts.app.config.movies.trashPurgeInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ts.app.purgeTrash(ctx)
+		close(done)
+	}()
+
+	// the first purge runs straight away
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res := ts.do(t, http.MethodGet, "/v1/movies/trash", token, nil)
+		if len(res.body["movies"].([]any)) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("movie was not purged from the trash")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	res := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/restore", movieID), token, nil)
+	if res.status != http.StatusNotFound {
+		t.Errorf("restoring a purged movie: got status %d; want %d", res.status, http.StatusNotFound)
+	}
+}
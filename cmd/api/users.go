@@ -50,7 +50,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Users.Insert(user)
+	err = app.models.Users.Insert(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -63,13 +63,14 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Permissions.AddForUser(user.ID, data.PermissionsCode.MoviesRead)
+	err = app.models.Permissions.AddForUser(r.Context(), user.ID, data.PermissionsCode.MoviesRead, data.PermissionsCode.ReviewsWrite)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
 	token, err := app.models.Tokens.New(
+		r.Context(),
 		user.ID,
 		3*24*time.Hour,
 		data.ScopeActivation,
@@ -115,7 +116,7 @@ func (app *application) sendActivationTokenHandler(w http.ResponseWriter, r *htt
 	}
 	// should protect abuse
 	// we already have rate limiting
-	user, err := app.models.Users.GetByEmail(input.Email)
+	user, err := app.models.Users.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -131,6 +132,7 @@ func (app *application) sendActivationTokenHandler(w http.ResponseWriter, r *htt
 	if !user.Activated {
 
 		token, err := app.models.Tokens.New(
+			r.Context(),
 			user.ID,
 			3*24*time.Hour,
 			data.ScopeActivation,
@@ -177,7 +179,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopeActivation, input.TokenPlaintext)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -192,7 +194,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 	user.Activated = true
 
-	err = app.models.Users.Update(user)
+	err = app.models.Users.Update(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -203,7 +205,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+	err = app.models.Tokens.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -232,7 +234,7 @@ func (app *application) passwordResetHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	user, err := app.models.Users.GetByEmail(input.Email)
+	user, err := app.models.Users.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -252,6 +254,7 @@ func (app *application) passwordResetHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	token, err := app.models.Tokens.New(
+		r.Context(),
 		user.ID,
 		15*time.Minute,
 		data.ScopePasswordReset,
@@ -302,7 +305,7 @@ func (app *application) updatePasswordHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, input.PasswordResetToken)
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopePasswordReset, input.PasswordResetToken)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -320,7 +323,7 @@ func (app *application) updatePasswordHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err = app.models.Users.Update(user)
+	err = app.models.Users.Update(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -330,7 +333,7 @@ func (app *application) updatePasswordHandler(w http.ResponseWriter, r *http.Req
 		}
 		return
 	}
-	err = app.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+	err = app.models.Tokens.DeleteAllForUser(r.Context(), data.ScopePasswordReset, user.ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
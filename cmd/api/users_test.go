@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterUser(t *testing.T) {
+	ts := newTestServer(t)
+	ts.registerUser(t, "alice@example.com", "pa55word1234")
+
+	tests := []struct {
+		name        string
+		body        any
+		wantStatus  int
+		wantErrKeys []string
+	}{
+		{
+			name:       "valid user",
+			body:       map[string]string{"name": "Bob", "email": "bob@example.com", "password": "pa55word1234"},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:        "duplicate email",
+			body:        map[string]string{"name": "Alice", "email": "alice@example.com", "password": "pa55word1234"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrKeys: []string{"email"},
+		},
+		{
+			name:        "missing fields",
+			body:        map[string]string{"email": "not-an-email", "password": "short"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrKeys: []string{"name", "email", "password"},
+		},
+		{
+			name:       "unknown field",
+			body:       map[string]string{"name": "Carol", "email": "carol@example.com", "password": "pa55word1234", "admin": "yes"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "badly-formed JSON",
+			body:       `{"name": "Carol",`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty body",
+			body:       "",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multiple JSON values",
+			body:       `{"name": "Carol"}{"name": "Dan"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/users", "", tt.body)
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			for _, key := range tt.wantErrKeys {
+				if _, ok := res.validationErrors()[key]; !ok {
+					t.Errorf("missing validation error for %q in %v", key, res.body)
+				}
+			}
+		})
+	}
+
+	t.Run("duplicate email notifies the owner", func(t *testing.T) {
+		ts.app.wg.Wait()
+		if _, ok := ts.mailer.last("alice@example.com", "suspicious_login.tmpl"); !ok {
+			t.Error("no suspicious login email was sent")
+		}
+	})
+}
+
+func TestActivateUser(t *testing.T) {
+	ts := newTestServer(t)
+	token := ts.registerUser(t, "alice@example.com", "pa55word1234")
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "malformed token", token: "abc", wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown token", token: "ABCDEFGHIJKLMNOPQRSTUVWXYZ", wantStatus: http.StatusUnprocessableEntity},
+		{name: "valid token", token: token, wantStatus: http.StatusOK},
+		{name: "token already used", token: token, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPut, "/v1/users/activated", "", map[string]string{"token": tt.token})
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				user := res.body["user"].(map[string]any)
+				if user["activated"] != true {
+					t.Errorf("got activated %v; want true", user["activated"])
+				}
+			}
+		})
+	}
+}
+
+func TestSendActivationToken(t *testing.T) {
+	ts := newTestServer(t)
+	ts.registerUser(t, "alice@example.com", "pa55word1234")
+	ts.newActivatedUser(t, "bob@example.com")
+
+	tests := []struct {
+		name       string
+		email      string
+		wantStatus int
+		wantMail   bool
+	}{
+		{name: "inactive user", email: "alice@example.com", wantStatus: http.StatusOK, wantMail: true},
+		{name: "activated user", email: "bob@example.com", wantStatus: http.StatusOK},
+		{name: "unknown user", email: "carol@example.com", wantStatus: http.StatusOK},
+		{name: "invalid email", email: "carol", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.mailer.reset()
+
+			res := ts.do(t, http.MethodPost, "/v1/users/verification", "", map[string]string{"email": tt.email})
+			if res.status != tt.wantStatus {
+				t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+			}
+
+			ts.app.wg.Wait()
+			if _, ok := ts.mailer.last(tt.email, "user_welcome.tmpl"); ok != tt.wantMail {
+				t.Errorf("got welcome email sent %t; want %t", ok, tt.wantMail)
+			}
+		})
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	ts := newTestServer(t)
+	ts.newActivatedUser(t, "alice@example.com")
+	ts.registerUser(t, "bob@example.com", "pa55word1234")
+
+	t.Run("request token", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			email      string
+			wantStatus int
+		}{
+			{name: "activated user", email: "alice@example.com", wantStatus: http.StatusAccepted},
+			{name: "inactive user", email: "bob@example.com", wantStatus: http.StatusForbidden},
+			{name: "unknown user", email: "carol@example.com", wantStatus: http.StatusNotFound},
+			{name: "invalid email", email: "carol", wantStatus: http.StatusUnprocessableEntity},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				res := ts.do(t, http.MethodPost, "/v1/tokens/password-reset", "", map[string]string{"email": tt.email})
+				if res.status != tt.wantStatus {
+					t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+				}
+			})
+		}
+	})
+
+	ts.app.wg.Wait()
+	mail, ok := ts.mailer.last("alice@example.com", "password_reset.tmpl")
+	if !ok {
+		t.Fatal("no password reset email was sent")
+	}
+	resetToken := mail.data.(map[string]any)["passwordResetToken"].(string)
+
+	t.Run("update password", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			token      string
+			password   string
+			wantStatus int
+		}{
+			{name: "password too short", token: resetToken, password: "short", wantStatus: http.StatusUnprocessableEntity},
+			{name: "unknown token", token: "ABCDEFGHIJKLMNOPQRSTUVWXYZ", password: "newpa55word", wantStatus: http.StatusUnprocessableEntity},
+			{name: "valid token", token: resetToken, password: "newpa55word", wantStatus: http.StatusOK},
+			{name: "token already used", token: resetToken, password: "newpa55word", wantStatus: http.StatusUnprocessableEntity},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				res := ts.do(t, http.MethodPut, "/v1/users/password", "", map[string]string{
+					"password_reset_token": tt.token,
+					"new_password":         tt.password,
+				})
+				if res.status != tt.wantStatus {
+					t.Fatalf("got status %d; want %d (body %v)", res.status, tt.wantStatus, res.body)
+				}
+			})
+		}
+	})
+
+	ts.authenticate(t, "alice@example.com", "newpa55word")
+}
@@ -0,0 +1,423 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateCollectionName  = errors.New("duplicate collection name")
+	ErrDuplicateCollectionMovie = errors.New("duplicate collection movie")
+)
+
+// The kinds of collection.
+const (
+	CollectionKindSeries    = "series"    // numbered entries, such as a trilogy
+	CollectionKindFranchise = "franchise" // a shared universe, with spin-offs and reboots
+	CollectionKindCurated   = "curated"   // a hand-picked set, such as a festival selection
+)
+
+var CollectionKinds = []string{CollectionKindSeries, CollectionKindFranchise, CollectionKindCurated}
+
+// Collection is an ordered group of movies that belong together, such as a trilogy or
+// a franchise. Unlike lists, collections are shared by everyone and maintained by the
+// users who can edit movies.
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description,omitzero"`
+	MovieCount  int32     `json:"movie_count"`
+	Version     int32     `json:"version"`
+}
+
+// CollectionItem is a movie in a collection. Positions are numbered from 1 in
+// collection order.
+type CollectionItem struct {
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(validator.PermittedValue(collection.Kind, CollectionKinds...), "kind", "must be series, franchise or curated")
+	v.Check(len(collection.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+}
+
+// collectionMovieCountQuery counts the movies of the collection in the outer query,
+// leaving out the ones in the trash.
+const collectionMovieCountQuery = `
+	SELECT count(*)
+	FROM collections_movies
+	INNER JOIN movies ON movies.id = collections_movies.movie_id
+	WHERE collections_movies.collection_id = collections.id AND movies.deleted_at IS NULL`
+
+type CollectionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m CollectionModel) Insert(ctx context.Context, collection *Collection) error {
+	query := `
+	INSERT INTO collections (name, kind, description)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, updated_at, version
+	`
+	args := []any{collection.Name, collection.Kind, collection.Description}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.ID, &collection.CreatedAt, &collection.UpdatedAt, &collection.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "collections_name_key"`:
+			return ErrDuplicateCollectionName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CollectionModel) Get(ctx context.Context, id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id, created_at, updated_at, name, kind, description, (%s), version
+	FROM collections
+	WHERE id = $1
+	`, collectionMovieCountQuery)
+	var collection Collection
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.Name,
+		&collection.Kind,
+		&collection.Description,
+		&collection.MovieCount,
+		&collection.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &collection, nil
+}
+
+func (m CollectionModel) Update(ctx context.Context, collection *Collection) error {
+	query := `
+	UPDATE collections
+	SET name = $1, kind = $2, description = $3, version = version + 1, updated_at = NOW()
+	WHERE id = $4 AND version = $5
+	RETURNING version, updated_at
+	`
+	args := []any{collection.Name, collection.Kind, collection.Description, collection.ID, collection.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.Version, &collection.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "collections_name_key"`:
+			return ErrDuplicateCollectionName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CollectionModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM collections
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll returns a page of collections, optionally only those whose name contains all
+// the words in name and those of the given kind.
+func (m CollectionModel) GetAll(ctx context.Context, name, kind string, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, updated_at, name, kind, description, (%s), version
+	FROM collections
+	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	AND (kind = $2 OR $2 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $3 OFFSET $4
+	`, collectionMovieCountQuery, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, kind, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.UpdatedAt,
+			&collection.Name,
+			&collection.Kind,
+			&collection.Description,
+			&collection.MovieCount,
+			&collection.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return collections, metadata, nil
+}
+
+// GetMovies returns a page of the movies in a collection, the same way
+// ListModel.GetMovies() does for a list.
+func (m CollectionModel) GetMovies(ctx context.Context, collectionID int64, filters Filters) ([]*CollectionItem, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), items.position, items.added_at, movies.id, movies.created_at, movies.updated_at,
+		movies.title, movies.year, movies.runtime, movies.genres, movies.version, movies.average_rating, movies.rating_count
+	FROM (
+		SELECT collections_movies.movie_id, collections_movies.added_at, row_number() OVER (ORDER BY collections_movies.position) AS position
+		FROM collections_movies
+		INNER JOIN movies ON movies.id = collections_movies.movie_id
+		WHERE collections_movies.collection_id = $1 AND movies.deleted_at IS NULL
+	) AS items
+	INNER JOIN movies ON movies.id = items.movie_id
+	ORDER BY %s %s, movies.id ASC
+	LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, collectionID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	items := []*CollectionItem{}
+
+	for rows.Next() {
+		item := CollectionItem{Movie: &Movie{}}
+
+		err := rows.Scan(
+			&totalRecords,
+			&item.Position,
+			&item.AddedAt,
+			&item.Movie.ID,
+			&item.Movie.CreatedAt,
+			&item.Movie.UpdatedAt,
+			&item.Movie.Title,
+			&item.Movie.Year,
+			&item.Movie.Runtime,
+			pq.Array(&item.Movie.Genres),
+			&item.Movie.Version,
+			&item.Movie.AverageRating,
+			&item.Movie.RatingCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+// AddMovie adds a movie to a collection at the given position, moving the movies at
+// and after it down by one. A position of 0, or one past the end, appends the movie.
+func (m CollectionModel) AddMovie(ctx context.Context, collectionID, movieID int64, position int) error {
+	return m.reorder(ctx, collectionID, func(tx *sql.Tx, movieIDs []int64) ([]int64, error) {
+		if slices.Contains(movieIDs, movieID) {
+			return nil, ErrDuplicateCollectionMovie
+		}
+
+		// the foreign key would accept a movie in the trash, so check for that first
+		query := `
+		INSERT INTO collections_movies (collection_id, movie_id, position)
+		SELECT $1, id, $3
+		FROM movies
+		WHERE id = $2 AND deleted_at IS NULL
+		`
+		result, err := tx.ExecContext(ctx, query, collectionID, movieID, listPositionPlaceholder)
+		if err != nil {
+			return nil, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if rowsAffected == 0 {
+			return nil, ErrRecordNotFound
+		}
+
+		return slices.Insert(movieIDs, insertIndex(position, len(movieIDs)), movieID), nil
+	})
+}
+
+// MoveMovie moves a movie that is already in a collection to the given position.
+// Positions past the end of the collection move it to the end.
+func (m CollectionModel) MoveMovie(ctx context.Context, collectionID, movieID int64, position int) error {
+	return m.reorder(ctx, collectionID, func(tx *sql.Tx, movieIDs []int64) ([]int64, error) {
+		return moveListMovie(movieIDs, movieID, position)
+	})
+}
+
+func (m CollectionModel) RemoveMovie(ctx context.Context, collectionID, movieID int64) error {
+	return m.reorder(ctx, collectionID, func(tx *sql.Tx, movieIDs []int64) ([]int64, error) {
+		i := slices.Index(movieIDs, movieID)
+		if i == -1 {
+			return nil, ErrRecordNotFound
+		}
+
+		query := `
+		DELETE FROM collections_movies
+		WHERE collection_id = $1 AND movie_id = $2
+		`
+		_, err := tx.ExecContext(ctx, query, collectionID, movieID)
+		if err != nil {
+			return nil, err
+		}
+
+		return slices.Delete(movieIDs, i, i+1), nil
+	})
+}
+
+// reorder is ListModel.reorder() for collections: change gets the ids of the movies in
+// the collection that are not in the trash, in order, and the collection is renumbered
+// to match the order it returns, with the trashed movies kept at the end.
+func (m CollectionModel) reorder(ctx context.Context, collectionID int64, change func(tx *sql.Tx, movieIDs []int64) ([]int64, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM collections WHERE id = $1 FOR UPDATE`, collectionID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	var movieIDs, trashedIDs []int64
+	query := `
+	SELECT
+		COALESCE(array_agg(collections_movies.movie_id ORDER BY position) FILTER (WHERE movies.deleted_at IS NULL), '{}'),
+		COALESCE(array_agg(collections_movies.movie_id ORDER BY position) FILTER (WHERE movies.deleted_at IS NOT NULL), '{}')
+	FROM collections_movies
+	INNER JOIN movies ON movies.id = collections_movies.movie_id
+	WHERE collections_movies.collection_id = $1
+	`
+	err = tx.QueryRowContext(ctx, query, collectionID).Scan(pq.Array(&movieIDs), pq.Array(&trashedIDs))
+	if err != nil {
+		return err
+	}
+
+	movieIDs, err = change(tx, movieIDs)
+	if err != nil {
+		return err
+	}
+	movieIDs = append(movieIDs, trashedIDs...)
+
+	query = `
+	UPDATE collections_movies
+	SET position = ordered.position
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS ordered(movie_id, position)
+	WHERE collections_movies.collection_id = $1 AND collections_movies.movie_id = ordered.movie_id
+	`
+	_, err = tx.ExecContext(ctx, query, collectionID, pq.Array(movieIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE collections SET updated_at = NOW() WHERE id = $1`, collectionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,192 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// normalizedTitleSQL is the SQL counterpart of normalizeTitle(), which the
+// movies_normalized_title_year_idx index is built on. lower() and [:alnum:] follow the
+// LC_CTYPE of the database, so the two only agree on titles beyond ASCII when the
+// database uses a UTF-8 locale such as en_US.UTF-8. Under the C locale every non-ASCII
+// letter counts as punctuation, so "Amélie" and "Am lie" are taken for the same title.
+const normalizedTitleSQL = `trim(regexp_replace(lower(%s), '[^[:alnum:]]+', ' ', 'g'))`
+
+// normalizeTitle is the form titles are compared in to spot duplicates: lowercase, with
+// punctuation and runs of spaces turned into a single space, so that "Spider-Man" and
+// "spider man" are the same title.
+func normalizeTitle(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// DuplicateKey returns the same string for movies that FindDuplicate would take for
+// duplicates of each other, for spotting them among movies that aren't saved yet.
+func DuplicateKey(movie *Movie) string {
+	return fmt.Sprintf("%d %s", movie.Year, normalizeTitle(movie.Title))
+}
+
+// FindDuplicate returns a movie, other than the movie itself, with the same normalized
+// title and year, or ErrRecordNotFound if there is none. Movies in the trash don't
+// count.
+func (m MovieModel) FindDuplicate(ctx context.Context, movie *Movie) (*Movie, error) {
+	query := fmt.Sprintf(`
+	SELECT id
+	FROM movies
+	WHERE %s = %s AND year = $2 AND id <> $3 AND deleted_at IS NULL
+	ORDER BY id
+	LIMIT 1
+	`, fmt.Sprintf(normalizedTitleSQL, "title"), fmt.Sprintf(normalizedTitleSQL, "$1"))
+	var id int64
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movie.Title, movie.Year, movie.ID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(ctx, id)
+}
+
+// Merge folds the duplicates into the target movie in a single transaction. Their
+// credits, reviews, external ids, alternate titles, releases, images and places in
+// lists and collections move over to the target, except where the target already has
+// the same credit, a review by the same user, an id from the same source, a title in
+// the same locale, a release in the same country or a place in the same list or
+// collection. The duplicates are then moved to the trash, with a delete revision made
+// by userID for each, the ratings of every movie involved are recomputed and the target
+// gets a new version. It returns ErrRecordNotFound if the target or any duplicate
+// doesn't exist or is in the trash.
+func (m MovieModel) Merge(ctx context.Context, targetID int64, duplicateIDs []int64, userID int64) error {
+	// each statement takes the target id as $1 and a duplicate id as $2
+	moves := []string{
+		`UPDATE movies_people
+		SET movie_id = $1
+		WHERE movie_id = $2 AND (person_id, role, character_name) NOT IN (
+			SELECT person_id, role, character_name FROM movies_people WHERE movie_id = $1
+		)`,
+		`UPDATE reviews
+		SET movie_id = $1
+		WHERE movie_id = $2 AND user_id NOT IN (SELECT user_id FROM reviews WHERE movie_id = $1)`,
+		`UPDATE movie_external_ids
+		SET movie_id = $1
+		WHERE movie_id = $2 AND source NOT IN (SELECT source FROM movie_external_ids WHERE movie_id = $1)`,
+		`UPDATE movie_titles
+		SET movie_id = $1
+		WHERE movie_id = $2 AND locale NOT IN (SELECT locale FROM movie_titles WHERE movie_id = $1)`,
+		`UPDATE movie_releases
+		SET movie_id = $1
+		WHERE movie_id = $2 AND country NOT IN (SELECT country FROM movie_releases WHERE movie_id = $1)`,
+		`UPDATE movie_images
+		SET movie_id = $1
+		WHERE movie_id = $2`,
+		`UPDATE lists_movies
+		SET movie_id = $1
+		WHERE movie_id = $2 AND list_id NOT IN (SELECT list_id FROM lists_movies WHERE movie_id = $1)`,
+		`UPDATE collections_movies
+		SET movie_id = $1
+		WHERE movie_id = $2 AND collection_id NOT IN (SELECT collection_id FROM collections_movies WHERE movie_id = $1)`,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM movies WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, targetID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	for _, duplicateID := range duplicateIDs {
+		query := `
+		UPDATE movies
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, title, year, runtime, genres, version
+		`
+		var duplicate Movie
+		err := tx.QueryRowContext(ctx, query, duplicateID).Scan(
+			&duplicate.ID,
+			&duplicate.Title,
+			&duplicate.Year,
+			&duplicate.Runtime,
+			pq.Array(&duplicate.Genres),
+			&duplicate.Version,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		revision := NewMovieRevision(RevisionDelete, &duplicate, nil)
+		revision.UserID = userID
+		err = insertRevision(ctx, tx, revision)
+		if err != nil {
+			return err
+		}
+
+		for _, query := range moves {
+			_, err := tx.ExecContext(ctx, query, targetID, duplicateID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// moving reviews to another movie doesn't fire the reviews_refresh_movie_rating()
+	// trigger, which only watches the rating
+	query := `
+	UPDATE movies
+	SET average_rating = stats.average_rating, rating_count = stats.rating_count
+	FROM (
+		SELECT movies.id, COALESCE(ROUND(AVG(reviews.rating), 2), 0) AS average_rating, count(reviews.id) AS rating_count
+		FROM movies
+		LEFT JOIN reviews ON reviews.movie_id = movies.id
+		WHERE movies.id = ANY($1)
+		GROUP BY movies.id
+	) AS stats
+	WHERE movies.id = stats.id
+	`
+	_, err = tx.ExecContext(ctx, query, pq.Array(append([]int64{targetID}, duplicateIDs...)))
+	if err != nil {
+		return err
+	}
+
+	// the target has taken on the reviews and ratings of the duplicates, so it is a new
+	// version, and ETags taken before the merge go stale
+	_, err = tx.ExecContext(ctx, `UPDATE movies SET version = version + 1, updated_at = NOW() WHERE id = $1`, targetID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateExternalID = errors.New("duplicate external id")
+)
+
+const (
+	ExternalSourceIMDb     = "imdb"
+	ExternalSourceTMDB     = "tmdb"
+	ExternalSourceWikidata = "wikidata"
+)
+
+// ExternalSources are the catalogues a movie can be linked to.
+var ExternalSources = []string{ExternalSourceIMDb, ExternalSourceTMDB, ExternalSourceWikidata}
+
+// externalIDFormats describes the ids of each source: tt0111161 on IMDb, 278 on TMDB
+// and Q172241 on Wikidata.
+var externalIDFormats = map[string]*regexp.Regexp{
+	ExternalSourceIMDb:     regexp.MustCompile("^tt[0-9]{7,10}$"),
+	ExternalSourceTMDB:     regexp.MustCompile("^[1-9][0-9]{0,9}$"),
+	ExternalSourceWikidata: regexp.MustCompile("^Q[1-9][0-9]*$"),
+}
+
+// ExternalID links a movie to its entry in an external catalogue. A movie has at most
+// one id per source, and an id belongs to a single movie.
+type ExternalID struct {
+	MovieID    int64  `json:"movie_id"`
+	Source     string `json:"source"`
+	ExternalID string `json:"external_id"`
+}
+
+func ValidateExternalID(v *validator.Validator, externalID *ExternalID) {
+	v.Check(validator.PermittedValue(externalID.Source, ExternalSources...), "source", "must be one of imdb, tmdb or wikidata")
+	v.Check(externalID.ExternalID != "", "external_id", "must be provided")
+
+	if rx, ok := externalIDFormats[externalID.Source]; ok {
+		v.Check(validator.Matches(externalID.ExternalID, rx), "external_id", "is not a valid "+externalID.Source+" id")
+	}
+}
+
+type ExternalIDModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Set links the movie to the external id, replacing the id it had for the same source,
+// if any. It returns ErrDuplicateExternalID if another movie already has the id.
+func (m ExternalIDModel) Set(ctx context.Context, externalID *ExternalID) error {
+	query := `
+	INSERT INTO movie_external_ids (movie_id, source, external_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (movie_id, source) DO UPDATE SET external_id = EXCLUDED.external_id
+	`
+	args := []any{externalID.MovieID, externalID.Source, externalID.ExternalID}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_external_ids_source_external_id_key"`:
+			return ErrDuplicateExternalID
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ExternalIDModel) Delete(ctx context.Context, movieID int64, source string) error {
+	query := `
+	DELETE FROM movie_external_ids
+	WHERE movie_id = $1 AND source = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, source)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetMovieID returns the id of the movie linked to the external id, which may be in
+// the trash.
+func (m ExternalIDModel) GetMovieID(ctx context.Context, source, externalID string) (int64, error) {
+	query := `
+	SELECT movie_id
+	FROM movie_external_ids
+	WHERE source = $1 AND external_id = $2
+	`
+	var movieID int64
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, source, externalID).Scan(&movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return movieID, nil
+}
+
+func (m ExternalIDModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*ExternalID, error) {
+	externalIDs, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*ExternalID{}, externalIDs[movieID]...), nil
+}
+
+// GetAllForMovies returns the external ids of several movies at once, keyed by movie id
+// and ordered by source.
+func (m ExternalIDModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*ExternalID, error) {
+	query := `
+	SELECT movie_id, source, external_id
+	FROM movie_external_ids
+	WHERE movie_id = ANY($1)
+	ORDER BY movie_id, source
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	externalIDs := make(map[int64][]*ExternalID)
+	for rows.Next() {
+		var externalID ExternalID
+		err := rows.Scan(&externalID.MovieID, &externalID.Source, &externalID.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		externalIDs[externalID.MovieID] = append(externalIDs[externalID.MovieID], &externalID)
+	}
+
+	return externalIDs, rows.Err()
+}
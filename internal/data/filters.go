@@ -1,24 +1,88 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/kayconfig/green-light-api/internal/validator"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+
+	// Keyset is true when the client opted in to cursor based pagination, in which case
+	// Page is ignored. Cursor is the decoded cursor, or nil for the first page.
+	Keyset bool
+	Cursor *Cursor
+
+	// Facets are the fields the client wants counts of, across every matching record,
+	// checked against FacetSafeList.
+	Facets        []string
+	FacetSafeList []string
+
+	// Fields is a sparse fieldset: the only fields of each record the client wants,
+	// checked against FieldSafeList. It is empty when the client wants them all.
+	Fields        []string
+	FieldSafeList []string
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitzero"`
-	PageSize     int `json:"page_size,omitzero"`
-	FirstPage    int `json:"first_page,omitzero"`
-	LastPage     int `json:"last_page,omitzero"`
-	TotalRecords int `json:"total_records,omitzero"`
+	CurrentPage  int    `json:"current_page,omitzero"`
+	PageSize     int    `json:"page_size,omitzero"`
+	FirstPage    int    `json:"first_page,omitzero"`
+	LastPage     int    `json:"last_page,omitzero"`
+	TotalRecords int    `json:"total_records,omitzero"`
+	NextCursor   string `json:"next_cursor,omitzero"`
+	PrevCursor   string `json:"prev_cursor,omitzero"`
+
+	Facets map[string][]FacetCount `json:"facets,omitzero"`
+}
+
+// FacetCount is the number of matching records that share a value of a facet.
+type FacetCount struct {
+	Value any `json:"value"`
+	Count int `json:"count"`
+}
+
+// Cursor points at a row in a keyset paginated listing, using the value of the sort
+// column and the id of the row (the tie-breaker in every ORDER BY). Backward cursors
+// ask for the page before the row rather than the page after it. Clients only ever see
+// the encoded form, which they should treat as opaque.
+type Cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int64  `json:"id"`
+	Backward bool   `json:"b,omitzero"`
+}
+
+func (c Cursor) Encode() string {
+	js, err := json.Marshal(c)
+	if err != nil {
+		panic(err) // a Cursor always marshals
+	}
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
 }
 
 // The calculateMetadata() function calculates the appropriate pagination metadata
@@ -49,6 +113,28 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+
+	if f.Keyset {
+		v.Check(f.Page == 1, "page", "must not be used together with cursor")
+	}
+	if f.Cursor != nil {
+		v.Check(f.Cursor.Sort == f.Sort, "cursor", "was issued for a different sort value")
+	}
+
+	for _, facet := range f.Facets {
+		v.Check(validator.PermittedValue(facet, f.FacetSafeList...), "facets", "invalid facet value")
+	}
+	v.Check(validator.Unique(f.Facets), "facets", "must not contain duplicate values")
+
+	ValidateFields(v, f.Fields, f.FieldSafeList)
+}
+
+// ValidateFields checks a sparse fieldset against the fields that can be picked.
+func ValidateFields(v *validator.Validator, fields, safeList []string) {
+	for _, field := range fields {
+		v.Check(validator.PermittedValue(field, safeList...), "fields", "invalid field value")
+	}
+	v.Check(validator.Unique(fields), "fields", "must not contain duplicate values")
 }
 
 // Check that the client-provided Sort field matches one of the entries in our safelist
@@ -80,3 +166,39 @@ func (f Filters) limit() int {
 func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
+
+// The keysetClauses() method returns the WHERE condition and ORDER BY clause for
+// fetching the rows after f.Cursor (or before it, for a backward cursor). The sort
+// value and id of the cursor are expected in the $n and $n+1 placeholders. Because the
+// sort column and id can be ordered in different directions, the condition is spelled
+// out instead of using a row comparison like (column, id) > ($n, $n+1). Backward pages
+// are fetched in reverse order, so the rows closest to the cursor come first.
+func (f Filters) keysetClauses(n int) (string, string) {
+	column := f.sortColumn()
+	columnOp, columnOrder := ">", "ASC"
+	idOp, idOrder := ">", "ASC"
+
+	if f.sortDirection() == "DESC" {
+		columnOp, columnOrder = "<", "DESC"
+	}
+
+	if f.Cursor != nil && f.Cursor.Backward {
+		columnOp, columnOrder = flipKeysetDirection(columnOp, columnOrder)
+		idOp, idOrder = flipKeysetDirection(idOp, idOrder)
+	}
+
+	orderBy := fmt.Sprintf("%s %s, id %s", column, columnOrder, idOrder)
+	if f.Cursor == nil {
+		return "TRUE", orderBy
+	}
+
+	where := fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id %[4]s $%[5]d))", column, columnOp, n, idOp, n+1)
+	return where, orderBy
+}
+
+func flipKeysetDirection(op, order string) (string, string) {
+	if op == ">" {
+		return "<", "DESC"
+	}
+	return ">", "ASC"
+}
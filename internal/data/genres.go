@@ -0,0 +1,315 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrGenreInUse     = errors.New("genre in use")
+)
+
+var genreSlugRX = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+// Genre is an entry of the genre taxonomy. Movies refer to genres by their slug, which
+// never changes once the genre is created. Aliases are other names the genre goes by,
+// like "science fiction" for sci-fi, and Parent is the slug of a broader genre, if any.
+type Genre struct {
+	ID      int64    `json:"id"`
+	Slug    string   `json:"slug"`
+	Name    string   `json:"name"`
+	Parent  string   `json:"parent,omitzero"`
+	Aliases []string `json:"aliases"`
+	Version int32    `json:"version"`
+}
+
+// defaultGenres is the taxonomy the migrations seed the genres table with.
+var defaultGenres = []Genre{
+	{Slug: "action", Name: "Action"},
+	{Slug: "adventure", Name: "Adventure"},
+	{Slug: "animation", Name: "Animation", Aliases: []string{"animated", "cartoon"}},
+	{Slug: "comedy", Name: "Comedy"},
+	{Slug: "crime", Name: "Crime"},
+	{Slug: "documentary", Name: "Documentary"},
+	{Slug: "drama", Name: "Drama"},
+	{Slug: "family", Name: "Family"},
+	{Slug: "fantasy", Name: "Fantasy"},
+	{Slug: "history", Name: "History"},
+	{Slug: "horror", Name: "Horror"},
+	{Slug: "musical", Name: "Musical"},
+	{Slug: "mystery", Name: "Mystery"},
+	{Slug: "romance", Name: "Romance"},
+	{Slug: "sci-fi", Name: "Science Fiction", Aliases: []string{"scifi", "sf"}},
+	{Slug: "thriller", Name: "Thriller"},
+	{Slug: "war", Name: "War"},
+	{Slug: "western", Name: "Western"},
+	{Slug: "romantic-comedy", Name: "Romantic Comedy", Parent: "comedy", Aliases: []string{"rom-com", "romcom"}},
+	{Slug: "superhero", Name: "Superhero", Parent: "action"},
+}
+
+// genreKey is the form genre names are compared in: "Sci-Fi", "sci fi" and "SCI_FI"
+// all have the key "sci fi". The migration that created the genres table normalized
+// the existing movies the same way.
+func genreKey(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_'
+	}), " ")
+}
+
+// GenreIndex maps the key of every slug, name and alias of the known genres to the
+// genre's slug.
+type GenreIndex map[string]string
+
+func NewGenreIndex(genres []*Genre) GenreIndex {
+	index := make(GenreIndex)
+	for _, genre := range genres {
+		for _, name := range append([]string{genre.Slug, genre.Name}, genre.Aliases...) {
+			index[genreKey(name)] = genre.Slug
+		}
+	}
+	return index
+}
+
+// Normalize replaces every known genre name or alias by the slug of the genre, and
+// leaves unknown genres as they are for ValidateMovie to report.
+func (index GenreIndex) Normalize(genres []string) []string {
+	if genres == nil {
+		return nil
+	}
+
+	normalized := make([]string, len(genres))
+	for i, genre := range genres {
+		slug, ok := index[genreKey(genre)]
+		if !ok {
+			slug = genre
+		}
+		normalized[i] = slug
+	}
+	return normalized
+}
+
+// Has reports whether slug is the slug of a known genre.
+func (index GenreIndex) Has(slug string) bool {
+	return index[genreKey(slug)] == slug
+}
+
+// ValidateGenre checks a new or updated genre against the existing ones, which may
+// include the genre itself: names and aliases must not be taken by another genre, and
+// the parent must exist without making the hierarchy go round in circles.
+func ValidateGenre(v *validator.Validator, genre *Genre, existing []*Genre) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 50, "slug", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(genre.Slug, genreSlugRX), "slug", "must only contain lowercase letters, digits and single hyphens")
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(genre.Aliases != nil, "aliases", "must be provided")
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contain more than 20 aliases")
+
+	keys := make([]string, len(genre.Aliases))
+	for i, alias := range genre.Aliases {
+		keys[i] = genreKey(alias)
+		v.Check(keys[i] != "", "aliases", "must not contain empty values")
+		v.Check(len(alias) <= 100, "aliases", "must not contain values more than 100 bytes long")
+	}
+	v.Check(validator.Unique(keys), "aliases", "must not contain duplicate values")
+
+	parents := make(map[string]string)
+	others := make([]*Genre, 0, len(existing))
+	for _, other := range existing {
+		parents[other.Slug] = other.Parent
+		if other.Slug != genre.Slug {
+			others = append(others, other)
+		}
+	}
+	parents[genre.Slug] = genre.Parent
+
+	taken := NewGenreIndex(others)
+	if slug, ok := taken[genreKey(genre.Slug)]; ok {
+		v.AddError("slug", fmt.Sprintf("is already used by the %q genre", slug))
+	}
+	if slug, ok := taken[genreKey(genre.Name)]; ok {
+		v.AddError("name", fmt.Sprintf("is already used by the %q genre", slug))
+	}
+	for _, key := range keys {
+		if slug, ok := taken[key]; ok {
+			v.AddError("aliases", fmt.Sprintf("contains a name already used by the %q genre", slug))
+		}
+	}
+
+	if genre.Parent != "" {
+		_, ok := parents[genre.Parent]
+		v.Check(ok && genre.Parent != genre.Slug, "parent", "must be an existing genre")
+
+		// walk up from the parent; getting back to the genre means a cycle
+		for slug, steps := genre.Parent, 0; slug != "" && steps <= len(parents); slug, steps = parents[slug], steps+1 {
+			if slug == genre.Slug {
+				v.AddError("parent", "must not be the genre itself or one of its subgenres")
+				break
+			}
+		}
+	}
+}
+
+type GenreModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m GenreModel) GetAll(ctx context.Context) ([]*Genre, error) {
+	query := `
+	SELECT id, slug, name, COALESCE(parent, ''), aliases, version
+	FROM genres
+	ORDER BY slug
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(&genre.ID, &genre.Slug, &genre.Name, &genre.Parent, pq.Array(&genre.Aliases), &genre.Version)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+
+	return genres, rows.Err()
+}
+
+func (m GenreModel) GetIndex(ctx context.Context) (GenreIndex, error) {
+	genres, err := m.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewGenreIndex(genres), nil
+}
+
+func (m GenreModel) Get(ctx context.Context, slug string) (*Genre, error) {
+	query := `
+	SELECT id, slug, name, COALESCE(parent, ''), aliases, version
+	FROM genres
+	WHERE slug = $1
+	`
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(
+		&genre.ID,
+		&genre.Slug,
+		&genre.Name,
+		&genre.Parent,
+		pq.Array(&genre.Aliases),
+		&genre.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+func (m GenreModel) Insert(ctx context.Context, genre *Genre) error {
+	query := `
+	INSERT INTO genres (slug, name, parent, aliases)
+	VALUES ($1, $2, NULLIF($3, ''), $4)
+	RETURNING id, version
+	`
+	args := []any{genre.Slug, genre.Name, genre.Parent, pq.Array(genre.Aliases)}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m GenreModel) Update(ctx context.Context, genre *Genre) error {
+	query := `
+	UPDATE genres
+	SET name = $1, parent = NULLIF($2, ''), aliases = $3, version = version + 1
+	WHERE slug = $4 AND version = $5
+	RETURNING version
+	`
+	args := []any{genre.Name, genre.Parent, pq.Array(genre.Aliases), genre.Slug, genre.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a genre, and returns ErrGenreInUse if any movie, including those in
+// the trash, still has it. Subgenres of the genre are left without a parent.
+func (m GenreModel) Delete(ctx context.Context, slug string) error {
+	query := `
+	DELETE FROM genres
+	WHERE slug = $1 AND NOT EXISTS (SELECT 1 FROM movies WHERE $1 = ANY(genres))
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, slug)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		// tell a missing genre apart from one that is still in use
+		if _, err := m.Get(ctx, slug); err != nil {
+			return err
+		}
+		return ErrGenreInUse
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	ImageKindPoster   = "poster"
+	ImageKindBackdrop = "backdrop"
+)
+
+var ImageKinds = []string{ImageKindPoster, ImageKindBackdrop}
+
+// ImageContentTypes are the formats images can be uploaded in.
+var ImageContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// ThumbnailWidths are the widths, in pixels, the thumbnails of each kind of image are
+// made in. Images only get the thumbnails that are narrower than they are.
+var ThumbnailWidths = map[string][]int{
+	ImageKindPoster:   {154, 342, 500},
+	ImageKindBackdrop: {300, 780, 1280},
+}
+
+// MaxImageDimension is the largest width or height, in pixels, of an uploaded image.
+// It bounds the memory taken to make the thumbnails.
+const MaxImageDimension = 6000
+
+// MovieImage is a poster or backdrop uploaded for a movie. The files themselves are
+// kept in the storage under StorageKey; URL and the URLs of the thumbnails are filled
+// in by the handlers, since they depend on where the storage is served from.
+type MovieImage struct {
+	ID          int64             `json:"id"`
+	CreatedAt   time.Time         `json:"created_at"`
+	MovieID     int64             `json:"movie_id"`
+	Kind        string            `json:"kind"`
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Size        int64             `json:"size"`
+	StorageKey  string            `json:"-"`
+	URL         string            `json:"url"`
+	Thumbnails  []*ImageThumbnail `json:"thumbnails"`
+}
+
+// ImageThumbnail is a scaled down JPEG copy of a movie image.
+type ImageThumbnail struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	StorageKey string `json:"-"`
+	URL        string `json:"url"`
+}
+
+// thumbnailRecord is how a thumbnail is kept in the thumbnails column.
+type thumbnailRecord struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	StorageKey string `json:"storage_key"`
+}
+
+func ValidateMovieImage(v *validator.Validator, image *MovieImage) {
+	v.Check(validator.PermittedValue(image.Kind, ImageKinds...), "kind", "must be poster or backdrop")
+	v.Check(image.Width <= MaxImageDimension && image.Height <= MaxImageDimension, "image", "must not be more than 6000 pixels wide or high")
+}
+
+type MovieImageModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m MovieImageModel) Insert(ctx context.Context, image *MovieImage) error {
+	thumbnails := make([]thumbnailRecord, len(image.Thumbnails))
+	for i, thumbnail := range image.Thumbnails {
+		thumbnails[i] = thumbnailRecord{thumbnail.Width, thumbnail.Height, thumbnail.StorageKey}
+	}
+
+	js, err := json.Marshal(thumbnails)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO movie_images (movie_id, kind, content_type, width, height, size, storage_key, thumbnails)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, created_at
+	`
+	args := []any{image.MovieID, image.Kind, image.ContentType, image.Width, image.Height, image.Size, image.StorageKey, js}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt)
+}
+
+func (m MovieImageModel) Get(ctx context.Context, movieID, id int64) (*MovieImage, error) {
+	query := `
+	SELECT id, created_at, movie_id, kind, content_type, width, height, size, storage_key, thumbnails
+	FROM movie_images
+	WHERE movie_id = $1 AND id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	image, err := scanMovieImage(m.DB.QueryRowContext(ctx, query, movieID, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+// GetByStorageKey returns the image whose file, or the file of one of its thumbnails,
+// is kept under key. The images of movies in the trash are hidden along with them.
+func (m MovieImageModel) GetByStorageKey(ctx context.Context, key string) (*MovieImage, error) {
+	query := `
+	SELECT movie_images.id, movie_images.created_at, movie_id, kind, content_type, width, height, size, storage_key, thumbnails
+	FROM movie_images
+	INNER JOIN movies ON movies.id = movie_images.movie_id
+	WHERE (storage_key = $1 OR thumbnails @> jsonb_build_array(jsonb_build_object('storage_key', $1::text)))
+	AND movies.deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	image, err := scanMovieImage(m.DB.QueryRowContext(ctx, query, key))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+func (m MovieImageModel) Delete(ctx context.Context, movieID, id int64) error {
+	query := `
+	DELETE FROM movie_images
+	WHERE movie_id = $1 AND id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MovieImageModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieImage, error) {
+	images, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*MovieImage{}, images[movieID]...), nil
+}
+
+// GetAllForMovies returns the images of several movies at once, keyed by movie id and
+// ordered by kind, then from the oldest upload to the newest.
+func (m MovieImageModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieImage, error) {
+	query := `
+	SELECT id, created_at, movie_id, kind, content_type, width, height, size, storage_key, thumbnails
+	FROM movie_images
+	WHERE movie_id = ANY($1)
+	ORDER BY movie_id, kind DESC, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make(map[int64][]*MovieImage)
+	for rows.Next() {
+		image, err := scanMovieImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images[image.MovieID] = append(images[image.MovieID], image)
+	}
+
+	return images, rows.Err()
+}
+
+// GetAllDeletedBefore returns the images of the movies that were moved to the trash
+// before the given time, i.e. those PurgeDeleted is about to delete, so that their
+// files can be deleted as well.
+func (m MovieImageModel) GetAllDeletedBefore(ctx context.Context, before time.Time) ([]*MovieImage, error) {
+	query := `
+	SELECT id, created_at, movie_id, kind, content_type, width, height, size, storage_key, thumbnails
+	FROM movie_images
+	WHERE movie_id IN (SELECT id FROM movies WHERE deleted_at < $1)
+	ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []*MovieImage
+	for rows.Next() {
+		image, err := scanMovieImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	return images, rows.Err()
+}
+
+// scanMovieImage scans a row of the columns selected by the queries above.
+func scanMovieImage(row interface{ Scan(...any) error }) (*MovieImage, error) {
+	var image MovieImage
+	var js []byte
+
+	err := row.Scan(
+		&image.ID,
+		&image.CreatedAt,
+		&image.MovieID,
+		&image.Kind,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.Size,
+		&image.StorageKey,
+		&js,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var thumbnails []thumbnailRecord
+	if err := json.Unmarshal(js, &thumbnails); err != nil {
+		return nil, err
+	}
+
+	image.Thumbnails = make([]*ImageThumbnail, len(thumbnails))
+	for i, thumbnail := range thumbnails {
+		image.Thumbnails[i] = &ImageThumbnail{Width: thumbnail.Width, Height: thumbnail.Height, StorageKey: thumbnail.StorageKey}
+	}
+
+	return &image, nil
+}
@@ -0,0 +1,436 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateListName  = errors.New("duplicate list name")
+	ErrDuplicateListMovie = errors.New("duplicate list movie")
+)
+
+// List is a named, ordered collection of movies owned by a user, such as a watchlist.
+// Private lists are only visible to their owner.
+type List struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitzero"`
+	Public      bool      `json:"public"`
+	MovieCount  int32     `json:"movie_count"`
+	Version     int32     `json:"version"`
+}
+
+// ListItem is a movie in a list. Positions are numbered from 1 in list order.
+type ListItem struct {
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(list.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+}
+
+// listMovieCountQuery counts the movies of the list in the outer query, leaving out the
+// ones in the trash.
+const listMovieCountQuery = `
+	SELECT count(*)
+	FROM lists_movies
+	INNER JOIN movies ON movies.id = lists_movies.movie_id
+	WHERE lists_movies.list_id = lists.id AND movies.deleted_at IS NULL`
+
+type ListModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m ListModel) Insert(ctx context.Context, list *List) error {
+	query := `
+	INSERT INTO lists (user_id, name, description, public)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at, version
+	`
+	args := []any{list.UserID, list.Name, list.Description, list.Public}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.UpdatedAt, &list.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "lists_user_name_key"`:
+			return ErrDuplicateListName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ListModel) Get(ctx context.Context, id int64) (*List, error) {
+	query := fmt.Sprintf(`
+	SELECT id, created_at, updated_at, user_id, name, description, public,
+		(%s), version
+	FROM lists
+	WHERE id = $1
+	`, listMovieCountQuery)
+	var list List
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+		&list.UserID,
+		&list.Name,
+		&list.Description,
+		&list.Public,
+		&list.MovieCount,
+		&list.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+func (m ListModel) Update(ctx context.Context, list *List) error {
+	query := `
+	UPDATE lists
+	SET name = $1, description = $2, public = $3, version = version + 1, updated_at = NOW()
+	WHERE id = $4 AND version = $5
+	RETURNING version, updated_at
+	`
+	args := []any{list.Name, list.Description, list.Public, list.ID, list.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version, &list.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "lists_user_name_key"`:
+			return ErrDuplicateListName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ListModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM lists
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m ListModel) GetAllForUser(ctx context.Context, userID int64, filters Filters) ([]*List, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, updated_at, user_id, name, description, public,
+		(%s), version
+	FROM lists
+	WHERE user_id = $1
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3
+	`, listMovieCountQuery, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	lists := []*List{}
+
+	for rows.Next() {
+		var list List
+
+		err := rows.Scan(
+			&totalRecords,
+			&list.ID,
+			&list.CreatedAt,
+			&list.UpdatedAt,
+			&list.UserID,
+			&list.Name,
+			&list.Description,
+			&list.Public,
+			&list.MovieCount,
+			&list.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return lists, metadata, nil
+}
+
+// GetMovies returns a page of the movies in a list. Whatever the sort order, each item
+// keeps the position it has in the list. Movies in the trash are skipped, and do not
+// count towards the positions.
+func (m ListModel) GetMovies(ctx context.Context, listID int64, filters Filters) ([]*ListItem, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), items.position, items.added_at, movies.id, movies.created_at, movies.updated_at,
+		movies.title, movies.year, movies.runtime, movies.genres, movies.version, movies.average_rating, movies.rating_count
+	FROM (
+		SELECT lists_movies.movie_id, lists_movies.added_at, row_number() OVER (ORDER BY lists_movies.position) AS position
+		FROM lists_movies
+		INNER JOIN movies ON movies.id = lists_movies.movie_id
+		WHERE lists_movies.list_id = $1 AND movies.deleted_at IS NULL
+	) AS items
+	INNER JOIN movies ON movies.id = items.movie_id
+	ORDER BY %s %s, movies.id ASC
+	LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	items := []*ListItem{}
+
+	for rows.Next() {
+		item := ListItem{Movie: &Movie{}}
+
+		err := rows.Scan(
+			&totalRecords,
+			&item.Position,
+			&item.AddedAt,
+			&item.Movie.ID,
+			&item.Movie.CreatedAt,
+			&item.Movie.UpdatedAt,
+			&item.Movie.Title,
+			&item.Movie.Year,
+			&item.Movie.Runtime,
+			pq.Array(&item.Movie.Genres),
+			&item.Movie.Version,
+			&item.Movie.AverageRating,
+			&item.Movie.RatingCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+// AddMovie adds a movie to a list at the given position, moving the movies at and after
+// it down by one. A position of 0, or one past the end of the list, appends the movie.
+func (m ListModel) AddMovie(ctx context.Context, listID, movieID int64, position int) error {
+	return m.reorder(ctx, listID, func(tx *sql.Tx, movieIDs []int64) ([]int64, error) {
+		if slices.Contains(movieIDs, movieID) {
+			return nil, ErrDuplicateListMovie
+		}
+
+		// the foreign key would accept a movie in the trash, so check for that first
+		query := `
+		INSERT INTO lists_movies (list_id, movie_id, position)
+		SELECT $1, id, $3
+		FROM movies
+		WHERE id = $2 AND deleted_at IS NULL
+		`
+		result, err := tx.ExecContext(ctx, query, listID, movieID, listPositionPlaceholder)
+		if err != nil {
+			return nil, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if rowsAffected == 0 {
+			return nil, ErrRecordNotFound
+		}
+
+		return slices.Insert(movieIDs, insertIndex(position, len(movieIDs)), movieID), nil
+	})
+}
+
+// MoveMovie moves a movie that is already in a list to the given position. Positions
+// past the end of the list move it to the end.
+func (m ListModel) MoveMovie(ctx context.Context, listID, movieID int64, position int) error {
+	return m.reorder(ctx, listID, func(tx *sql.Tx, movieIDs []int64) ([]int64, error) {
+		return moveListMovie(movieIDs, movieID, position)
+	})
+}
+
+func (m ListModel) RemoveMovie(ctx context.Context, listID, movieID int64) error {
+	return m.reorder(ctx, listID, func(tx *sql.Tx, movieIDs []int64) ([]int64, error) {
+		i := slices.Index(movieIDs, movieID)
+		if i == -1 {
+			return nil, ErrRecordNotFound
+		}
+
+		query := `
+		DELETE FROM lists_movies
+		WHERE list_id = $1 AND movie_id = $2
+		`
+		_, err := tx.ExecContext(ctx, query, listID, movieID)
+		if err != nil {
+			return nil, err
+		}
+
+		return slices.Delete(movieIDs, i, i+1), nil
+	})
+}
+
+// listPositionPlaceholder is the position given to a new row before reorder() renumbers
+// the list. The unique constraint on positions is only checked on commit.
+const listPositionPlaceholder = -1
+
+// reorder runs change in a transaction with the ids of the movies in the list, in list
+// order, and then renumbers the list to match the order change returns. Movies in the
+// trash are not passed to change; they are kept after the others so that they come
+// back, at the end of the list, if they are restored. The list row is locked for the
+// duration so that concurrent changes to the same list are serialised.
+func (m ListModel) reorder(ctx context.Context, listID int64, change func(tx *sql.Tx, movieIDs []int64) ([]int64, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	var movieIDs, trashedIDs []int64
+	query := `
+	SELECT
+		COALESCE(array_agg(lists_movies.movie_id ORDER BY position) FILTER (WHERE movies.deleted_at IS NULL), '{}'),
+		COALESCE(array_agg(lists_movies.movie_id ORDER BY position) FILTER (WHERE movies.deleted_at IS NOT NULL), '{}')
+	FROM lists_movies
+	INNER JOIN movies ON movies.id = lists_movies.movie_id
+	WHERE lists_movies.list_id = $1
+	`
+	err = tx.QueryRowContext(ctx, query, listID).Scan(pq.Array(&movieIDs), pq.Array(&trashedIDs))
+	if err != nil {
+		return err
+	}
+
+	movieIDs, err = change(tx, movieIDs)
+	if err != nil {
+		return err
+	}
+	movieIDs = append(movieIDs, trashedIDs...)
+
+	query = `
+	UPDATE lists_movies
+	SET position = ordered.position
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS ordered(movie_id, position)
+	WHERE lists_movies.list_id = $1 AND lists_movies.movie_id = ordered.movie_id
+	`
+	_, err = tx.ExecContext(ctx, query, listID, pq.Array(movieIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE lists SET updated_at = NOW() WHERE id = $1`, listID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertIndex converts a 1-based list position into a slice index for a list of n
+// movies, where 0 means the end of the list.
+func insertIndex(position, n int) int {
+	if position < 1 || position > n {
+		return n
+	}
+	return position - 1
+}
+
+// moveListMovie moves movieID to the given 1-based position within movieIDs.
+func moveListMovie(movieIDs []int64, movieID int64, position int) ([]int64, error) {
+	i := slices.Index(movieIDs, movieID)
+	if i == -1 {
+		return nil, ErrRecordNotFound
+	}
+
+	movieIDs = slices.Delete(movieIDs, i, i+1)
+	return slices.Insert(movieIDs, insertIndex(position, len(movieIDs)), movieID), nil
+}
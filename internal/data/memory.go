@@ -0,0 +1,833 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// memoryStore holds every record for the in-memory models. The models share a single
+// store (and a single mutex) because, just like the tables in PostgreSQL, they refer to
+// each other: tokens and permissions belong to users.
+type memoryStore struct {
+	mu sync.RWMutex
+
+	movies           map[int64]Movie
+	lastMovieID      int64
+	users            map[int64]User
+	lastUserID       int64
+	tokens           map[string]Token
+	permissions      []string
+	userPermissions  map[int64][]string
+	people           map[int64]Person
+	lastPersonID     int64
+	credits          map[int64]Credit
+	lastCreditID     int64
+	reviews          map[int64]Review
+	lastReviewID     int64
+	lists            map[int64]List
+	lastListID       int64
+	listMovies       map[int64][]listEntry
+	revisions        map[int64]MovieRevision
+	lastRevisionID   int64
+	genres           map[string]Genre
+	lastGenreID      int64
+	externalIDs      map[int64]map[string]string // movie id -> source -> external id
+	collections      map[int64]Collection
+	lastCollectionID int64
+	collectionMovies map[int64][]listEntry
+	movieTitles      map[int64]map[string]string       // movie id -> locale -> title
+	movieReleases    map[int64]map[string]MovieRelease // movie id -> country -> release
+	images           map[int64]MovieImage
+	lastImageID      int64
+}
+
+// NewMemoryModels returns a Models struct backed by an in-memory store instead of
+// PostgreSQL. It is meant for tests, where spinning up a database is not worth it.
+// The permissions and genres tables are seeded with the same rows as the migrations.
+func NewMemoryModels() Models {
+	store := &memoryStore{
+		movies:           make(map[int64]Movie),
+		users:            make(map[int64]User),
+		tokens:           make(map[string]Token),
+		permissions:      []string{PermissionsCode.MoviesRead, PermissionsCode.MoviesWrite, PermissionsCode.ReviewsWrite, PermissionsCode.MoviesAdmin, PermissionsCode.MoviesExport},
+		userPermissions:  make(map[int64][]string),
+		people:           make(map[int64]Person),
+		credits:          make(map[int64]Credit),
+		reviews:          make(map[int64]Review),
+		lists:            make(map[int64]List),
+		listMovies:       make(map[int64][]listEntry),
+		revisions:        make(map[int64]MovieRevision),
+		genres:           make(map[string]Genre),
+		externalIDs:      make(map[int64]map[string]string),
+		collections:      make(map[int64]Collection),
+		collectionMovies: make(map[int64][]listEntry),
+		movieTitles:      make(map[int64]map[string]string),
+		movieReleases:    make(map[int64]map[string]MovieRelease),
+		images:           make(map[int64]MovieImage),
+	}
+
+	for _, genre := range defaultGenres {
+		store.lastGenreID++
+		genre.ID = store.lastGenreID
+		genre.Version = 1
+		if genre.Aliases == nil {
+			genre.Aliases = []string{}
+		}
+		store.genres[genre.Slug] = genre
+	}
+
+	return Models{
+		Movies:      MemoryMovieModel{store: store},
+		Users:       MemoryUserModel{store: store},
+		Tokens:      MemoryTokenModel{store: store},
+		Permissions: MemoryPermissionModel{store: store},
+		People:      MemoryPersonModel{store: store},
+		Reviews:     MemoryReviewModel{store: store},
+		Lists:       MemoryListModel{store: store},
+		Revisions:   MemoryMovieRevisionModel{store: store},
+		Genres:      MemoryGenreModel{store: store},
+		ExternalIDs: MemoryExternalIDModel{store: store},
+		Collections: MemoryCollectionModel{store: store},
+		Titles:      MemoryMovieTitleModel{store: store},
+		Releases:    MemoryMovieReleaseModel{store: store},
+		Images:      MemoryMovieImageModel{store: store},
+	}
+}
+
+type MemoryMovieModel struct {
+	store *memoryStore
+}
+
+// copyMovie returns a copy of the movie that does not share its genres slice, so that
+// callers can never modify the stored record without going through the model.
+func copyMovie(movie Movie) *Movie {
+	movie.Genres = slices.Clone(movie.Genres)
+	return &movie
+}
+
+// liveMovie returns the movie with the given id unless it doesn't exist or is in the
+// trash. The caller must hold the lock.
+func (s *memoryStore) liveMovie(id int64) (Movie, bool) {
+	movie, ok := s.movies[id]
+	return movie, ok && movie.DeletedAt == nil
+}
+
+func (m MemoryMovieModel) Insert(ctx context.Context, movie *Movie, revision *MovieRevision) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.lastMovieID++
+
+	now := time.Now()
+	movie.ID = m.store.lastMovieID
+	movie.CreatedAt = now
+	movie.UpdatedAt = now
+	movie.Version = 1
+
+	m.store.movies[movie.ID] = *copyMovie(*movie)
+	m.store.recordRevision(revision, movie)
+	return nil
+}
+
+func (m MemoryMovieModel) InsertBatch(ctx context.Context, movies []*Movie, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	now := time.Now()
+	for _, movie := range movies {
+		m.store.lastMovieID++
+
+		movie.ID = m.store.lastMovieID
+		movie.CreatedAt = now
+		movie.UpdatedAt = now
+		movie.Version = 1
+
+		m.store.movies[movie.ID] = *copyMovie(*movie)
+
+		revision := NewMovieRevision(RevisionInsert, nil, movie)
+		revision.UserID = userID
+		m.store.addRevision(revision)
+	}
+	return nil
+}
+
+func (m MemoryMovieModel) ExecuteBatch(ctx context.Context, ops []*MovieOperation) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	// the operations are applied to a copy, which replaces the movies only if all of
+	// them succeed
+	movies := maps.Clone(m.store.movies)
+	lastMovieID := m.store.lastMovieID
+	now := time.Now()
+
+	failed := false
+	for _, op := range ops {
+		movie := op.Movie
+
+		if op.Action == BatchCreate {
+			lastMovieID++
+			movie.ID = lastMovieID
+			movie.CreatedAt = now
+			movie.UpdatedAt = now
+			movie.Version = 1
+			movies[movie.ID] = *copyMovie(*movie)
+			continue
+		}
+
+		existing, ok := movies[movie.ID]
+		if !ok || existing.DeletedAt != nil || existing.Version != movie.Version {
+			op.Err = ErrEditConflict
+			failed = true
+			continue
+		}
+
+		switch op.Action {
+		case BatchUpdate:
+			movie.Version++
+			movie.UpdatedAt = now
+			movie.CreatedAt = existing.CreatedAt
+			movie.AverageRating = existing.AverageRating
+			movie.RatingCount = existing.RatingCount
+			movies[movie.ID] = *copyMovie(*movie)
+		case BatchDelete:
+			existing.DeletedAt = &now
+			movie.DeletedAt = &now
+			movies[movie.ID] = existing
+		default:
+			panic("unknown batch action: " + op.Action)
+		}
+	}
+
+	if failed {
+		return ErrBatchFailed
+	}
+
+	m.store.movies = movies
+	m.store.lastMovieID = lastMovieID
+	for _, op := range ops {
+		m.store.recordRevision(op.Revision, op.Movie)
+	}
+	return nil
+}
+
+func (m MemoryMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	movie, ok := m.store.liveMovie(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyMovie(movie), nil
+}
+
+// GetFields reads the whole movie, the fields are only picked out of the JSON.
+func (m MemoryMovieModel) GetFields(ctx context.Context, id int64, fields []string) (*Movie, error) {
+	return m.Get(ctx, id)
+}
+
+func (m MemoryMovieModel) Update(ctx context.Context, movie *Movie, revision *MovieRevision) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	// mirror the WHERE id = $5 AND version = $6 clause used by MovieModel.Update()
+	existing, ok := m.store.liveMovie(movie.ID)
+	if !ok || existing.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	movie.Version++
+	movie.UpdatedAt = time.Now()
+	movie.CreatedAt = existing.CreatedAt
+	// the rating aggregates are owned by the reviews, not by the caller
+	movie.AverageRating = existing.AverageRating
+	movie.RatingCount = existing.RatingCount
+
+	m.store.movies[movie.ID] = *copyMovie(*movie)
+	m.store.recordRevision(revision, movie)
+	return nil
+}
+
+func (m MemoryMovieModel) Delete(ctx context.Context, id int64, revision *MovieRevision) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	movie, ok := m.store.liveMovie(id)
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	now := time.Now()
+	movie.DeletedAt = &now
+	m.store.movies[id] = movie
+	m.store.recordRevision(revision, &movie)
+	return nil
+}
+
+func (m MemoryMovieModel) DeleteVersion(ctx context.Context, id int64, version int32, revision *MovieRevision) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	movie, ok := m.store.liveMovie(id)
+	if !ok || movie.Version != version {
+		return ErrEditConflict
+	}
+
+	now := time.Now()
+	movie.DeletedAt = &now
+	m.store.movies[id] = movie
+	m.store.recordRevision(revision, &movie)
+	return nil
+}
+
+func (m MemoryMovieModel) Restore(ctx context.Context, id int64, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	movie, ok := m.store.movies[id]
+	if !ok || movie.DeletedAt == nil {
+		return ErrRecordNotFound
+	}
+
+	// a restore is a new version, so that ETags taken before the delete go stale
+	movie.DeletedAt = nil
+	movie.Version++
+	movie.UpdatedAt = time.Now()
+	m.store.movies[id] = movie
+
+	revision := NewMovieRevision(RevisionRestore, &movie, &movie)
+	revision.UserID = userID
+	m.store.addRevision(revision)
+	return nil
+}
+
+func (m MemoryMovieModel) GetAllDeleted(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := []*Movie{}
+	for _, movie := range m.store.movies {
+		if movie.DeletedAt != nil {
+			matches = append(matches, copyMovie(movie))
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *Movie) int {
+		var c int
+		switch column {
+		case "deleted_at":
+			c = a.DeletedAt.Compare(*b.DeletedAt)
+		default:
+			c = compareMovieColumn(a, b, column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+func (m MemoryMovieModel) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	var purged int64
+	for id, movie := range m.store.movies {
+		if movie.DeletedAt != nil && movie.DeletedAt.Before(before) {
+			m.store.deleteMovie(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// deleteMovie removes a movie along with the rows that reference it, like the ON
+// DELETE CASCADE foreign keys do in PostgreSQL. The caller must hold the lock.
+func (s *memoryStore) deleteMovie(id int64) {
+	delete(s.movies, id)
+
+	for creditID, credit := range s.credits {
+		if credit.MovieID == id {
+			delete(s.credits, creditID)
+		}
+	}
+
+	for reviewID, review := range s.reviews {
+		if review.MovieID == id {
+			delete(s.reviews, reviewID)
+		}
+	}
+
+	for revisionID, revision := range s.revisions {
+		if revision.MovieID == id {
+			delete(s.revisions, revisionID)
+		}
+	}
+
+	for listID, entries := range s.listMovies {
+		s.listMovies[listID] = slices.DeleteFunc(entries, func(e listEntry) bool { return e.movieID == id })
+	}
+
+	for imageID, image := range s.images {
+		if image.MovieID == id {
+			delete(s.images, imageID)
+		}
+	}
+}
+
+func (m MemoryMovieModel) GetAll(ctx context.Context, movieFilters MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	column, direction := filters.sortColumn(), movieSortDirection(filters)
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := m.store.findMovies(movieFilters)
+
+	// ORDER BY <column> <direction>, id ASC
+	compare := func(a, b *Movie) int {
+		c := compareMovieColumn(a, b, column)
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	}
+	slices.SortFunc(matches, compare)
+
+	if filters.Keyset {
+		return memoryKeysetPage(matches, filters, compare)
+	}
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+func (m MemoryMovieModel) Export(ctx context.Context, movieFilters MovieFilters, fn func(movie *Movie) error) error {
+	m.store.mu.RLock()
+	matches := m.store.findMovies(movieFilters)
+	m.store.mu.RUnlock()
+
+	// the lock is released before calling fn, which may take its time writing out
+	slices.SortFunc(matches, func(a, b *Movie) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	for _, movie := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MemoryMovieModel) Suggest(ctx context.Context, search string, limit int) ([]*MovieSuggestion, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	suggestions := []*MovieSuggestion{}
+	for _, movie := range m.store.movies {
+		if movie.DeletedAt != nil {
+			continue
+		}
+
+		similarity := wordSimilarity(search, movie.Title)
+		if similarity >= wordSimilarityThreshold {
+			suggestions = append(suggestions, &MovieSuggestion{ID: movie.ID, Title: movie.Title, Year: movie.Year, Similarity: similarity})
+		}
+	}
+
+	// ORDER BY similarity DESC, id ASC
+	slices.SortFunc(suggestions, func(a, b *MovieSuggestion) int {
+		return cmp.Or(cmp.Compare(b.Similarity, a.Similarity), cmp.Compare(a.ID, b.ID))
+	})
+
+	return suggestions[:min(limit, len(suggestions))], nil
+}
+
+func (m MemoryMovieModel) Facets(ctx context.Context, movieFilters MovieFilters, facets []string) (map[string][]FacetCount, error) {
+	m.store.mu.RLock()
+	matches := m.store.findMovies(movieFilters)
+	m.store.mu.RUnlock()
+
+	return countFacets(matches, facets), nil
+}
+
+// countFacets is Facets over movies that were already found.
+func countFacets(matches []*Movie, facets []string) map[string][]FacetCount {
+	counts := make(map[string][]FacetCount, len(facets))
+	for _, facet := range facets {
+		buckets := []FacetCount{}
+		switch facet {
+		case MovieFacetGenres:
+			perGenre := make(map[string]int)
+			for _, movie := range matches {
+				for _, genre := range movie.Genres {
+					perGenre[genre]++
+				}
+			}
+			for genre, count := range perGenre {
+				buckets = append(buckets, FacetCount{Value: genre, Count: count})
+			}
+			// ORDER BY count DESC, genre ASC
+			slices.SortFunc(buckets, func(a, b FacetCount) int {
+				return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Value.(string), b.Value.(string)))
+			})
+		case MovieFacetYear:
+			perYear := make(map[int32]int)
+			for _, movie := range matches {
+				perYear[movie.Year]++
+			}
+			for year, count := range perYear {
+				buckets = append(buckets, FacetCount{Value: year, Count: count})
+			}
+			// ORDER BY year DESC
+			slices.SortFunc(buckets, func(a, b FacetCount) int {
+				return cmp.Compare(b.Value.(int32), a.Value.(int32))
+			})
+		default:
+			panic("unsafe facet: " + facet)
+		}
+		counts[facet] = buckets
+	}
+
+	return counts
+}
+
+// matchesGenres reports whether a movie has all of the genres of the filters or, when
+// they are matched with GenresMatchAny, any of them.
+func matchesGenres(genres []string, f MovieFilters) bool {
+	if f.GenresMatch != GenresMatchAny {
+		return containsAll(genres, f.Genres)
+	}
+	return slices.ContainsFunc(f.Genres, func(genre string) bool { return slices.Contains(genres, genre) })
+}
+
+// inMovieRanges reports whether a movie falls within the year, runtime and date ranges
+// of the filters.
+func inMovieRanges(movie Movie, f MovieFilters) bool {
+	switch {
+	case f.YearMin != 0 && movie.Year < f.YearMin,
+		f.YearMax != 0 && movie.Year > f.YearMax,
+		f.RuntimeMin != 0 && movie.Runtime < f.RuntimeMin,
+		f.RuntimeMax != 0 && movie.Runtime > f.RuntimeMax,
+		!f.CreatedAfter.IsZero() && !movie.CreatedAt.After(f.CreatedAfter),
+		!f.CreatedBefore.IsZero() && !movie.CreatedAt.Before(f.CreatedBefore),
+		!f.UpdatedAfter.IsZero() && !movie.UpdatedAt.After(f.UpdatedAfter),
+		!f.UpdatedBefore.IsZero() && !movie.UpdatedAt.Before(f.UpdatedBefore):
+		return false
+	}
+	return true
+}
+
+// findMovies returns copies of the live movies matching the filters, in no particular
+// order, with their relevance and headline set for a full-text search. The caller
+// must hold the lock.
+func (s *memoryStore) findMovies(f MovieFilters) []*Movie {
+	queryWords := simpleTSVector(f.Title)
+	search := newMemorySearch(f)
+	matches := []*Movie{}
+
+	for _, movie := range s.movies {
+		if movie.DeletedAt != nil {
+			continue
+		}
+		if len(f.IDs) > 0 && !slices.Contains(f.IDs, movie.ID) {
+			continue
+		}
+		if f.Title != "" && !slices.ContainsFunc(s.allTitles(movie), func(title string) bool {
+			return containsAll(simpleTSVector(title), queryWords)
+		}) {
+			continue
+		}
+		if len(f.Genres) > 0 && !matchesGenres(movie.Genres, f) {
+			continue
+		}
+		if f.PersonID != 0 && !s.hasCredit(movie.ID, f.PersonID) {
+			continue
+		}
+		if f.CollectionID != 0 && !s.inCollection(f.CollectionID, movie.ID) {
+			continue
+		}
+		if !inMovieRanges(movie, f) {
+			continue
+		}
+
+		match := copyMovie(movie)
+		if search != nil {
+			relevance, ok := search.rank(movie)
+			for _, title := range s.movieTitles[movie.ID] {
+				if alternate, found := search.rank(Movie{Title: title}); found {
+					relevance, ok = max(relevance, alternate), true
+				}
+			}
+			if !ok {
+				continue
+			}
+			match.Relevance = relevance
+			match.Headline = search.headline(movie.Title)
+		}
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// allTitles returns the title of the movie followed by its alternate titles. The caller
+// must hold the lock.
+func (s *memoryStore) allTitles(movie Movie) []string {
+	titles := []string{movie.Title}
+	for _, title := range s.movieTitles[movie.ID] {
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+// memoryKeysetPage picks the rows after (or before) the cursor out of the sorted
+// matches, in the same order the keyset query in MovieModel.getAllByCursor() would
+// return them.
+func memoryKeysetPage(matches []*Movie, filters Filters, compare func(a, b *Movie) int) ([]*Movie, Metadata, error) {
+	if filters.Cursor != nil {
+		after, err := movieFromCursor(filters)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		i := slices.IndexFunc(matches, func(movie *Movie) bool {
+			if filters.Cursor.Backward {
+				return compare(movie, after) >= 0
+			}
+			return compare(movie, after) > 0
+		})
+		if i == -1 {
+			i = len(matches)
+		}
+
+		if filters.Cursor.Backward {
+			matches = matches[:i]
+			slices.Reverse(matches)
+		} else {
+			matches = matches[i:]
+		}
+	}
+
+	matches = matches[:min(len(matches), filters.limit()+1)]
+	movies, metadata := keysetPage(matches, filters)
+	return movies, metadata, nil
+}
+
+func compareMovieColumn(a, b *Movie, column string) int {
+	switch column {
+	case "id":
+		return cmp.Compare(a.ID, b.ID)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	case "title":
+		return strings.Compare(a.Title, b.Title)
+	case "year":
+		return cmp.Compare(a.Year, b.Year)
+	case "runtime":
+		return cmp.Compare(a.Runtime, b.Runtime)
+	case "average_rating":
+		return cmp.Compare(a.AverageRating, b.AverageRating)
+	case "rating_count":
+		return cmp.Compare(a.RatingCount, b.RatingCount)
+	case "relevance":
+		return cmp.Compare(a.Relevance, b.Relevance)
+	default:
+		panic("unsupported sort column: " + column)
+	}
+}
+
+// simpleTSVector approximates to_tsvector('simple', s): the text is split into
+// lowercase words on anything that isn't a letter or a digit.
+func simpleTSVector(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsAll reports whether every value in subset is present in set, which is what
+// both the tsquery match and the genres @> operator check for.
+func containsAll(set, subset []string) bool {
+	for _, value := range subset {
+		if !slices.Contains(set, value) {
+			return false
+		}
+	}
+	return true
+}
+
+type MemoryUserModel struct {
+	store *memoryStore
+}
+
+// emailTaken reports whether another user already has the email address. Emails are
+// compared case-insensitively, like the citext column in the users table.
+func (s *memoryStore) emailTaken(email string, exceptID int64) bool {
+	for id, user := range s.users {
+		if id != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MemoryUserModel) Insert(ctx context.Context, user *User) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	m.store.lastUserID++
+
+	user.ID = m.store.lastUserID
+	user.CreatedAt = time.Now()
+	user.Version = 1
+
+	m.store.users[user.ID] = *user
+	return nil
+}
+
+func (m MemoryUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, user := range m.store.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryUserModel) Update(ctx context.Context, user *User) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.emailTaken(user.Email, user.ID) {
+		return ErrDuplicateEmail
+	}
+
+	existing, ok := m.store.users[user.ID]
+	if !ok || existing.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	user.Version++
+	m.store.users[user.ID] = *user
+	return nil
+}
+
+func (m MemoryUserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	token, ok := m.store.tokens[string(tokenHash[:])]
+	if !ok || token.Scope != tokenScope || !token.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+
+	user, ok := m.store.users[token.UserID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &user, nil
+}
+
+type MemoryTokenModel struct {
+	store *memoryStore
+}
+
+func (m MemoryTokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token := generateToken(userID, ttl, scope)
+	err := m.Insert(ctx, token)
+	return token, err
+}
+
+func (m MemoryTokenModel) Insert(ctx context.Context, token *Token) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.tokens[string(token.Hash)] = *token
+	return nil
+}
+
+func (m MemoryTokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for hash, token := range m.store.tokens {
+		if token.Scope == scope && token.UserID == userID {
+			delete(m.store.tokens, hash)
+		}
+	}
+	return nil
+}
+
+type MemoryPermissionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryPermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	return slices.Clone(Permissions(m.store.userPermissions[userID])), nil
+}
+
+func (m MemoryPermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	// like the INSERT ... SELECT in PermissionModel.AddForUser(), codes that aren't in
+	// the permissions table are silently skipped
+	for _, code := range codes {
+		if !slices.Contains(m.store.permissions, code) || slices.Contains(m.store.userPermissions[userID], code) {
+			continue
+		}
+		m.store.userPermissions[userID] = append(m.store.userPermissions[userID], code)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+type MemoryCollectionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryCollectionModel) Insert(ctx context.Context, collection *Collection) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.collectionNameTaken(collection.Name, 0) {
+		return ErrDuplicateCollectionName
+	}
+
+	m.store.lastCollectionID++
+
+	now := time.Now()
+	collection.ID = m.store.lastCollectionID
+	collection.CreatedAt = now
+	collection.UpdatedAt = now
+	collection.Version = 1
+	collection.MovieCount = 0
+
+	m.store.collections[collection.ID] = *collection
+	return nil
+}
+
+func (m MemoryCollectionModel) Get(ctx context.Context, id int64) (*Collection, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	collection, ok := m.store.collections[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	collection.MovieCount = m.store.liveEntryCount(m.store.collectionMovies[id])
+	return &collection, nil
+}
+
+func (m MemoryCollectionModel) Update(ctx context.Context, collection *Collection) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	existing, ok := m.store.collections[collection.ID]
+	if !ok || existing.Version != collection.Version {
+		return ErrEditConflict
+	}
+	if m.store.collectionNameTaken(collection.Name, collection.ID) {
+		return ErrDuplicateCollectionName
+	}
+
+	existing.Name = collection.Name
+	existing.Kind = collection.Kind
+	existing.Description = collection.Description
+	existing.Version++
+	existing.UpdatedAt = time.Now()
+
+	collection.Version = existing.Version
+	collection.UpdatedAt = existing.UpdatedAt
+
+	m.store.collections[collection.ID] = existing
+	return nil
+}
+
+func (m MemoryCollectionModel) Delete(ctx context.Context, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.collections[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.collections, id)
+	delete(m.store.collectionMovies, id)
+	return nil
+}
+
+func (m MemoryCollectionModel) GetAll(ctx context.Context, name, kind string, filters Filters) ([]*Collection, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	queryWords := simpleTSVector(name)
+	matches := []*Collection{}
+
+	for _, collection := range m.store.collections {
+		if name != "" && !containsAll(simpleTSVector(collection.Name), queryWords) {
+			continue
+		}
+		if kind != "" && collection.Kind != kind {
+			continue
+		}
+		collection.MovieCount = m.store.liveEntryCount(m.store.collectionMovies[collection.ID])
+		matches = append(matches, &collection)
+	}
+
+	slices.SortFunc(matches, func(a, b *Collection) int {
+		var c int
+		switch column {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		default:
+			panic("unsupported sort column: " + column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+func (m MemoryCollectionModel) GetMovies(ctx context.Context, collectionID int64, filters Filters) ([]*CollectionItem, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	items := []*CollectionItem{}
+	for _, entry := range m.store.collectionMovies[collectionID] {
+		movie, ok := m.store.liveMovie(entry.movieID)
+		if !ok {
+			continue
+		}
+		items = append(items, &CollectionItem{Position: len(items) + 1, AddedAt: entry.addedAt, Movie: copyMovie(movie)})
+	}
+
+	slices.SortFunc(items, func(a, b *CollectionItem) int {
+		var c int
+		switch column {
+		case "position":
+			c = cmp.Compare(a.Position, b.Position)
+		case "added_at":
+			c = a.AddedAt.Compare(b.AddedAt)
+		default:
+			c = compareMovieColumn(a.Movie, b.Movie, column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Movie.ID, b.Movie.ID)
+	})
+
+	totalRecords := len(items)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items[start:end], metadata, nil
+}
+
+func (m MemoryCollectionModel) AddMovie(ctx context.Context, collectionID, movieID int64, position int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.reorderCollection(collectionID, func(movieIDs []int64) ([]int64, error) {
+		if slices.Contains(movieIDs, movieID) {
+			return nil, ErrDuplicateCollectionMovie
+		}
+		if _, ok := m.store.liveMovie(movieID); !ok {
+			return nil, ErrRecordNotFound
+		}
+		return slices.Insert(movieIDs, insertIndex(position, len(movieIDs)), movieID), nil
+	})
+}
+
+func (m MemoryCollectionModel) MoveMovie(ctx context.Context, collectionID, movieID int64, position int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.reorderCollection(collectionID, func(movieIDs []int64) ([]int64, error) {
+		return moveListMovie(movieIDs, movieID, position)
+	})
+}
+
+func (m MemoryCollectionModel) RemoveMovie(ctx context.Context, collectionID, movieID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.reorderCollection(collectionID, func(movieIDs []int64) ([]int64, error) {
+		i := slices.Index(movieIDs, movieID)
+		if i == -1 {
+			return nil, ErrRecordNotFound
+		}
+		return slices.Delete(movieIDs, i, i+1), nil
+	})
+}
+
+// reorderCollection mirrors CollectionModel.reorder(). The caller must hold the lock.
+func (s *memoryStore) reorderCollection(collectionID int64, change func(movieIDs []int64) ([]int64, error)) error {
+	collection, ok := s.collections[collectionID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	reordered, err := s.reorderEntries(s.collectionMovies[collectionID], change)
+	if err != nil {
+		return err
+	}
+	s.collectionMovies[collectionID] = reordered
+
+	collection.UpdatedAt = time.Now()
+	s.collections[collectionID] = collection
+	return nil
+}
+
+// collectionNameTaken reports whether a collection other than exceptID has the given
+// name, like the collections_name_key constraint. The caller must hold the lock.
+func (s *memoryStore) collectionNameTaken(name string, exceptID int64) bool {
+	for _, collection := range s.collections {
+		if collection.Name == name && collection.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// inCollection reports whether the movie is in the collection. The caller must hold
+// the lock.
+func (s *memoryStore) inCollection(collectionID, movieID int64) bool {
+	for _, entry := range s.collectionMovies[collectionID] {
+		if entry.movieID == movieID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+func (m MemoryMovieModel) FindDuplicate(ctx context.Context, movie *Movie) (*Movie, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	title := normalizeTitle(movie.Title)
+
+	var duplicate *Movie
+	for _, other := range m.store.movies {
+		if other.ID == movie.ID || other.DeletedAt != nil || other.Year != movie.Year || normalizeTitle(other.Title) != title {
+			continue
+		}
+		if duplicate == nil || other.ID < duplicate.ID {
+			duplicate = copyMovie(other)
+		}
+	}
+
+	if duplicate == nil {
+		return nil, ErrRecordNotFound
+	}
+	return duplicate, nil
+}
+
+func (m MemoryMovieModel) Merge(ctx context.Context, targetID int64, duplicateIDs []int64, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.liveMovie(targetID); !ok {
+		return ErrRecordNotFound
+	}
+	for _, duplicateID := range duplicateIDs {
+		if _, ok := m.store.liveMovie(duplicateID); !ok {
+			return ErrRecordNotFound
+		}
+	}
+
+	now := time.Now()
+	for _, duplicateID := range duplicateIDs {
+		duplicate := m.store.movies[duplicateID]
+		duplicate.DeletedAt = &now
+		m.store.movies[duplicateID] = duplicate
+
+		revision := NewMovieRevision(RevisionDelete, &duplicate, nil)
+		revision.UserID = userID
+		m.store.addRevision(revision)
+
+		for id, credit := range m.store.credits {
+			if credit.MovieID == duplicateID && !m.store.hasSameCredit(targetID, credit) {
+				credit.MovieID = targetID
+				m.store.credits[id] = credit
+			}
+		}
+
+		for id, review := range m.store.reviews {
+			if review.MovieID == duplicateID && !m.store.hasReview(targetID, review.UserID) {
+				review.MovieID = targetID
+				m.store.reviews[id] = review
+			}
+		}
+
+		for source, externalID := range m.store.externalIDs[duplicateID] {
+			if _, ok := m.store.externalIDs[targetID][source]; ok {
+				continue
+			}
+			if m.store.externalIDs[targetID] == nil {
+				m.store.externalIDs[targetID] = make(map[string]string)
+			}
+			m.store.externalIDs[targetID][source] = externalID
+			delete(m.store.externalIDs[duplicateID], source)
+		}
+
+		for locale, title := range m.store.movieTitles[duplicateID] {
+			if _, ok := m.store.movieTitles[targetID][locale]; ok {
+				continue
+			}
+			if m.store.movieTitles[targetID] == nil {
+				m.store.movieTitles[targetID] = make(map[string]string)
+			}
+			m.store.movieTitles[targetID][locale] = title
+			delete(m.store.movieTitles[duplicateID], locale)
+		}
+
+		for country, release := range m.store.movieReleases[duplicateID] {
+			if _, ok := m.store.movieReleases[targetID][country]; ok {
+				continue
+			}
+			if m.store.movieReleases[targetID] == nil {
+				m.store.movieReleases[targetID] = make(map[string]MovieRelease)
+			}
+			release.MovieID = targetID
+			m.store.movieReleases[targetID][country] = release
+			delete(m.store.movieReleases[duplicateID], country)
+		}
+
+		for id, image := range m.store.images {
+			if image.MovieID == duplicateID {
+				image.MovieID = targetID
+				m.store.images[id] = image
+			}
+		}
+
+		for listID, entries := range m.store.listMovies {
+			if m.store.listHasMovie(listID, targetID) {
+				continue
+			}
+			for i, entry := range entries {
+				if entry.movieID == duplicateID {
+					entries[i].movieID = targetID
+				}
+			}
+		}
+
+		for collectionID, entries := range m.store.collectionMovies {
+			if m.store.inCollection(collectionID, targetID) {
+				continue
+			}
+			for i, entry := range entries {
+				if entry.movieID == duplicateID {
+					entries[i].movieID = targetID
+				}
+			}
+		}
+	}
+
+	for _, id := range append([]int64{targetID}, duplicateIDs...) {
+		m.store.computeMovieRating(id)
+	}
+
+	target := m.store.movies[targetID]
+	target.Version++
+	target.UpdatedAt = now
+	m.store.movies[targetID] = target
+	return nil
+}
+
+// hasSameCredit reports whether the movie already has a credit for the same person,
+// role and character. The caller must hold the lock.
+func (s *memoryStore) hasSameCredit(movieID int64, credit Credit) bool {
+	for _, existing := range s.credits {
+		if existing.MovieID == movieID && existing.PersonID == credit.PersonID &&
+			existing.Role == credit.Role && existing.Character == credit.Character {
+			return true
+		}
+	}
+	return false
+}
+
+// hasReview reports whether the user has reviewed the movie. The caller must hold the
+// lock.
+func (s *memoryStore) hasReview(movieID, userID int64) bool {
+	for _, review := range s.reviews {
+		if review.MovieID == movieID && review.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// listHasMovie reports whether the movie is in the list, even if it is in the trash.
+// The caller must hold the lock.
+func (s *memoryStore) listHasMovie(listID, movieID int64) bool {
+	for _, entry := range s.listMovies[listID] {
+		if entry.movieID == movieID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+type MemoryExternalIDModel struct {
+	store *memoryStore
+}
+
+func (m MemoryExternalIDModel) Set(ctx context.Context, externalID *ExternalID) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for movieID, ids := range m.store.externalIDs {
+		if movieID != externalID.MovieID && ids[externalID.Source] == externalID.ExternalID {
+			return ErrDuplicateExternalID
+		}
+	}
+
+	if m.store.externalIDs[externalID.MovieID] == nil {
+		m.store.externalIDs[externalID.MovieID] = make(map[string]string)
+	}
+	m.store.externalIDs[externalID.MovieID][externalID.Source] = externalID.ExternalID
+	return nil
+}
+
+func (m MemoryExternalIDModel) Delete(ctx context.Context, movieID int64, source string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.externalIDs[movieID][source]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.externalIDs[movieID], source)
+	return nil
+}
+
+func (m MemoryExternalIDModel) GetMovieID(ctx context.Context, source, externalID string) (int64, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for movieID, ids := range m.store.externalIDs {
+		if id, ok := ids[source]; ok && id == externalID {
+			return movieID, nil
+		}
+	}
+	return 0, ErrRecordNotFound
+}
+
+func (m MemoryExternalIDModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*ExternalID, error) {
+	externalIDs, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*ExternalID{}, externalIDs[movieID]...), nil
+}
+
+func (m MemoryExternalIDModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*ExternalID, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	externalIDs := make(map[int64][]*ExternalID)
+	for _, movieID := range movieIDs {
+		for source, id := range m.store.externalIDs[movieID] {
+			externalIDs[movieID] = append(externalIDs[movieID], &ExternalID{MovieID: movieID, Source: source, ExternalID: id})
+		}
+		slices.SortFunc(externalIDs[movieID], func(a, b *ExternalID) int {
+			return strings.Compare(a.Source, b.Source)
+		})
+	}
+	return externalIDs, nil
+}
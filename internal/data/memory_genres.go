@@ -0,0 +1,111 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+type MemoryGenreModel struct {
+	store *memoryStore
+}
+
+// copyGenre returns a copy of the genre that does not share its aliases slice.
+func copyGenre(genre Genre) *Genre {
+	genre.Aliases = slices.Clone(genre.Aliases)
+	return &genre
+}
+
+func (m MemoryGenreModel) GetAll(ctx context.Context) ([]*Genre, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	genres := []*Genre{}
+	for _, genre := range m.store.genres {
+		genres = append(genres, copyGenre(genre))
+	}
+
+	slices.SortFunc(genres, func(a, b *Genre) int {
+		return cmp.Compare(a.Slug, b.Slug)
+	})
+	return genres, nil
+}
+
+func (m MemoryGenreModel) GetIndex(ctx context.Context) (GenreIndex, error) {
+	genres, err := m.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewGenreIndex(genres), nil
+}
+
+func (m MemoryGenreModel) Get(ctx context.Context, slug string) (*Genre, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	genre, ok := m.store.genres[slug]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyGenre(genre), nil
+}
+
+func (m MemoryGenreModel) Insert(ctx context.Context, genre *Genre) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.genres[genre.Slug]; ok {
+		return ErrDuplicateGenre
+	}
+
+	m.store.lastGenreID++
+
+	genre.ID = m.store.lastGenreID
+	genre.Version = 1
+
+	m.store.genres[genre.Slug] = *copyGenre(*genre)
+	return nil
+}
+
+func (m MemoryGenreModel) Update(ctx context.Context, genre *Genre) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	existing, ok := m.store.genres[genre.Slug]
+	if !ok || existing.Version != genre.Version {
+		return ErrEditConflict
+	}
+
+	genre.ID = existing.ID
+	genre.Version++
+
+	m.store.genres[genre.Slug] = *copyGenre(*genre)
+	return nil
+}
+
+func (m MemoryGenreModel) Delete(ctx context.Context, slug string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.genres[slug]; !ok {
+		return ErrRecordNotFound
+	}
+
+	for _, movie := range m.store.movies {
+		if slices.Contains(movie.Genres, slug) {
+			return ErrGenreInUse
+		}
+	}
+
+	delete(m.store.genres, slug)
+
+	// like ON DELETE SET NULL on the parent column
+	for _, genre := range m.store.genres {
+		if genre.Parent == slug {
+			genre.Parent = ""
+			m.store.genres[genre.Slug] = genre
+		}
+	}
+	return nil
+}
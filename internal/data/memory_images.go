@@ -0,0 +1,125 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+)
+
+type MemoryMovieImageModel struct {
+	store *memoryStore
+}
+
+// copyImage returns a copy of the image that does not share its thumbnails with the
+// stored record.
+func copyImage(image MovieImage) *MovieImage {
+	thumbnails := make([]*ImageThumbnail, len(image.Thumbnails))
+	for i, thumbnail := range image.Thumbnails {
+		copied := *thumbnail
+		thumbnails[i] = &copied
+	}
+	image.Thumbnails = thumbnails
+	return &image
+}
+
+func (m MemoryMovieImageModel) Insert(ctx context.Context, image *MovieImage) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.lastImageID++
+	image.ID = m.store.lastImageID
+	image.CreatedAt = time.Now()
+
+	m.store.images[image.ID] = *copyImage(*image)
+	return nil
+}
+
+func (m MemoryMovieImageModel) Get(ctx context.Context, movieID, id int64) (*MovieImage, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	image, ok := m.store.images[id]
+	if !ok || image.MovieID != movieID {
+		return nil, ErrRecordNotFound
+	}
+	return copyImage(image), nil
+}
+
+func (m MemoryMovieImageModel) GetByStorageKey(ctx context.Context, key string) (*MovieImage, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, image := range m.store.images {
+		if _, ok := m.store.liveMovie(image.MovieID); !ok {
+			continue
+		}
+
+		if image.StorageKey == key || slices.ContainsFunc(image.Thumbnails, func(thumbnail *ImageThumbnail) bool {
+			return thumbnail.StorageKey == key
+		}) {
+			return copyImage(image), nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryMovieImageModel) Delete(ctx context.Context, movieID, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	image, ok := m.store.images[id]
+	if !ok || image.MovieID != movieID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.images, id)
+	return nil
+}
+
+func (m MemoryMovieImageModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieImage, error) {
+	images, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*MovieImage{}, images[movieID]...), nil
+}
+
+func (m MemoryMovieImageModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieImage, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	images := make(map[int64][]*MovieImage)
+	for _, image := range m.store.images {
+		if slices.Contains(movieIDs, image.MovieID) {
+			images[image.MovieID] = append(images[image.MovieID], copyImage(image))
+		}
+	}
+
+	for _, movieImages := range images {
+		slices.SortFunc(movieImages, func(a, b *MovieImage) int {
+			// posters before backdrops, like ORDER BY kind DESC
+			return cmp.Or(cmp.Compare(b.Kind, a.Kind), cmp.Compare(a.ID, b.ID))
+		})
+	}
+	return images, nil
+}
+
+func (m MemoryMovieImageModel) GetAllDeletedBefore(ctx context.Context, before time.Time) ([]*MovieImage, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	var images []*MovieImage
+	for _, image := range m.store.images {
+		movie := m.store.movies[image.MovieID]
+		if movie.DeletedAt != nil && movie.DeletedAt.Before(before) {
+			images = append(images, copyImage(image))
+		}
+	}
+
+	slices.SortFunc(images, func(a, b *MovieImage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return images, nil
+}
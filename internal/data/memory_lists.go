@@ -0,0 +1,296 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// listEntry is a row of lists_movies, or of collections_movies. The entries of a list
+// are kept in list order.
+type listEntry struct {
+	movieID int64
+	addedAt time.Time
+}
+
+type MemoryListModel struct {
+	store *memoryStore
+}
+
+func (m MemoryListModel) Insert(ctx context.Context, list *List) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.listNameTaken(list.UserID, list.Name, 0) {
+		return ErrDuplicateListName
+	}
+
+	m.store.lastListID++
+
+	now := time.Now()
+	list.ID = m.store.lastListID
+	list.CreatedAt = now
+	list.UpdatedAt = now
+	list.Version = 1
+	list.MovieCount = 0
+
+	m.store.lists[list.ID] = *list
+	return nil
+}
+
+func (m MemoryListModel) Get(ctx context.Context, id int64) (*List, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	list, ok := m.store.lists[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	list.MovieCount = m.store.listMovieCount(id)
+	return &list, nil
+}
+
+func (m MemoryListModel) Update(ctx context.Context, list *List) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	existing, ok := m.store.lists[list.ID]
+	if !ok || existing.Version != list.Version {
+		return ErrEditConflict
+	}
+	if m.store.listNameTaken(existing.UserID, list.Name, list.ID) {
+		return ErrDuplicateListName
+	}
+
+	existing.Name = list.Name
+	existing.Description = list.Description
+	existing.Public = list.Public
+	existing.Version++
+	existing.UpdatedAt = time.Now()
+
+	list.Version = existing.Version
+	list.UpdatedAt = existing.UpdatedAt
+
+	m.store.lists[list.ID] = existing
+	return nil
+}
+
+func (m MemoryListModel) Delete(ctx context.Context, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.lists[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.lists, id)
+	delete(m.store.listMovies, id)
+	return nil
+}
+
+func (m MemoryListModel) GetAllForUser(ctx context.Context, userID int64, filters Filters) ([]*List, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := []*List{}
+	for _, list := range m.store.lists {
+		if list.UserID == userID {
+			list.MovieCount = m.store.listMovieCount(list.ID)
+			matches = append(matches, &list)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *List) int {
+		var c int
+		switch column {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		default:
+			panic("unsupported sort column: " + column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+func (m MemoryListModel) GetMovies(ctx context.Context, listID int64, filters Filters) ([]*ListItem, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	items := []*ListItem{}
+	for _, entry := range m.store.listMovies[listID] {
+		movie, ok := m.store.liveMovie(entry.movieID)
+		if !ok {
+			continue
+		}
+		items = append(items, &ListItem{Position: len(items) + 1, AddedAt: entry.addedAt, Movie: copyMovie(movie)})
+	}
+
+	slices.SortFunc(items, func(a, b *ListItem) int {
+		var c int
+		switch column {
+		case "position":
+			c = cmp.Compare(a.Position, b.Position)
+		case "added_at":
+			c = a.AddedAt.Compare(b.AddedAt)
+		default:
+			c = compareMovieColumn(a.Movie, b.Movie, column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Movie.ID, b.Movie.ID)
+	})
+
+	totalRecords := len(items)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items[start:end], metadata, nil
+}
+
+func (m MemoryListModel) AddMovie(ctx context.Context, listID, movieID int64, position int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.reorderList(listID, func(movieIDs []int64) ([]int64, error) {
+		if slices.Contains(movieIDs, movieID) {
+			return nil, ErrDuplicateListMovie
+		}
+		if _, ok := m.store.liveMovie(movieID); !ok {
+			return nil, ErrRecordNotFound
+		}
+		return slices.Insert(movieIDs, insertIndex(position, len(movieIDs)), movieID), nil
+	})
+}
+
+func (m MemoryListModel) MoveMovie(ctx context.Context, listID, movieID int64, position int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.reorderList(listID, func(movieIDs []int64) ([]int64, error) {
+		return moveListMovie(movieIDs, movieID, position)
+	})
+}
+
+func (m MemoryListModel) RemoveMovie(ctx context.Context, listID, movieID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.reorderList(listID, func(movieIDs []int64) ([]int64, error) {
+		i := slices.Index(movieIDs, movieID)
+		if i == -1 {
+			return nil, ErrRecordNotFound
+		}
+		return slices.Delete(movieIDs, i, i+1), nil
+	})
+}
+
+// reorderList mirrors ListModel.reorder(): change receives the ids of the movies in the
+// list that are not in the trash, in order, and the entries are rearranged, added or
+// dropped to match the ids it returns. The caller must hold the lock.
+func (s *memoryStore) reorderList(listID int64, change func(movieIDs []int64) ([]int64, error)) error {
+	list, ok := s.lists[listID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	reordered, err := s.reorderEntries(s.listMovies[listID], change)
+	if err != nil {
+		return err
+	}
+	s.listMovies[listID] = reordered
+
+	list.UpdatedAt = time.Now()
+	s.lists[listID] = list
+	return nil
+}
+
+// reorderEntries passes the ids of the live movies among entries to change and returns
+// the entries rearranged to match the ids it returns, with new entries for ids that
+// weren't there and the movies in the trash kept at the end. The caller must hold the
+// lock.
+func (s *memoryStore) reorderEntries(entries []listEntry, change func(movieIDs []int64) ([]int64, error)) ([]listEntry, error) {
+	byMovie := make(map[int64]listEntry)
+	var movieIDs, trashedIDs []int64
+	for _, entry := range entries {
+		byMovie[entry.movieID] = entry
+		if _, ok := s.liveMovie(entry.movieID); ok {
+			movieIDs = append(movieIDs, entry.movieID)
+		} else {
+			trashedIDs = append(trashedIDs, entry.movieID)
+		}
+	}
+
+	movieIDs, err := change(movieIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reordered := make([]listEntry, 0, len(movieIDs)+len(trashedIDs))
+	for _, movieID := range append(movieIDs, trashedIDs...) {
+		entry, ok := byMovie[movieID]
+		if !ok {
+			entry = listEntry{movieID: movieID, addedAt: now}
+		}
+		reordered = append(reordered, entry)
+	}
+	return reordered, nil
+}
+
+// listMovieCount counts the movies in a list that are not in the trash. The caller must
+// hold the lock.
+func (s *memoryStore) listMovieCount(listID int64) int32 {
+	return s.liveEntryCount(s.listMovies[listID])
+}
+
+// liveEntryCount counts the entries whose movie is not in the trash. The caller must
+// hold the lock.
+func (s *memoryStore) liveEntryCount(entries []listEntry) int32 {
+	var count int32
+	for _, entry := range entries {
+		if _, ok := s.liveMovie(entry.movieID); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// listNameTaken reports whether the user has a list other than exceptID with the given
+// name, like the lists_user_name_key constraint. The caller must hold the lock.
+func (s *memoryStore) listNameTaken(userID int64, name string, exceptID int64) bool {
+	for _, list := range s.lists {
+		if list.UserID == userID && list.Name == name && list.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
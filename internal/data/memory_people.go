@@ -0,0 +1,209 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+type MemoryPersonModel struct {
+	store *memoryStore
+}
+
+func (m MemoryPersonModel) Insert(ctx context.Context, person *Person) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.lastPersonID++
+
+	person.ID = m.store.lastPersonID
+	person.CreatedAt = time.Now()
+	person.Version = 1
+
+	m.store.people[person.ID] = *person
+	return nil
+}
+
+func (m MemoryPersonModel) Get(ctx context.Context, id int64) (*Person, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	person, ok := m.store.people[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &person, nil
+}
+
+func (m MemoryPersonModel) Update(ctx context.Context, person *Person) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	existing, ok := m.store.people[person.ID]
+	if !ok || existing.Version != person.Version {
+		return ErrEditConflict
+	}
+
+	person.Version++
+	person.CreatedAt = existing.CreatedAt
+
+	m.store.people[person.ID] = *person
+	return nil
+}
+
+func (m MemoryPersonModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.people[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.people, id)
+
+	for creditID, credit := range m.store.credits {
+		if credit.PersonID == id {
+			delete(m.store.credits, creditID)
+		}
+	}
+	return nil
+}
+
+func (m MemoryPersonModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Person, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	queryWords := simpleTSVector(name)
+	matches := []*Person{}
+
+	for _, person := range m.store.people {
+		if name != "" && !containsAll(simpleTSVector(person.Name), queryWords) {
+			continue
+		}
+		matches = append(matches, &person)
+	}
+
+	slices.SortFunc(matches, func(a, b *Person) int {
+		var c int
+		switch column {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "birth_year":
+			c = cmp.Compare(a.BirthYear, b.BirthYear)
+		default:
+			panic("unsupported sort column: " + column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+func (m MemoryPersonModel) AddCredit(ctx context.Context, credit *Credit) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	person, ok := m.store.people[credit.PersonID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	for _, existing := range m.store.credits {
+		if existing.MovieID == credit.MovieID && existing.PersonID == credit.PersonID &&
+			existing.Role == credit.Role && existing.Character == credit.Character {
+			return ErrDuplicateCredit
+		}
+	}
+
+	m.store.lastCreditID++
+
+	credit.ID = m.store.lastCreditID
+	credit.Name = person.Name
+
+	m.store.credits[credit.ID] = *credit
+	return nil
+}
+
+func (m MemoryPersonModel) DeleteCredit(ctx context.Context, movieID, creditID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	credit, ok := m.store.credits[creditID]
+	if !ok || credit.MovieID != movieID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.credits, creditID)
+	return nil
+}
+
+func (m MemoryPersonModel) GetCreditsForMovie(ctx context.Context, movieID int64) ([]*Credit, error) {
+	credits, err := m.GetCreditsForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*Credit{}, credits[movieID]...), nil
+}
+
+func (m MemoryPersonModel) GetCreditsForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*Credit, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	var matches []*Credit
+	for _, credit := range m.store.credits {
+		if slices.Contains(movieIDs, credit.MovieID) {
+			// the name always comes from the current person record, as with the JOIN
+			credit.Name = m.store.people[credit.PersonID].Name
+			matches = append(matches, &credit)
+		}
+	}
+
+	roles := []string{RoleDirector, RoleWriter, RoleActor}
+	slices.SortFunc(matches, func(a, b *Credit) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(roles, a.Role), slices.Index(roles, b.Role)),
+			cmp.Compare(a.BillingOrder, b.BillingOrder),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+
+	credits := make(map[int64][]*Credit)
+	for _, credit := range matches {
+		credits[credit.MovieID] = append(credits[credit.MovieID], credit)
+	}
+	return credits, nil
+}
+
+// hasCredit reports whether the person is credited on the movie in any role. The
+// caller must hold the lock.
+func (s *memoryStore) hasCredit(movieID, personID int64) bool {
+	for _, credit := range s.credits {
+		if credit.MovieID == movieID && credit.PersonID == personID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+)
+
+func (m MemoryMovieModel) GetSimilar(ctx context.Context, movie *Movie, filters Filters) ([]*Movie, Metadata, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := []*Movie{}
+	for _, other := range m.store.movies {
+		if other.ID == movie.ID || other.DeletedAt != nil || !overlaps(other.Genres, movie.Genres) {
+			continue
+		}
+
+		shared, all := 0, len(movie.Genres)
+		for _, genre := range other.Genres {
+			if slices.Contains(movie.Genres, genre) {
+				shared++
+			} else {
+				all++
+			}
+		}
+
+		match := copyMovie(other)
+		match.Relevance = roundRelevance(similarGenreWeight*float64(shared)/float64(all) +
+			similarYearWeight/(1+math.Abs(float64(other.Year-movie.Year))/similarYearScale) +
+			similarTitleWeight*trigramSimilarity(other.Title, movie.Title))
+		matches = append(matches, match)
+	}
+
+	return rankedPage(matches, filters)
+}
+
+func (m MemoryMovieModel) GetRecommended(ctx context.Context, userID int64, filters Filters) ([]*Movie, Metadata, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	// the weight of each movie the user rated or put on a list, then of each genre
+	activity := make(map[int64]float64)
+	for _, review := range m.store.reviews {
+		if review.UserID == userID {
+			activity[review.MovieID] += float64(review.Rating) - recommendationRatingMidpoint
+		}
+	}
+	for listID, entries := range m.store.listMovies {
+		if m.store.lists[listID].UserID != userID {
+			continue
+		}
+		for _, entry := range entries {
+			activity[entry.movieID] += recommendationListWeight
+		}
+	}
+
+	profile := make(map[string]float64)
+	for movieID, weight := range activity {
+		for _, genre := range m.store.movies[movieID].Genres {
+			profile[genre] += weight
+		}
+	}
+
+	var liked []string
+	var total float64
+	for genre, weight := range profile {
+		if weight > 0 {
+			liked = append(liked, genre)
+			total += weight
+		}
+	}
+
+	matches := []*Movie{}
+	for _, movie := range m.store.movies {
+		if _, ok := activity[movie.ID]; ok || movie.DeletedAt != nil || !overlaps(movie.Genres, liked) {
+			continue
+		}
+
+		var affinity float64
+		for _, genre := range movie.Genres {
+			affinity += profile[genre]
+		}
+
+		match := copyMovie(movie)
+		match.Relevance = roundRelevance(recommendationAffinityWeight*affinity/total +
+			recommendationRatingWeight*movie.AverageRating/10)
+		matches = append(matches, match)
+	}
+
+	return rankedPage(matches, filters)
+}
+
+// rankedPage sorts the movies by relevance, best first, and returns the page of them
+// the filters ask for.
+func rankedPage(matches []*Movie, filters Filters) ([]*Movie, Metadata, error) {
+	slices.SortFunc(matches, func(a, b *Movie) int {
+		return cmp.Or(cmp.Compare(b.Relevance, a.Relevance), cmp.Compare(a.ID, b.ID))
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+// roundRelevance rounds a score to 4 decimal places, like the queries do.
+func roundRelevance(relevance float64) float64 {
+	return math.Round(relevance*10000) / 10000
+}
+
+// overlaps reports whether a and b have an element in common, like the && operator on
+// arrays.
+func overlaps(a, b []string) bool {
+	return slices.ContainsFunc(a, func(s string) bool { return slices.Contains(b, s) })
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+)
+
+type MemoryMovieReleaseModel struct {
+	store *memoryStore
+}
+
+func (m MemoryMovieReleaseModel) Set(ctx context.Context, release *MovieRelease) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.movieReleases[release.MovieID] == nil {
+		m.store.movieReleases[release.MovieID] = make(map[string]MovieRelease)
+	}
+	m.store.movieReleases[release.MovieID][release.Country] = *release
+	return nil
+}
+
+func (m MemoryMovieReleaseModel) Delete(ctx context.Context, movieID int64, country string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.movieReleases[movieID][country]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.movieReleases[movieID], country)
+	return nil
+}
+
+func (m MemoryMovieReleaseModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRelease, error) {
+	releases, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*MovieRelease{}, releases[movieID]...), nil
+}
+
+func (m MemoryMovieReleaseModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieRelease, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	releases := make(map[int64][]*MovieRelease)
+	for _, movieID := range movieIDs {
+		for _, release := range m.store.movieReleases[movieID] {
+			releases[movieID] = append(releases[movieID], &release)
+		}
+		// dates in YYYY-MM-DD sort as strings
+		slices.SortFunc(releases[movieID], func(a, b *MovieRelease) int {
+			return cmp.Or(strings.Compare(a.ReleaseDate, b.ReleaseDate), strings.Compare(a.Country, b.Country))
+		})
+	}
+	return releases, nil
+}
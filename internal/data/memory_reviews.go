@@ -0,0 +1,171 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"time"
+)
+
+type MemoryReviewModel struct {
+	store *memoryStore
+}
+
+func (m MemoryReviewModel) Insert(ctx context.Context, review *Review) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for _, existing := range m.store.reviews {
+		if existing.MovieID == review.MovieID && existing.UserID == review.UserID {
+			return ErrDuplicateReview
+		}
+	}
+
+	m.store.lastReviewID++
+
+	now := time.Now()
+	review.ID = m.store.lastReviewID
+	review.CreatedAt = now
+	review.UpdatedAt = now
+	review.Version = 1
+	review.UserName = m.store.users[review.UserID].Name
+
+	m.store.reviews[review.ID] = *review
+	m.store.refreshMovieRating(review.MovieID)
+	return nil
+}
+
+func (m MemoryReviewModel) Get(ctx context.Context, movieID, id int64) (*Review, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	review, ok := m.store.reviews[id]
+	if !ok || review.MovieID != movieID {
+		return nil, ErrRecordNotFound
+	}
+
+	review.UserName = m.store.users[review.UserID].Name
+	return &review, nil
+}
+
+func (m MemoryReviewModel) Update(ctx context.Context, review *Review) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	existing, ok := m.store.reviews[review.ID]
+	if !ok || existing.Version != review.Version {
+		return ErrEditConflict
+	}
+
+	existing.Rating = review.Rating
+	existing.Body = review.Body
+	existing.Version++
+	existing.UpdatedAt = time.Now()
+
+	review.Version = existing.Version
+	review.UpdatedAt = existing.UpdatedAt
+
+	m.store.reviews[review.ID] = existing
+	m.store.refreshMovieRating(existing.MovieID)
+	return nil
+}
+
+func (m MemoryReviewModel) Delete(ctx context.Context, movieID, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	review, ok := m.store.reviews[id]
+	if !ok || review.MovieID != movieID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.reviews, id)
+	m.store.refreshMovieRating(movieID)
+	return nil
+}
+
+func (m MemoryReviewModel) GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := []*Review{}
+	for _, review := range m.store.reviews {
+		if review.MovieID == movieID {
+			review.UserName = m.store.users[review.UserID].Name
+			matches = append(matches, &review)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *Review) int {
+		var c int
+		switch column {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "rating":
+			c = cmp.Compare(a.Rating, b.Rating)
+		default:
+			panic("unsupported sort column: " + column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
+
+// refreshMovieRating recomputes the rating aggregates of a movie, doing the job of the
+// reviews_refresh_movie_rating() trigger: when they change, the movie gets a new
+// version. The caller must hold the lock.
+func (s *memoryStore) refreshMovieRating(movieID int64) {
+	if !s.computeMovieRating(movieID) {
+		return
+	}
+
+	movie := s.movies[movieID]
+	movie.Version++
+	movie.UpdatedAt = time.Now()
+	s.movies[movieID] = movie
+}
+
+// computeMovieRating recomputes the rating aggregates of a movie and reports whether
+// they changed. The caller must hold the lock.
+func (s *memoryStore) computeMovieRating(movieID int64) bool {
+	movie, ok := s.movies[movieID]
+	if !ok {
+		return false
+	}
+
+	var total, count int32
+	for _, review := range s.reviews {
+		if review.MovieID == movieID {
+			total += review.Rating
+			count++
+		}
+	}
+
+	var average float64
+	if count > 0 {
+		average = math.Round(float64(total)/float64(count)*100) / 100
+	}
+	if average == movie.AverageRating && count == movie.RatingCount {
+		return false
+	}
+
+	movie.AverageRating = average
+	movie.RatingCount = count
+	s.movies[movieID] = movie
+	return true
+}
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+)
+
+type MemoryMovieRevisionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryMovieRevisionModel) Insert(ctx context.Context, revision *MovieRevision) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.addRevision(revision)
+	return nil
+}
+
+// addRevision adds a revision to the history. The caller must hold the lock.
+func (s *memoryStore) addRevision(revision *MovieRevision) {
+	s.lastRevisionID++
+
+	revision.ID = s.lastRevisionID
+	revision.CreatedAt = time.Now()
+
+	s.revisions[revision.ID] = *revision
+}
+
+// recordRevision is the memory equivalent of recordRevision(), for changes made
+// through the store directly. The caller must hold the lock.
+func (s *memoryStore) recordRevision(revision *MovieRevision, movie *Movie) {
+	if revision == nil {
+		return
+	}
+
+	revision.MovieID = movie.ID
+	revision.Version = movie.Version
+	s.addRevision(revision)
+}
+
+func (m MemoryMovieRevisionModel) Get(ctx context.Context, movieID, id int64) (*MovieRevision, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	revision, ok := m.store.revisions[id]
+	if !ok || revision.MovieID != movieID {
+		return nil, ErrRecordNotFound
+	}
+
+	return &revision, nil
+}
+
+func (m MemoryMovieRevisionModel) GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*MovieRevision, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := []*MovieRevision{}
+	for _, revision := range m.store.revisions {
+		if revision.MovieID == movieID {
+			matches = append(matches, &revision)
+		}
+	}
+
+	// ORDER BY <column> <direction>, id <direction>
+	slices.SortFunc(matches, func(a, b *MovieRevision) int {
+		var c int
+		switch column {
+		case "created_at":
+			c = cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+		case "version":
+			c = cmp.Or(cmp.Compare(a.Version, b.Version), cmp.Compare(a.ID, b.ID))
+		default:
+			panic("unsupported sort column: " + column)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		return c
+	})
+
+	totalRecords := len(matches)
+	start := min(filters.offset(), totalRecords)
+	end := min(start+filters.limit(), totalRecords)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches[start:end], metadata, nil
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// memorySearch approximates the full-text search of MovieModel. Words are matched the
+// way the "simple" configuration would, whatever the language, so there is no
+// stemming and there are no stop words. A term found in the title counts for 1 and
+// one only found in the genres for 0.4, the default weights of ts_rank() for A and B.
+// In websearch mode "or" is ignored, so every term has to match.
+type memorySearch struct {
+	fuzzy    string // the search, in fuzzy mode
+	prefix   bool
+	terms    [][]string // each term is a run of words that must appear in that order
+	excluded []string
+}
+
+// newMemorySearch parses the full-text search of the filters, or returns nil if there
+// isn't one.
+func newMemorySearch(f MovieFilters) *memorySearch {
+	if f.Search == "" {
+		return nil
+	}
+
+	s := &memorySearch{}
+	switch f.SearchMode {
+	case SearchModeFuzzy:
+		s.fuzzy = f.Search
+		return s
+	case SearchModePhrase:
+		s.terms = [][]string{simpleTSVector(f.Search)}
+	case SearchModeWebsearch:
+		// the odd parts of a split on quotes are the quoted phrases
+		for i, part := range strings.Split(f.Search, `"`) {
+			if i%2 == 1 {
+				s.terms = append(s.terms, simpleTSVector(part))
+				continue
+			}
+			for _, word := range strings.Fields(part) {
+				switch {
+				case strings.EqualFold(word, "or"):
+				case strings.HasPrefix(word, "-"):
+					s.excluded = append(s.excluded, simpleTSVector(word)...)
+				default:
+					for _, w := range simpleTSVector(word) {
+						s.terms = append(s.terms, []string{w})
+					}
+				}
+			}
+		}
+	default:
+		s.prefix = f.SearchMode == SearchModePrefix
+		for _, word := range simpleTSVector(f.Search) {
+			s.terms = append(s.terms, []string{word})
+		}
+	}
+
+	s.terms = slices.DeleteFunc(s.terms, func(term []string) bool { return len(term) == 0 })
+	return s
+}
+
+// rank returns the relevance of the movie, and false if it doesn't match.
+func (s *memorySearch) rank(movie Movie) (float64, bool) {
+	if s.fuzzy != "" {
+		similarity := wordSimilarity(s.fuzzy, movie.Title)
+		return similarity, similarity >= wordSimilarityThreshold
+	}
+
+	title := simpleTSVector(movie.Title)
+	genres := simpleTSVector(strings.Join(movie.Genres, " "))
+
+	if len(s.terms) == 0 {
+		return 0, false
+	}
+	for _, word := range s.excluded {
+		if slices.Contains(title, word) || slices.Contains(genres, word) {
+			return 0, false
+		}
+	}
+
+	var rank float64
+	for _, term := range s.terms {
+		switch {
+		case s.contains(title, term):
+			rank += 1
+		case s.contains(genres, term):
+			rank += 0.4
+		default:
+			return 0, false
+		}
+	}
+
+	return rank / float64(len(s.terms)), true
+}
+
+// contains reports whether the words of term appear one after the other in words.
+func (s *memorySearch) contains(words, term []string) bool {
+	for i := 0; i+len(term) <= len(words); i++ {
+		if slices.EqualFunc(words[i:i+len(term)], term, s.wordMatches) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memorySearch) wordMatches(word, searchWord string) bool {
+	if s.prefix {
+		return strings.HasPrefix(word, searchWord)
+	}
+	return word == searchWord
+}
+
+// headline marks every word of the title that matches a search word, like ts_headline()
+// with HighlightAll.
+func (s *memorySearch) headline(title string) string {
+	if s.fuzzy != "" {
+		return ""
+	}
+
+	var b strings.Builder
+
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for len(title) > 0 {
+		end := strings.IndexFunc(title, func(r rune) bool { return !isWordRune(r) })
+		if end == 0 {
+			end = strings.IndexFunc(title, isWordRune)
+			if end == -1 {
+				end = len(title)
+			}
+			b.WriteString(title[:end])
+			title = title[end:]
+			continue
+		}
+		if end == -1 {
+			end = len(title)
+		}
+
+		word := title[:end]
+		if s.highlights(strings.ToLower(word)) {
+			b.WriteString("<mark>" + word + "</mark>")
+		} else {
+			b.WriteString(word)
+		}
+		title = title[end:]
+	}
+
+	return b.String()
+}
+
+func (s *memorySearch) highlights(word string) bool {
+	for _, term := range s.terms {
+		for _, searchWord := range term {
+			if s.wordMatches(word, searchWord) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wordSimilarityThreshold is the default of pg_trgm.word_similarity_threshold, used by
+// the <% operator.
+const wordSimilarityThreshold = 0.6
+
+// wordSimilarity follows pg_trgm's word_similarity(a, b): the greatest similarity
+// between the trigrams of a and any continuous extent of the ordered trigrams of b.
+func wordSimilarity(a, b string) float64 {
+	want := make(map[string]bool)
+	for _, trigram := range trigrams(a) {
+		want[trigram] = true
+	}
+	if len(want) == 0 {
+		return 0
+	}
+
+	ordered := trigrams(b)
+	best := 0.0
+	for i := range ordered {
+		extent := make(map[string]bool)
+		shared := 0
+		for _, trigram := range ordered[i:] {
+			if extent[trigram] {
+				continue
+			}
+			extent[trigram] = true
+			if want[trigram] {
+				shared++
+			}
+
+			similarity := float64(shared) / float64(len(want)+len(extent)-shared)
+			best = max(best, similarity)
+		}
+	}
+
+	return best
+}
+
+// trigramSimilarity follows pg_trgm's similarity(a, b): the number of trigrams a and b
+// share over the number of distinct trigrams between them.
+func trigramSimilarity(a, b string) float64 {
+	set := make(map[string]int)
+	for _, trigram := range trigrams(a) {
+		set[trigram] |= 1
+	}
+	for _, trigram := range trigrams(b) {
+		set[trigram] |= 2
+	}
+	if len(set) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for _, in := range set {
+		if in == 3 {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(set))
+}
+
+// trigrams returns the trigrams of each word of s in order, the way pg_trgm pads the
+// words with two spaces in front and one behind.
+func trigrams(s string) []string {
+	var trigrams []string
+	for _, word := range simpleTSVector(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigrams = append(trigrams, string(padded[i:i+3]))
+		}
+	}
+	return trigrams
+}
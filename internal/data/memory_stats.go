@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+func (m MemoryMovieModel) Stats(ctx context.Context, movieFilters MovieFilters) (*MovieStats, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matches := m.store.findMovies(movieFilters)
+	now := time.Now()
+	stats := newMovieStats(countFacets(matches, []string{MovieFacetGenres, MovieFacetYear}), now)
+
+	since := recentlyAddedSince(now)
+	var runtimes int
+	for _, movie := range matches {
+		stats.Totals.Movies++
+		if movie.RatingCount > 0 {
+			stats.Totals.RatedMovies++
+		}
+		stats.Totals.Ratings += int(movie.RatingCount)
+
+		if stats.Totals.Movies == 1 || movie.Runtime < stats.Runtime.Min {
+			stats.Runtime.Min = movie.Runtime
+		}
+		stats.Runtime.Max = max(stats.Runtime.Max, movie.Runtime)
+		runtimes += int(movie.Runtime)
+		stats.Runtime.Histogram[runtimeBucket(movie.Runtime)].Count++
+
+		if !movie.CreatedAt.Before(since) {
+			stats.addRecentlyAdded(weekStart(movie.CreatedAt), 1)
+		}
+	}
+
+	if stats.Totals.Movies > 0 {
+		stats.Runtime.Average = Runtime(math.Round(float64(runtimes) / float64(stats.Totals.Movies)))
+	}
+
+	return stats, nil
+}
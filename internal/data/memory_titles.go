@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+type MemoryMovieTitleModel struct {
+	store *memoryStore
+}
+
+func (m MemoryMovieTitleModel) Set(ctx context.Context, title *MovieTitle) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.movieTitles[title.MovieID] == nil {
+		m.store.movieTitles[title.MovieID] = make(map[string]string)
+	}
+	m.store.movieTitles[title.MovieID][title.Locale] = title.Title
+	return nil
+}
+
+func (m MemoryMovieTitleModel) Delete(ctx context.Context, movieID int64, locale string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.movieTitles[movieID][locale]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.movieTitles[movieID], locale)
+	return nil
+}
+
+func (m MemoryMovieTitleModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTitle, error) {
+	titles, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*MovieTitle{}, titles[movieID]...), nil
+}
+
+func (m MemoryMovieTitleModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieTitle, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	titles := make(map[int64][]*MovieTitle)
+	for _, movieID := range movieIDs {
+		for locale, title := range m.store.movieTitles[movieID] {
+			titles[movieID] = append(titles[movieID], &MovieTitle{MovieID: movieID, Locale: locale, Title: title})
+		}
+		slices.SortFunc(titles[movieID], func(a, b *MovieTitle) int {
+			return strings.Compare(a.Locale, b.Locale)
+		})
+	}
+	return titles, nil
+}
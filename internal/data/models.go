@@ -1,8 +1,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -10,18 +12,184 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// querier is what *sql.DB and *sql.Tx have in common, for the queries that run either
+// on their own or as part of a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// The repository interfaces describe everything the handlers need from the data layer.
+// The *Model types implement them on top of PostgreSQL, while the types returned by
+// NewMemoryModels() keep everything in memory.
+type MovieRepository interface {
+	Insert(ctx context.Context, movie *Movie, revision *MovieRevision) error
+	InsertBatch(ctx context.Context, movies []*Movie, userID int64) error
+	ExecuteBatch(ctx context.Context, ops []*MovieOperation) error
+	Get(ctx context.Context, id int64) (*Movie, error)
+	GetFields(ctx context.Context, id int64, fields []string) (*Movie, error)
+	Update(ctx context.Context, movie *Movie, revision *MovieRevision) error
+	Delete(ctx context.Context, id int64, revision *MovieRevision) error
+	DeleteVersion(ctx context.Context, id int64, version int32, revision *MovieRevision) error
+	GetAll(ctx context.Context, movieFilters MovieFilters, filters Filters) ([]*Movie, Metadata, error)
+	Facets(ctx context.Context, movieFilters MovieFilters, facets []string) (map[string][]FacetCount, error)
+	Stats(ctx context.Context, movieFilters MovieFilters) (*MovieStats, error)
+	Export(ctx context.Context, movieFilters MovieFilters, fn func(movie *Movie) error) error
+	Suggest(ctx context.Context, search string, limit int) ([]*MovieSuggestion, error)
+	FindDuplicate(ctx context.Context, movie *Movie) (*Movie, error)
+	Merge(ctx context.Context, targetID int64, duplicateIDs []int64, userID int64) error
+	Restore(ctx context.Context, id int64, userID int64) error
+	GetAllDeleted(ctx context.Context, filters Filters) ([]*Movie, Metadata, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	GetSimilar(ctx context.Context, movie *Movie, filters Filters) ([]*Movie, Metadata, error)
+	GetRecommended(ctx context.Context, userID int64, filters Filters) ([]*Movie, Metadata, error)
+}
+
+type UserRepository interface {
+	Insert(ctx context.Context, user *User) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error)
+}
+
+type TokenRepository interface {
+	New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+}
+
+type PermissionRepository interface {
+	GetAllForUser(ctx context.Context, userID int64) (Permissions, error)
+	AddForUser(ctx context.Context, userID int64, codes ...string) error
+}
+
+type PersonRepository interface {
+	Insert(ctx context.Context, person *Person) error
+	Get(ctx context.Context, id int64) (*Person, error)
+	Update(ctx context.Context, person *Person) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Person, Metadata, error)
+	AddCredit(ctx context.Context, credit *Credit) error
+	DeleteCredit(ctx context.Context, movieID, creditID int64) error
+	GetCreditsForMovie(ctx context.Context, movieID int64) ([]*Credit, error)
+	GetCreditsForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*Credit, error)
+}
+
+type ReviewRepository interface {
+	Insert(ctx context.Context, review *Review) error
+	Get(ctx context.Context, movieID, id int64) (*Review, error)
+	Update(ctx context.Context, review *Review) error
+	Delete(ctx context.Context, movieID, id int64) error
+	GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error)
+}
+
+type ListRepository interface {
+	Insert(ctx context.Context, list *List) error
+	Get(ctx context.Context, id int64) (*List, error)
+	Update(ctx context.Context, list *List) error
+	Delete(ctx context.Context, id int64) error
+	GetAllForUser(ctx context.Context, userID int64, filters Filters) ([]*List, Metadata, error)
+	GetMovies(ctx context.Context, listID int64, filters Filters) ([]*ListItem, Metadata, error)
+	AddMovie(ctx context.Context, listID, movieID int64, position int) error
+	MoveMovie(ctx context.Context, listID, movieID int64, position int) error
+	RemoveMovie(ctx context.Context, listID, movieID int64) error
+}
+
+type CollectionRepository interface {
+	Insert(ctx context.Context, collection *Collection) error
+	Get(ctx context.Context, id int64) (*Collection, error)
+	Update(ctx context.Context, collection *Collection) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, name, kind string, filters Filters) ([]*Collection, Metadata, error)
+	GetMovies(ctx context.Context, collectionID int64, filters Filters) ([]*CollectionItem, Metadata, error)
+	AddMovie(ctx context.Context, collectionID, movieID int64, position int) error
+	MoveMovie(ctx context.Context, collectionID, movieID int64, position int) error
+	RemoveMovie(ctx context.Context, collectionID, movieID int64) error
+}
+
+type MovieRevisionRepository interface {
+	Insert(ctx context.Context, revision *MovieRevision) error
+	Get(ctx context.Context, movieID, id int64) (*MovieRevision, error)
+	GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*MovieRevision, Metadata, error)
+}
+
+type GenreRepository interface {
+	GetAll(ctx context.Context) ([]*Genre, error)
+	GetIndex(ctx context.Context) (GenreIndex, error)
+	Get(ctx context.Context, slug string) (*Genre, error)
+	Insert(ctx context.Context, genre *Genre) error
+	Update(ctx context.Context, genre *Genre) error
+	Delete(ctx context.Context, slug string) error
+}
+
+type ExternalIDRepository interface {
+	Set(ctx context.Context, externalID *ExternalID) error
+	Delete(ctx context.Context, movieID int64, source string) error
+	GetMovieID(ctx context.Context, source, externalID string) (int64, error)
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*ExternalID, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*ExternalID, error)
+}
+
+type MovieTitleRepository interface {
+	Set(ctx context.Context, title *MovieTitle) error
+	Delete(ctx context.Context, movieID int64, locale string) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTitle, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieTitle, error)
+}
+
+type MovieReleaseRepository interface {
+	Set(ctx context.Context, release *MovieRelease) error
+	Delete(ctx context.Context, movieID int64, country string) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRelease, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieRelease, error)
+}
+
+type MovieImageRepository interface {
+	Insert(ctx context.Context, image *MovieImage) error
+	Get(ctx context.Context, movieID, id int64) (*MovieImage, error)
+	GetByStorageKey(ctx context.Context, key string) (*MovieImage, error)
+	Delete(ctx context.Context, movieID, id int64) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieImage, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieImage, error)
+	GetAllDeletedBefore(ctx context.Context, before time.Time) ([]*MovieImage, error)
+}
+
 type Models struct {
-	Movies      MovieModel
-	Users       UserModel
-	Tokens      TokenModel
-	Permissions PermissionModel
+	Movies      MovieRepository
+	Users       UserRepository
+	Tokens      TokenRepository
+	Permissions PermissionRepository
+	People      PersonRepository
+	Reviews     ReviewRepository
+	Lists       ListRepository
+	Revisions   MovieRevisionRepository
+	Genres      GenreRepository
+	ExternalIDs ExternalIDRepository
+	Collections CollectionRepository
+	Titles      MovieTitleRepository
+	Releases    MovieReleaseRepository
+	Images      MovieImageRepository
 }
 
-func NewModels(db *sql.DB) Models {
+// NewModels returns a Models struct whose models share the db connection pool. Every
+// query is bounded by queryTimeout, in addition to the deadline of the context passed
+// in by the caller.
+func NewModels(db *sql.DB, queryTimeout time.Duration) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Movies:      MovieModel{DB: db, Timeout: queryTimeout},
+		Users:       UserModel{DB: db, Timeout: queryTimeout},
+		Tokens:      TokenModel{DB: db, Timeout: queryTimeout},
+		Permissions: PermissionModel{DB: db, Timeout: queryTimeout},
+		People:      PersonModel{DB: db, Timeout: queryTimeout},
+		Reviews:     ReviewModel{DB: db, Timeout: queryTimeout},
+		Lists:       ListModel{DB: db, Timeout: queryTimeout},
+		Revisions:   MovieRevisionModel{DB: db, Timeout: queryTimeout},
+		Genres:      GenreModel{DB: db, Timeout: queryTimeout},
+		ExternalIDs: ExternalIDModel{DB: db, Timeout: queryTimeout},
+		Collections: CollectionModel{DB: db, Timeout: queryTimeout},
+		Titles:      MovieTitleModel{DB: db, Timeout: queryTimeout},
+		Releases:    MovieReleaseModel{DB: db, Timeout: queryTimeout},
+		Images:      MovieImageModel{DB: db, Timeout: queryTimeout},
 	}
 }
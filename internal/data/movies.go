@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kayconfig/green-light-api/internal/validator"
@@ -12,48 +15,230 @@ import (
 )
 
 type Movie struct {
-	ID        int64     `json:"id"`         // Unique integer ID for the movie
-	CreatedAt time.Time `json:"created_at"` // Timestamp for when the movie is added to our database
-	Title     string    `json:"title"`      // Movie title
-	Year      int32     `json:"year"`       // Movie release year
-	Runtime   Runtime   `json:"runtime"`    // Movie runtime (in minutes)
-	Genres    []string  `json:"genres"`     // Slice of genres for the movie (romance, comedy, etc.)
-	Version   int32     `json:"version"`    // The version number starts at 1 and will be incremented each
-	UpdatedAt time.Time `json:"-"`          // time the movie information is updated
+	ID            int64      `json:"id"`                     // Unique integer ID for the movie
+	CreatedAt     time.Time  `json:"created_at"`             // Timestamp for when the movie is added to our database
+	Title         string     `json:"title"`                  // Movie title
+	Year          int32      `json:"year"`                   // Movie release year
+	Runtime       Runtime    `json:"runtime"`                // Movie runtime (in minutes)
+	Genres        []string   `json:"genres"`                 // Slice of genres for the movie (romance, comedy, etc.)
+	Version       int32      `json:"version"`                // The version number starts at 1 and will be incremented each
+	UpdatedAt     time.Time  `json:"-"`                      // time the movie information is updated
+	AverageRating float64    `json:"average_rating"`         // Mean of the user ratings, rounded to 2 decimal places
+	RatingCount   int32      `json:"rating_count"`           // Number of user ratings
+	DeletedAt     *time.Time `json:"deleted_at,omitzero"`    // When the movie was moved to the trash, nil otherwise
+	Relevance     float64    `json:"relevance,omitzero"`     // Rank of the movie in a full-text search, or as a similar movie or recommendation
+	Headline      string     `json:"headline,omitzero"`      // Title with the words matching a full-text search marked
+	DisplayTitle  string     `json:"display_title,omitzero"` // Title in the language asked for with Accept-Language, if the movie has one
+
+	Credits     []*Credit       `json:"credits,omitempty"`      // Cast and crew, only set when embedded on request
+	ExternalIDs []*ExternalID   `json:"external_ids,omitempty"` // Ids in other catalogues, only set when embedded on request
+	Titles      []*MovieTitle   `json:"titles,omitempty"`       // Alternate titles per locale, only set when embedded on request
+	Releases    []*MovieRelease `json:"releases,omitempty"`     // Releases per country, only set when embedded on request
+	Images      []*MovieImage   `json:"images,omitempty"`       // Posters and backdrops, only set when embedded on request
 }
 
 type MovieModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+// Insert adds the movie along with its revision, in a single transaction. A nil
+// revision leaves the history alone; the same goes for the other methods that take one.
+func (m MovieModel) Insert(ctx context.Context, movie *Movie, revision *MovieRevision) error {
 	query := `
 	INSERT INTO movies(title, year, runtime, genres)
 	VALUES($1, $2, $3, $4)
 	RETURNING id, created_at, version, updated_at
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)).Scan(
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
 		&movie.Version,
 		&movie.UpdatedAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, revision, movie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertBatch inserts the movies in a single transaction, so either all of them are
+// added or none are. The insert revision of each movie, made on behalf of userID (zero
+// for changes not made through the API), is part of the same transaction.
+func (m MovieModel) InsertBatch(ctx context.Context, movies []*Movie, userID int64) error {
+	query := `
+	INSERT INTO movies(title, year, runtime, genres)
+	VALUES($1, $2, $3, $4)
+	RETURNING id, created_at, version, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, movie := range movies {
+		err := stmt.QueryRowContext(ctx, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)).Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Version,
+			&movie.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		revision := NewMovieRevision(RevisionInsert, nil, movie)
+		revision.UserID = userID
+
+		err = insertRevision(ctx, tx, revision)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (m MovieModel) Get(id int64) (*Movie, error) {
+// ErrBatchFailed is returned by ExecuteBatch() when an operation can't be applied, in
+// which case none of them are. The Err of each operation says what went wrong with it.
+var ErrBatchFailed = errors.New("batch failed")
+
+// The actions of a MovieOperation.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// MovieOperation is one of the operations of a batch. Movie is the new movie for a
+// create, the changed movie at the version it was read at for an update (as with
+// Update()), and for a delete the id and version the movie must still be at. Movie is
+// filled in the way Insert() and Update() do it, except that an update also reads back
+// the fields the operation doesn't carry (created_at and the rating aggregates), and Err
+// is set to ErrEditConflict when an update or delete finds the movie gone or at another
+// version. Revision, if set, is recorded as part of the batch once the operation has
+// been applied, with the id and version it left the movie with.
+type MovieOperation struct {
+	Action   string
+	Movie    *Movie
+	Revision *MovieRevision
+	Err      error
+}
+
+// ExecuteBatch applies the operations in order in a single transaction, along with
+// their revisions. Every operation is attempted, so that all the conflicts can be
+// reported at once, but if any of them fails the transaction is rolled back and
+// ErrBatchFailed is returned.
+func (m MovieModel) ExecuteBatch(ctx context.Context, ops []*MovieOperation) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	failed := false
+	for _, op := range ops {
+		movie := op.Movie
+
+		switch op.Action {
+		case BatchCreate:
+			query := `
+			INSERT INTO movies(title, year, runtime, genres)
+			VALUES($1, $2, $3, $4)
+			RETURNING id, created_at, version, updated_at
+			`
+			err = tx.QueryRowContext(ctx, query, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)).Scan(
+				&movie.ID,
+				&movie.CreatedAt,
+				&movie.Version,
+				&movie.UpdatedAt,
+			)
+		case BatchUpdate:
+			query := `
+			UPDATE movies
+			SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1, updated_at = NOW()
+			WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+			RETURNING version, updated_at, created_at, average_rating, rating_count
+			`
+			err = tx.QueryRowContext(ctx, query, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ID, movie.Version).Scan(
+				&movie.Version,
+				&movie.UpdatedAt,
+				&movie.CreatedAt,
+				&movie.AverageRating,
+				&movie.RatingCount,
+			)
+		case BatchDelete:
+			query := `
+			UPDATE movies
+			SET deleted_at = NOW()
+			WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+			RETURNING deleted_at
+			`
+			err = tx.QueryRowContext(ctx, query, movie.ID, movie.Version).Scan(&movie.DeletedAt)
+		default:
+			panic("unknown batch action: " + op.Action)
+		}
+
+		switch {
+		case err == nil:
+			err = recordRevision(ctx, tx, op.Revision, movie)
+			if err != nil {
+				return err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			op.Err = ErrEditConflict
+			failed = true
+		default:
+			return err
+		}
+	}
+
+	if failed {
+		return ErrBatchFailed
+	}
+	return tx.Commit()
+}
+
+func (m MovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
 	var movie Movie
 	query := `
-	select id,created_at,title, year, runtime, genres, version, updated_at
+	select id,created_at,title, year, runtime, genres, version, updated_at, average_rating, rating_count
 	from movies
-	where id = $1
+	where id = $1 AND deleted_at IS NULL
 	`
 
-	// create Context with 3-second timeout deadline
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// derive a Context with the configured query timeout from the caller's context, so
+	// the query is cancelled if either the deadline passes or the caller goes away
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	// use defer to make sure we cancel the context before the Get() method returns
 	defer cancel()
 
@@ -65,7 +250,36 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.Version,
-		&movie.UpdatedAt)
+		&movie.UpdatedAt,
+		&movie.AverageRating,
+		&movie.RatingCount)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
+// GetFields is Get() for a sparse fieldset, only reading the columns behind fields
+// (any of MovieFields) along with the id and version.
+func (m MovieModel) GetFields(ctx context.Context, id int64, fields []string) (*Movie, error) {
+	var movie Movie
+	columns, dest := movieColumns(&movie, fields)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM movies
+	WHERE id = $1 AND deleted_at IS NULL
+	`, columns)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(dest...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -78,11 +292,13 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+// Update saves the changes to the movie, along with their revision, if the movie is
+// still at the version it was read at. It returns ErrEditConflict otherwise.
+func (m MovieModel) Update(ctx context.Context, movie *Movie, revision *MovieRevision) error {
 	query := `
 	UPDATE movies
 	SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1, updated_at = NOW()
-	WHERE id = $5 AND version = $6
+	WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 	RETURNING version,updated_at
 	`
 	args := []any{
@@ -93,10 +309,16 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.ID,
 		movie.Version,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version, &movie.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version, &movie.UpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -106,54 +328,424 @@ func (m MovieModel) Update(movie *Movie) error {
 		}
 	}
 
-	return nil
+	err = recordRevision(ctx, tx, revision, movie)
+	if err != nil {
+		return err
+	}
 
+	return tx.Commit()
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Delete moves the movie to the trash by setting its deleted_at timestamp, and records
+// the revision. Trashed movies are hidden from every other query until they are
+// restored, or purged for good by PurgeDeleted().
+func (m MovieModel) Delete(ctx context.Context, id int64, revision *MovieRevision) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
-	DELETE FROM movies
-	where id = $1
+	UPDATE movies
+	SET deleted_at = NOW()
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id, version
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	return m.delete(ctx, query, []any{id}, ErrRecordNotFound, revision)
+}
+
+// DeleteVersion moves the movie to the trash only if it is still at the given version,
+// returning ErrEditConflict if it has been changed (or deleted) in the meantime.
+func (m MovieModel) DeleteVersion(ctx context.Context, id int64, version int32, revision *MovieRevision) error {
+	query := `
+	UPDATE movies
+	SET deleted_at = NOW()
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	RETURNING id, version
+	`
+
+	return m.delete(ctx, query, []any{id, version}, ErrEditConflict, revision)
+}
+
+// delete runs one of the delete queries, which return the id and version of the movie
+// they moved to the trash, and records the revision. notFound is returned when the
+// query finds no movie.
+func (m MovieModel) delete(ctx context.Context, query string, args []any, notFound error, revision *MovieRevision) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var movie Movie
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return notFound
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, revision, &movie)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	return tx.Commit()
+}
+
+func (m MovieModel) GetAll(ctx context.Context, movieFilters MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.Keyset {
+		return m.getAllByCursor(ctx, movieFilters, filters)
+	}
+
+	var args queryArgs
+	query := fmt.Sprintf(
+		`
+	SELECT count(*) OVER(), %s, %s
+	FROM movies
+	WHERE %s
+	ORDER BY %s %s, id ASC
+	LIMIT %s OFFSET %s
+	`, movieColumnList(filters.Fields), movieFilters.searchColumns(&args), movieFilters.where(&args),
+		filters.sortColumn(), movieSortDirection(filters), args.add(filters.limit()), args.add(filters.offset()))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		_, dest := movieColumns(&movie, filters.Fields)
+		dest = append([]any{&totalRecords}, dest...)
+		err := rows.Scan(append(dest, &movie.Relevance, &movie.Headline)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return movies, metadata, nil
+}
+
+// Facets counts the movies matching the filters for each value of the facets, which
+// are any of MovieFacets. A movie is counted once for each of its genres. Genres come
+// most common first and years newest first.
+func (m MovieModel) Facets(ctx context.Context, movieFilters MovieFilters, facets []string) (map[string][]FacetCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return queryFacets(ctx, m.DB, movieFilters, facets)
+}
+
+// queryFacets is Facets run on db, which may be a transaction.
+func queryFacets(ctx context.Context, db querier, movieFilters MovieFilters, facets []string) (map[string][]FacetCount, error) {
+	counts := make(map[string][]FacetCount, len(facets))
+	for _, facet := range facets {
+		var args queryArgs
+		var query string
+
+		switch facet {
+		case MovieFacetGenres:
+			query = fmt.Sprintf(`
+			SELECT genre, count(*)
+			FROM movies CROSS JOIN unnest(genres) AS genre
+			WHERE %s
+			GROUP BY genre
+			ORDER BY count(*) DESC, genre ASC
+			`, movieFilters.where(&args))
+		case MovieFacetYear:
+			query = fmt.Sprintf(`
+			SELECT year, count(*)
+			FROM movies
+			WHERE %s
+			GROUP BY year
+			ORDER BY year DESC
+			`, movieFilters.where(&args))
+		default:
+			panic("unsafe facet: " + facet)
+		}
+
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		buckets := []FacetCount{}
+		for rows.Next() {
+			var bucket FacetCount
+
+			if facet == MovieFacetYear {
+				var year int32
+				err = rows.Scan(&year, &bucket.Count)
+				bucket.Value = year
+			} else {
+				var genre string
+				err = rows.Scan(&genre, &bucket.Count)
+				bucket.Value = genre
+			}
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			buckets = append(buckets, bucket)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		counts[facet] = buckets
+	}
+
+	return counts, nil
+}
+
+// Export calls fn for every movie matching the filters, in id order, as the rows are
+// read from the database, so the whole catalogue never has to be held in memory. The
+// query timeout doesn't apply, since an export takes as long as it takes to write the
+// movies out; it stops when ctx is cancelled or fn returns an error.
+func (m MovieModel) Export(ctx context.Context, movieFilters MovieFilters, fn func(movie *Movie) error) error {
+	var args queryArgs
+	query := fmt.Sprintf(`
+	SELECT id, created_at, updated_at, title, year, runtime, genres, version, average_rating, rating_count
+	FROM movies
+	WHERE %s
+	ORDER BY id ASC
+	`, movieFilters.where(&args))
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.UpdatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&movie); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// getAllByCursor is the keyset paginated version of GetAll(). Instead of skipping rows
+// with OFFSET it continues from the sort value and id stored in the cursor, which keeps
+// deep pages fast and stable while movies are being added. It fetches one row more
+// than the page size to find out whether there is another page.
+func (m MovieModel) getAllByCursor(ctx context.Context, movieFilters MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	var args queryArgs
+	columns := movieFilters.searchColumns(&args)
+	where := movieFilters.where(&args)
+	limit := args.add(filters.limit() + 1)
+
+	// the cursors are made from the sort column, whether or not it was asked for
+	fields := filters.Fields
+	if len(fields) > 0 {
+		fields = append(slices.Clone(fields), filters.sortColumn())
+	}
+
+	keysetWhere, orderBy := filters.keysetClauses(len(args) + 1)
+	if filters.Cursor != nil {
+		after, err := movieFromCursor(filters)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		args = append(args, movieSortValue(after, filters.sortColumn()), after.ID)
+	}
+
+	query := fmt.Sprintf(
+		`
+	SELECT %s, %s
+	FROM movies
+	WHERE %s
+	AND %s
+	ORDER BY %s
+	LIMIT %s
+	`, movieColumnList(fields), columns, where, keysetWhere, orderBy, limit)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		_, dest := movieColumns(&movie, fields)
+		err := rows.Scan(append(dest, &movie.Relevance, &movie.Headline)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	movies, metadata := keysetPage(movies, filters)
+	return movies, metadata, nil
+}
+
+// Suggest returns up to limit movies whose titles are the most similar to search, for
+// autocompletion. Similarity is measured with pg_trgm's word_similarity(), so a search
+// matches part of a title and survives a typo or two.
+func (m MovieModel) Suggest(ctx context.Context, search string, limit int) ([]*MovieSuggestion, error) {
+	query := `
+	SELECT id, title, year, word_similarity($1, title) AS similarity
+	FROM movies
+	WHERE $1 <% title AND deleted_at IS NULL
+	ORDER BY similarity DESC, id ASC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, search, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	suggestions := []*MovieSuggestion{}
+
+	for rows.Next() {
+		var suggestion MovieSuggestion
+
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year, &suggestion.Similarity)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
 	}
-	return nil
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// movieSortDirection is filters.sortDirection(), except that relevance is the other way
+// round: "relevance" puts the best matches first and "-relevance" the worst.
+func movieSortDirection(filters Filters) string {
+	direction := filters.sortDirection()
+	if filters.sortColumn() != "relevance" {
+		return direction
+	}
+
+	if direction == "ASC" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// Restore takes the movie out of the trash as a new version, like an update, so that
+// ETags from before the delete go stale. The restore is recorded as a revision made by
+// userID (zero for changes not made through the API), since only the model sees the
+// movie as it comes out of the trash. It returns ErrRecordNotFound if the movie isn't
+// in the trash.
+func (m MovieModel) Restore(ctx context.Context, id int64, userID int64) error {
+	query := `
+	UPDATE movies
+	SET deleted_at = NULL, version = version + 1, updated_at = NOW()
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	RETURNING id, title, year, runtime, genres, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var movie Movie
+	err = tx.QueryRowContext(ctx, query, id).Scan(&movie.ID, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	revision := NewMovieRevision(RevisionRestore, &movie, &movie)
+	revision.UserID = userID
+
+	err = insertRevision(ctx, tx, revision)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAllDeleted lists the movies in the trash.
+func (m MovieModel) GetAllDeleted(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
 	query := fmt.Sprintf(
 		`
-	SELECT count(*) OVER(), id, created_at, updated_at, title, year, runtime, genres, version
+	SELECT count(*) OVER(), id, created_at, updated_at, title, year, runtime, genres, version, average_rating, rating_count, deleted_at
 	FROM movies
-	WHERE ( to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-	AND (genres @> $2 OR $2 = '{}')
+	WHERE deleted_at IS NOT NULL
 	ORDER BY %s %s, id ASC
-	LIMIT $3 OFFSET $4
+	LIMIT $1 OFFSET $2
 	`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, title, pq.Array(genres), filters.limit(), filters.offset())
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -175,6 +767,9 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
 			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.DeletedAt,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -190,7 +785,182 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// PurgeDeleted permanently deletes the movies that were moved to the trash before the
+// given time, along with everything that references them, and returns how many were
+// deleted.
+func (m MovieModel) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+	DELETE FROM movies
+	WHERE deleted_at < $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// keysetPage trims the extra row fetched by a keyset query, restores the order of a
+// backward page and works out the cursors for the pages either side of it.
+func keysetPage(movies []*Movie, filters Filters) ([]*Movie, Metadata) {
+	backward := filters.Cursor != nil && filters.Cursor.Backward
+
+	hasMore := len(movies) > filters.limit()
+	if hasMore {
+		movies = movies[:filters.limit()]
+	}
+	if backward {
+		slices.Reverse(movies)
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+	if len(movies) == 0 {
+		return movies, metadata
+	}
+
+	// going forward there is a next page if we got the extra row, and a previous one
+	// unless this is the first page; going backward it's the other way round
+	if hasMore || backward {
+		metadata.NextCursor = movieCursor(movies[len(movies)-1], filters.Sort, false)
+	}
+	if (hasMore && backward) || (!backward && filters.Cursor != nil) {
+		metadata.PrevCursor = movieCursor(movies[0], filters.Sort, true)
+	}
+
+	return movies, metadata
+}
+
+func movieCursor(movie *Movie, sort string, backward bool) string {
+	column := strings.TrimPrefix(sort, "-")
+
+	var value string
+	switch column {
+	case "created_at":
+		value = movie.CreatedAt.Format(time.RFC3339Nano)
+	default:
+		value = fmt.Sprint(movieSortValue(movie, column))
+	}
+
+	return Cursor{Sort: sort, Value: value, ID: movie.ID, Backward: backward}.Encode()
+}
+
+// movieFromCursor turns the cursor in filters back into a (partial) movie holding just
+// the id and the value of the sort column, or returns ErrInvalidCursor if the value
+// doesn't make sense for that column.
+func movieFromCursor(filters Filters) (*Movie, error) {
+	movie := &Movie{ID: filters.Cursor.ID}
+	value := filters.Cursor.Value
+
+	var err error
+	switch filters.sortColumn() {
+	case "created_at":
+		movie.CreatedAt, err = time.Parse(time.RFC3339Nano, value)
+	case "title":
+		movie.Title = value
+	case "year":
+		var year int64
+		year, err = strconv.ParseInt(value, 10, 32)
+		movie.Year = int32(year)
+	case "runtime":
+		var runtime int64
+		runtime, err = strconv.ParseInt(value, 10, 32)
+		movie.Runtime = Runtime(runtime)
+	case "average_rating":
+		movie.AverageRating, err = strconv.ParseFloat(value, 64)
+	case "rating_count":
+		var count int64
+		count, err = strconv.ParseInt(value, 10, 32)
+		movie.RatingCount = int32(count)
+	default:
+		return nil, ErrInvalidCursor
+	}
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return movie, nil
+}
+
+func movieSortValue(movie *Movie, column string) any {
+	switch column {
+	case "created_at":
+		return movie.CreatedAt
+	case "title":
+		return movie.Title
+	case "year":
+		return movie.Year
+	case "runtime":
+		return int32(movie.Runtime)
+	case "average_rating":
+		return movie.AverageRating
+	case "rating_count":
+		return movie.RatingCount
+	default:
+		panic("unsupported sort column: " + column)
+	}
+}
+
+// MovieFields are the fields of a movie that can be picked with a sparse fieldset.
+var MovieFields = []string{"id", "title", "year", "runtime", "genres", "version", "created_at", "average_rating", "rating_count"}
+
+// movieColumnOrder lists the columns of a movie in the order they are selected.
+var movieColumnOrder = []string{"id", "created_at", "updated_at", "title", "year", "runtime", "genres", "version", "average_rating", "rating_count"}
+
+// movieColumnList returns the comma-separated columns selected for a sparse fieldset
+// of a movie; see movieColumns().
+func movieColumnList(fields []string) string {
+	columns, _ := movieColumns(&Movie{}, fields)
+	return columns
+}
+
+// movieColumns returns the columns selected for a sparse fieldset of a movie, along
+// with where each of them is scanned to. No fields means every column. The id and
+// version are always read, since the ETag and cursors depend on them.
+func movieColumns(movie *Movie, fields []string) (string, []any) {
+	var columns []string
+	var dest []any
+
+	for _, column := range movieColumnOrder {
+		if len(fields) > 0 && column != "id" && column != "version" && !slices.Contains(fields, column) {
+			continue
+		}
+
+		columns = append(columns, column)
+		switch column {
+		case "id":
+			dest = append(dest, &movie.ID)
+		case "created_at":
+			dest = append(dest, &movie.CreatedAt)
+		case "updated_at":
+			dest = append(dest, &movie.UpdatedAt)
+		case "title":
+			dest = append(dest, &movie.Title)
+		case "year":
+			dest = append(dest, &movie.Year)
+		case "runtime":
+			dest = append(dest, &movie.Runtime)
+		case "genres":
+			dest = append(dest, pq.Array(&movie.Genres))
+		case "version":
+			dest = append(dest, &movie.Version)
+		case "average_rating":
+			dest = append(dest, &movie.AverageRating)
+		case "rating_count":
+			dest = append(dest, &movie.RatingCount)
+		}
+	}
+
+	return strings.Join(columns, ", "), dest
+}
+
+// ValidateMovie checks a movie before it is saved. Its genres must be slugs of the known
+// genres, so callers normalize them with genres.Normalize() first.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres GenreIndex) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(movie.Year != 0, "year", "must be provided")
@@ -202,4 +972,9 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	for _, genre := range movie.Genres {
+		if !genres.Has(genre) {
+			v.AddError("genres", fmt.Sprintf("contains unknown genre %q", genre))
+		}
+	}
 }
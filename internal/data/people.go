@@ -0,0 +1,333 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("duplicate credit")
+)
+
+const (
+	RoleDirector = "director"
+	RoleWriter   = "writer"
+	RoleActor    = "actor"
+)
+
+type Person struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	BirthYear int32     `json:"birth_year,omitzero"`
+	Biography string    `json:"biography,omitzero"`
+	Version   int32     `json:"version"`
+}
+
+// Credit links a person to a movie in a given role. Character is only used for actors,
+// and BillingOrder ranks the credits of a movie within each role.
+type Credit struct {
+	ID           int64  `json:"id"`
+	MovieID      int64  `json:"movie_id"`
+	PersonID     int64  `json:"person_id"`
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	Character    string `json:"character,omitzero"`
+	BillingOrder int32  `json:"billing_order"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+	if person.BirthYear != 0 {
+		v.Check(person.BirthYear >= 1800, "birth_year", "must be greater than 1800")
+		v.Check(person.BirthYear <= int32(time.Now().Year()), "birth_year", "must not be in the future")
+	}
+	v.Check(len(person.Biography) <= 5000, "biography", "must not be more than 5000 bytes long")
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+	v.Check(validator.PermittedValue(credit.Role, RoleDirector, RoleWriter, RoleActor), "role", "must be one of director, writer or actor")
+	v.Check(credit.Role == RoleActor || credit.Character == "", "character", "must only be provided for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
+
+type PersonModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m PersonModel) Insert(ctx context.Context, person *Person) error {
+	query := `
+	INSERT INTO people (name, birth_year, biography)
+	VALUES ($1, NULLIF($2, 0), $3)
+	RETURNING id, created_at, version
+	`
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, person.Name, person.BirthYear, person.Biography).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Version,
+	)
+}
+
+func (m PersonModel) Get(ctx context.Context, id int64) (*Person, error) {
+	query := `
+	SELECT id, created_at, name, COALESCE(birth_year, 0), biography, version
+	FROM people
+	WHERE id = $1
+	`
+	var person Person
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.BirthYear,
+		&person.Biography,
+		&person.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &person, nil
+}
+
+func (m PersonModel) Update(ctx context.Context, person *Person) error {
+	query := `
+	UPDATE people
+	SET name = $1, birth_year = NULLIF($2, 0), biography = $3, version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING version
+	`
+	args := []any{person.Name, person.BirthYear, person.Biography, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m PersonModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM people
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m PersonModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, name, COALESCE(birth_year, 0), biography, version
+	FROM people
+	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(
+			&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.BirthYear,
+			&person.Biography,
+			&person.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return people, metadata, nil
+}
+
+// AddCredit links a person to a movie. It returns ErrRecordNotFound if the person
+// doesn't exist, and ErrDuplicateCredit if the person already has the same credit.
+func (m PersonModel) AddCredit(ctx context.Context, credit *Credit) error {
+	query := `
+	WITH credit AS (
+		INSERT INTO movies_people (movie_id, person_id, role, character_name, billing_order)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, person_id
+	)
+	SELECT credit.id, people.name
+	FROM credit
+	INNER JOIN people ON people.id = credit.person_id
+	`
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.Name)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "movies_people" violates foreign key constraint "movies_people_person_id_fkey"`:
+			return ErrRecordNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "movies_people_credit_key"`:
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m PersonModel) DeleteCredit(ctx context.Context, movieID, creditID int64) error {
+	query := `
+	DELETE FROM movies_people
+	WHERE id = $1 AND movie_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, creditID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetCreditsForMovie returns the credits of a movie grouped by role (directors, then
+// writers, then actors) and ordered by billing within each role.
+func (m PersonModel) GetCreditsForMovie(ctx context.Context, movieID int64) ([]*Credit, error) {
+	credits, err := m.GetCreditsForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*Credit{}, credits[movieID]...), nil
+}
+
+// GetCreditsForMovies returns the credits of several movies in a single query, keyed
+// by movie id and ordered as in GetCreditsForMovie(). Movies without credits are left
+// out of the map.
+func (m PersonModel) GetCreditsForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*Credit, error) {
+	query := `
+	SELECT movies_people.id, movies_people.movie_id, movies_people.person_id, people.name,
+		movies_people.role, movies_people.character_name, movies_people.billing_order
+	FROM movies_people
+	INNER JOIN people ON people.id = movies_people.person_id
+	WHERE movies_people.movie_id = ANY($1)
+	ORDER BY array_position(ARRAY['director', 'writer', 'actor'], movies_people.role),
+		movies_people.billing_order, movies_people.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	credits := make(map[int64][]*Credit)
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.Name,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+		credits[credit.MovieID] = append(credits[credit.MovieID], &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
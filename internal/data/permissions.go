@@ -10,11 +10,17 @@ import (
 )
 
 var PermissionsCode = struct {
-	MoviesRead  string
-	MoviesWrite string
+	MoviesRead   string
+	MoviesWrite  string
+	MoviesAdmin  string
+	MoviesExport string
+	ReviewsWrite string
 }{
-	MoviesRead:  "movies:read",
-	MoviesWrite: "movies:write",
+	MoviesRead:   "movies:read",
+	MoviesWrite:  "movies:write",
+	MoviesAdmin:  "movies:admin",
+	MoviesExport: "movies:export",
+	ReviewsWrite: "reviews:write",
 }
 
 type Permissions []string
@@ -24,10 +30,11 @@ func (p Permissions) Include(code string) bool {
 }
 
 type PermissionModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+func (m PermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
 	query := `
 	SELECT permissions.code
 	FROM permissions
@@ -35,7 +42,7 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	INNER JOIN users ON users_permissions.user_id = users.id
 	WHERE users.id = $1
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, userID)
@@ -62,12 +69,12 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	return permissions, nil
 }
 
-func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+func (m PermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
 	query := `
 	INSERT INTO users_permissions
 	SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
 	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
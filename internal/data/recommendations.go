@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// The similarity of two movies is a weighted sum of how much their genres overlap (the
+// share of their genres they have in common), how close their years are and how
+// similar their titles are (pg_trgm's similarity()). The weights add up to 1, and so
+// does a perfect match.
+const (
+	similarGenreWeight = 0.6
+	similarYearWeight  = 0.25
+	similarTitleWeight = 0.15
+
+	// similarYearScale is how many years apart two movies are when their year
+	// proximity is down to a half.
+	similarYearScale = 10.0
+)
+
+// The recommendations for a user are drawn from the genres of the movies they rated
+// and put on their lists. Each genre scores the sum of the weights of those movies: a
+// rating weighs its distance from the middle of the 1 to 10 scale, so a 1 counts
+// against a genre as much as a 10 counts for it, while a movie on a list weighs like a
+// rating of 8.5. A candidate's score is mostly the share of the positive genre weights
+// its genres add up to, with its average rating making up the rest.
+const (
+	recommendationRatingMidpoint = 5.5
+	recommendationListWeight     = 3.0
+
+	recommendationAffinityWeight = 0.8
+	recommendationRatingWeight   = 0.2
+)
+
+// GetSimilar lists the other movies that share a genre with the movie, most similar
+// first, with their similarity in Relevance. Movies in the trash are left out.
+func (m MovieModel) GetSimilar(ctx context.Context, movie *Movie, filters Filters) ([]*Movie, Metadata, error) {
+	// the && on the genres is what lets the GIN index on them narrow down the candidates
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s, relevance
+	FROM (
+		SELECT movies.*, round((
+			%g * cardinality(ARRAY(SELECT unnest(genres) INTERSECT SELECT unnest($2::text[])))::float8
+				/ cardinality(ARRAY(SELECT unnest(genres) UNION SELECT unnest($2::text[])))
+			+ %g / (1 + abs(year - $3)::float8 / %g)
+			+ %g * similarity(title, $4)::float8
+		)::numeric, 4) AS relevance
+		FROM movies
+		WHERE genres && $2 AND id <> $1 AND deleted_at IS NULL
+	) AS similar
+	ORDER BY relevance DESC, id ASC
+	LIMIT $5 OFFSET $6
+	`, movieColumnList(nil), similarGenreWeight, similarYearWeight, similarYearScale, similarTitleWeight)
+	args := []any{movie.ID, pq.Array(movie.Genres), movie.Year, movie.Title, filters.limit(), filters.offset()}
+
+	return m.getAllRanked(ctx, query, args, filters)
+}
+
+// GetRecommended lists the movies recommended to the user, best first, with their score
+// in Relevance. Movies the user already rated or put on a list are left out, and so
+// are movies in the trash. A user who hasn't done either has no recommendations.
+func (m MovieModel) GetRecommended(ctx context.Context, userID int64, filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+	WITH activity AS (
+		SELECT movie_id, rating - %g AS weight
+		FROM reviews
+		WHERE user_id = $1
+		UNION ALL
+		SELECT lists_movies.movie_id, %g
+		FROM lists_movies
+		INNER JOIN lists ON lists.id = lists_movies.list_id
+		WHERE lists.user_id = $1
+	),
+	profile AS (
+		SELECT genre, sum(activity.weight) AS weight
+		FROM activity
+		INNER JOIN movies ON movies.id = activity.movie_id
+		CROSS JOIN unnest(movies.genres) AS movie_genres (genre)
+		GROUP BY genre
+	),
+	liked AS (
+		SELECT array_agg(genre) AS genres, sum(weight) AS total
+		FROM profile
+		WHERE weight > 0
+	)
+	SELECT count(*) OVER(), %s, relevance
+	FROM (
+		SELECT movies.*, round((
+			%g * (SELECT sum(profile.weight) FROM profile WHERE profile.genre = ANY(movies.genres))::float8 / liked.total::float8
+			+ %g * movies.average_rating::float8 / 10
+		)::numeric, 4) AS relevance
+		FROM movies, liked
+		WHERE movies.genres && liked.genres AND movies.deleted_at IS NULL
+		AND movies.id NOT IN (SELECT movie_id FROM activity)
+	) AS recommended
+	ORDER BY relevance DESC, id ASC
+	LIMIT $2 OFFSET $3
+	`, recommendationRatingMidpoint, recommendationListWeight, movieColumnList(nil), recommendationAffinityWeight, recommendationRatingWeight)
+	args := []any{userID, filters.limit(), filters.offset()}
+
+	return m.getAllRanked(ctx, query, args, filters)
+}
+
+// getAllRanked runs a query for a page of movies that selects the total count, every
+// column of a movie and its relevance.
+func (m MovieModel) getAllRanked(ctx context.Context, query string, args []any, filters Filters) ([]*Movie, Metadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		_, dest := movieColumns(&movie, nil)
+		dest = append([]any{&totalRecords}, dest...)
+		err := rows.Scan(append(dest, &movie.Relevance)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return movies, metadata, nil
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+// countryRX matches ISO 3166-1 alpha-2 country codes, such as US or GB.
+var countryRX = regexp.MustCompile("^[A-Z]{2}$")
+
+// MovieRelease is when a movie came out in a country, and the age certification it was
+// given there, such as PG-13 in the US or 15 in GB. A movie has at most one release per
+// country.
+type MovieRelease struct {
+	MovieID       int64  `json:"movie_id"`
+	Country       string `json:"country"`
+	ReleaseDate   string `json:"release_date"` // As YYYY-MM-DD
+	Certification string `json:"certification,omitzero"`
+}
+
+func ValidateMovieRelease(v *validator.Validator, release *MovieRelease) {
+	v.Check(validator.Matches(release.Country, countryRX), "country", "must be a two-letter country code such as US")
+	v.Check(release.ReleaseDate != "", "release_date", "must be provided")
+
+	if release.ReleaseDate != "" {
+		date, err := time.Parse(time.DateOnly, release.ReleaseDate)
+		v.Check(err == nil, "release_date", "must be a date such as 2024-01-31")
+		v.Check(err != nil || date.Year() >= 1888, "release_date", "must be in 1888 or later")
+	}
+
+	v.Check(len(release.Certification) <= 20, "certification", "must not be more than 20 bytes long")
+}
+
+type MovieReleaseModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Set records the release of the movie in the country, replacing the release it had
+// there.
+func (m MovieReleaseModel) Set(ctx context.Context, release *MovieRelease) error {
+	query := `
+	INSERT INTO movie_releases (movie_id, country, release_date, certification)
+	VALUES ($1, $2, $3::date, $4)
+	ON CONFLICT (movie_id, country) DO UPDATE
+	SET release_date = EXCLUDED.release_date, certification = EXCLUDED.certification
+	`
+	args := []any{release.MovieID, release.Country, release.ReleaseDate, release.Certification}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m MovieReleaseModel) Delete(ctx context.Context, movieID int64, country string) error {
+	query := `
+	DELETE FROM movie_releases
+	WHERE movie_id = $1 AND country = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, country)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MovieReleaseModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRelease, error) {
+	releases, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*MovieRelease{}, releases[movieID]...), nil
+}
+
+// GetAllForMovies returns the releases of several movies at once, keyed by movie id
+// and ordered by release date, earliest first.
+func (m MovieReleaseModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieRelease, error) {
+	query := `
+	SELECT movie_id, country, to_char(release_date, 'YYYY-MM-DD'), certification
+	FROM movie_releases
+	WHERE movie_id = ANY($1)
+	ORDER BY movie_id, release_date, country
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := make(map[int64][]*MovieRelease)
+	for rows.Next() {
+		var release MovieRelease
+		err := rows.Scan(&release.MovieID, &release.Country, &release.ReleaseDate, &release.Certification)
+		if err != nil {
+			return nil, err
+		}
+		releases[release.MovieID] = append(releases[release.MovieID], &release)
+	}
+
+	return releases, rows.Err()
+}
@@ -0,0 +1,214 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+)
+
+var (
+	ErrDuplicateReview = errors.New("duplicate review")
+)
+
+type Review struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	MovieID   int64     `json:"movie_id"`
+	UserID    int64     `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Rating    int32     `json:"rating"`
+	Body      string    `json:"body,omitzero"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating != 0, "rating", "must be provided")
+	v.Check(review.Rating >= 1 && review.Rating <= 10, "rating", "must be between 1 and 10")
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
+
+type ReviewModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Insert adds a review, returning ErrDuplicateReview if the user has already reviewed
+// the movie. The movie's average_rating and rating_count are refreshed by a trigger,
+// which makes a new version of the movie when they change.
+func (m ReviewModel) Insert(ctx context.Context, review *Review) error {
+	query := `
+	WITH review AS (
+		INSERT INTO reviews (movie_id, user_id, rating, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version, user_id
+	)
+	SELECT review.id, review.created_at, review.updated_at, review.version, users.name
+	FROM review
+	INNER JOIN users ON users.id = review.user_id
+	`
+	args := []any{review.MovieID, review.UserID, review.Rating, review.Body}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+		&review.UserName,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "reviews_movie_user_key"`:
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ReviewModel) Get(ctx context.Context, movieID, id int64) (*Review, error) {
+	query := `
+	SELECT reviews.id, reviews.created_at, reviews.updated_at, reviews.movie_id, reviews.user_id,
+		users.name, reviews.rating, reviews.body, reviews.version
+	FROM reviews
+	INNER JOIN users ON users.id = reviews.user_id
+	WHERE reviews.id = $1 AND reviews.movie_id = $2
+	`
+	var review Review
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, movieID).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.MovieID,
+		&review.UserID,
+		&review.UserName,
+		&review.Rating,
+		&review.Body,
+		&review.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+}
+
+func (m ReviewModel) Update(ctx context.Context, review *Review) error {
+	query := `
+	UPDATE reviews
+	SET rating = $1, body = $2, version = version + 1, updated_at = NOW()
+	WHERE id = $3 AND version = $4
+	RETURNING version, updated_at
+	`
+	args := []any{review.Rating, review.Body, review.ID, review.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.Version, &review.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ReviewModel) Delete(ctx context.Context, movieID, id int64) error {
+	query := `
+	DELETE FROM reviews
+	WHERE id = $1 AND movie_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m ReviewModel) GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), reviews.id, reviews.created_at, reviews.updated_at, reviews.movie_id,
+		reviews.user_id, users.name, reviews.rating, reviews.body, reviews.version
+	FROM reviews
+	INNER JOIN users ON users.id = reviews.user_id
+	WHERE reviews.movie_id = $1
+	ORDER BY reviews.%s %s, reviews.id ASC
+	LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.MovieID,
+			&review.UserID,
+			&review.UserName,
+			&review.Rating,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return reviews, metadata, nil
+}
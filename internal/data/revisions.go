@@ -0,0 +1,241 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+)
+
+// MovieRevision records a change made to a movie: who made it, the version of the movie
+// it produced and, for each field that changed, the old and new values. Snapshot holds
+// the editable fields of the movie after the change (or, for a delete, before it), and
+// is what a revert goes back to.
+type MovieRevision struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	MovieID   int64                  `json:"movie_id"`
+	Version   int32                  `json:"version"`
+	UserID    int64                  `json:"user_id,omitzero"` // Zero for changes not made through the API
+	Action    string                 `json:"action"`
+	Changes   map[string]FieldChange `json:"changes"`
+	Snapshot  MovieSnapshot          `json:"snapshot"`
+}
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// MovieSnapshot holds the fields of a movie that can be edited through the API.
+type MovieSnapshot struct {
+	Title   string   `json:"title"`
+	Year    int32    `json:"year"`
+	Runtime Runtime  `json:"runtime"`
+	Genres  []string `json:"genres"`
+}
+
+func snapshotOf(movie *Movie) MovieSnapshot {
+	return MovieSnapshot{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  slices.Clone(movie.Genres),
+	}
+}
+
+// ApplyTo copies the snapshot onto the editable fields of movie.
+func (s MovieSnapshot) ApplyTo(movie *Movie) {
+	movie.Title = s.Title
+	movie.Year = s.Year
+	movie.Runtime = s.Runtime
+	movie.Genres = slices.Clone(s.Genres)
+}
+
+// NewMovieRevision describes the change from before to after. before is nil for an
+// insert, and after is nil for a delete. Changes that leave the fields alone, like a
+// restore, pass the same movie as both.
+func NewMovieRevision(action string, before, after *Movie) *MovieRevision {
+	revision := &MovieRevision{Action: action, Changes: make(map[string]FieldChange)}
+
+	current := after
+	if current == nil {
+		current = before
+	}
+	revision.MovieID = current.ID
+	revision.Version = current.Version
+	revision.Snapshot = snapshotOf(current)
+
+	if after == nil {
+		return revision
+	}
+
+	// an insert records every field, with no old value
+	var old MovieSnapshot
+	if before != nil {
+		old = snapshotOf(before)
+	}
+	record := func(field string, oldValue, newValue any, equal bool) {
+		switch {
+		case before == nil:
+			revision.Changes[field] = FieldChange{New: newValue}
+		case !equal:
+			revision.Changes[field] = FieldChange{Old: oldValue, New: newValue}
+		}
+	}
+
+	record("title", old.Title, after.Title, old.Title == after.Title)
+	record("year", old.Year, after.Year, old.Year == after.Year)
+	record("runtime", old.Runtime, after.Runtime, old.Runtime == after.Runtime)
+	record("genres", old.Genres, after.Genres, slices.Equal(old.Genres, after.Genres))
+
+	return revision
+}
+
+type MovieRevisionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m MovieRevisionModel) Insert(ctx context.Context, revision *MovieRevision) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return insertRevision(ctx, m.DB, revision)
+}
+
+// recordRevision adds the revision of a change to movie through db, once the change has
+// been made, filling in the id and version the change left the movie with. A nil
+// revision is not recorded.
+func recordRevision(ctx context.Context, db querier, revision *MovieRevision, movie *Movie) error {
+	if revision == nil {
+		return nil
+	}
+
+	revision.MovieID = movie.ID
+	revision.Version = movie.Version
+	return insertRevision(ctx, db, revision)
+}
+
+// insertRevision adds a revision through db, which is either the connection pool or a
+// transaction the revision has to be part of.
+func insertRevision(ctx context.Context, db querier, revision *MovieRevision) error {
+	changes, err := json.Marshal(revision.Changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(revision.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO movie_revisions (movie_id, version, user_id, action, changes, snapshot)
+	VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6)
+	RETURNING id, created_at
+	`
+	args := []any{revision.MovieID, revision.Version, revision.UserID, revision.Action, changes, snapshot}
+
+	return db.QueryRowContext(ctx, query, args...).Scan(&revision.ID, &revision.CreatedAt)
+}
+
+func (m MovieRevisionModel) Get(ctx context.Context, movieID, id int64) (*MovieRevision, error) {
+	query := `
+	SELECT id, created_at, movie_id, version, COALESCE(user_id, 0), action, changes, snapshot
+	FROM movie_revisions
+	WHERE id = $1 AND movie_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	revision, err := scanMovieRevision(m.DB.QueryRowContext(ctx, query, id, movieID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+func (m MovieRevisionModel) GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*MovieRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, movie_id, version, COALESCE(user_id, 0), action, changes, snapshot
+	FROM movie_revisions
+	WHERE movie_id = $1
+	ORDER BY %s %s, id %[2]s
+	LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+	totalRecords := 0
+	revisions := []*MovieRevision{}
+
+	for rows.Next() {
+		revision, err := scanMovieRevision(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+// scanMovieRevision scans a row of movie_revisions, preceded by any extra columns.
+func scanMovieRevision(row interface{ Scan(...any) error }, extra ...any) (*MovieRevision, error) {
+	var revision MovieRevision
+	var changes, snapshot []byte
+
+	dest := append(extra,
+		&revision.ID,
+		&revision.CreatedAt,
+		&revision.MovieID,
+		&revision.Version,
+		&revision.UserID,
+		&revision.Action,
+		&changes,
+		&snapshot,
+	)
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(changes, &revision.Changes); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
@@ -0,0 +1,248 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+// The search modes decide how the text of a full-text search is turned into a tsquery.
+const (
+	SearchModePlain     = "plain"     // every word must match
+	SearchModePrefix    = "prefix"    // every word must match the start of a word, for search-as-you-type
+	SearchModePhrase    = "phrase"    // the words must appear next to each other, in order
+	SearchModeWebsearch = "websearch" // "quoted phrases", -excluded words and or, like a search engine
+	SearchModeFuzzy     = "fuzzy"     // the title must be similar to the search, allowing for typos
+)
+
+var SearchModes = []string{SearchModePlain, SearchModePrefix, SearchModePhrase, SearchModeWebsearch, SearchModeFuzzy}
+
+// SearchLanguages are the text search configurations that ship with PostgreSQL. The
+// language decides how words are stemmed and which stop words are ignored, while
+// "simple" only lowercases them.
+var SearchLanguages = []string{
+	"simple", "arabic", "armenian", "basque", "catalan", "danish", "dutch", "english",
+	"finnish", "french", "german", "greek", "hindi", "hungarian", "indonesian", "irish",
+	"italian", "lithuanian", "nepali", "norwegian", "portuguese", "romanian", "russian",
+	"serbian", "spanish", "swedish", "tamil", "turkish", "yiddish",
+}
+
+// headlineOptions are passed to ts_headline(), marking the matched words in the title.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+// MovieSuggestion is a movie whose title is similar to what the user has typed so far.
+type MovieSuggestion struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Year       int32   `json:"year"`
+	Similarity float64 `json:"similarity"` // From 0 to 1, where 1 means the whole search was found in the title
+}
+
+// How the genres of MovieFilters are matched.
+const (
+	GenresMatchAll = "all"
+	GenresMatchAny = "any"
+)
+
+// The movie fields that can be counted with Facets().
+const (
+	MovieFacetGenres = "genres"
+	MovieFacetYear   = "year"
+)
+
+var MovieFacets = []string{MovieFacetGenres, MovieFacetYear}
+
+// MovieFilters narrows down the movies returned by GetAll() and Export(). Zero values
+// leave a filter out, and ranges are exclusive for times and inclusive otherwise.
+type MovieFilters struct {
+	IDs          []int64  // the movies to fetch, for batch retrieval
+	Title        string   // words that must all appear in the title, or in one of its alternate titles
+	Genres       []string // genres the movie must have
+	GenresMatch  string   // whether the movie needs all of the genres (the default) or any of them
+	PersonID     int64    // someone credited on the movie
+	CollectionID int64    // a collection the movie is in
+
+	YearMin       int32
+	YearMax       int32
+	RuntimeMin    Runtime
+	RuntimeMax    Runtime
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// Search is a ranked full-text search over the title and, weighted lower, the
+	// genres. SearchMode says how it is parsed and SearchLanguage which text search
+	// configuration is used. The fuzzy mode compares the trigrams of the title with
+	// those of the search instead, which ignores the language. Alternate titles match
+	// too, and rank like the title.
+	Search         string
+	SearchMode     string
+	SearchLanguage string
+}
+
+func ValidateMovieFilters(v *validator.Validator, f MovieFilters) {
+	v.Check(len(f.IDs) <= 100, "ids", "must not contain more than 100 ids")
+	v.Check(!slices.ContainsFunc(f.IDs, func(id int64) bool { return id < 1 }), "ids", "must only contain positive integers")
+
+	v.Check(f.GenresMatch == "" || validator.PermittedValue(f.GenresMatch, GenresMatchAll, GenresMatchAny), "genres_match", "must be all or any")
+
+	v.Check(f.YearMin >= 0, "year_min", "must be a positive integer value")
+	v.Check(f.YearMax >= 0, "year_max", "must be a positive integer value")
+	v.Check(f.YearMax == 0 || f.YearMin <= f.YearMax, "year_max", "must not be less than year_min")
+	v.Check(f.RuntimeMin >= 0, "runtime_min", "must be a positive integer value")
+	v.Check(f.RuntimeMax >= 0, "runtime_max", "must be a positive integer value")
+	v.Check(f.RuntimeMax == 0 || f.RuntimeMin <= f.RuntimeMax, "runtime_max", "must not be less than runtime_min")
+	v.Check(f.CreatedBefore.IsZero() || f.CreatedAfter.Before(f.CreatedBefore), "created_before", "must be later than created_after")
+	v.Check(f.UpdatedBefore.IsZero() || f.UpdatedAfter.Before(f.UpdatedBefore), "updated_before", "must be later than updated_after")
+
+	v.Check(len(f.Search) <= 500, "q", "must not be more than 500 bytes long")
+	v.Check(validator.PermittedValue(f.SearchMode, SearchModes...), "search_mode", "invalid search mode")
+	v.Check(validator.PermittedValue(f.SearchLanguage, SearchLanguages...), "language", "unsupported language")
+}
+
+// queryArgs collects the arguments of a query built up from optional conditions.
+type queryArgs []any
+
+// add appends value to the arguments and returns its placeholder.
+func (a *queryArgs) add(value any) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// where returns the WHERE conditions for the filters, adding their values to args.
+// Movies in the trash never match.
+func (f MovieFilters) where(args *queryArgs) string {
+	conditions := []string{"deleted_at IS NULL"}
+
+	if len(f.IDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("id = ANY(%s)", args.add(pq.Array(f.IDs))))
+	}
+
+	if f.Title != "" {
+		query := fmt.Sprintf("plainto_tsquery('simple', %s)", args.add(f.Title))
+		conditions = append(conditions, fmt.Sprintf("(to_tsvector('simple', title) @@ %s OR %s)",
+			query, alternateTitleMatch(fmt.Sprintf("to_tsvector('simple', movie_titles.title) @@ %s", query))))
+	}
+	if len(f.Genres) > 0 {
+		operator := "@>"
+		if f.GenresMatch == GenresMatchAny {
+			operator = "&&"
+		}
+		conditions = append(conditions, fmt.Sprintf("genres %s %s", operator, args.add(pq.Array(f.Genres))))
+	}
+	if f.PersonID != 0 {
+		conditions = append(conditions, fmt.Sprintf("id IN (SELECT movie_id FROM movies_people WHERE person_id = %s)", args.add(f.PersonID)))
+	}
+	if f.CollectionID != 0 {
+		conditions = append(conditions, fmt.Sprintf("id IN (SELECT movie_id FROM collections_movies WHERE collection_id = %s)", args.add(f.CollectionID)))
+	}
+
+	ranges := []struct {
+		condition string
+		value     any
+		set       bool
+	}{
+		{"year >= %s", f.YearMin, f.YearMin != 0},
+		{"year <= %s", f.YearMax, f.YearMax != 0},
+		{"runtime >= %s", f.RuntimeMin, f.RuntimeMin != 0},
+		{"runtime <= %s", f.RuntimeMax, f.RuntimeMax != 0},
+		{"created_at > %s", f.CreatedAfter, !f.CreatedAfter.IsZero()},
+		{"created_at < %s", f.CreatedBefore, !f.CreatedBefore.IsZero()},
+		{"updated_at > %s", f.UpdatedAfter, !f.UpdatedAfter.IsZero()},
+		{"updated_at < %s", f.UpdatedBefore, !f.UpdatedBefore.IsZero()},
+	}
+	for _, r := range ranges {
+		if r.set {
+			conditions = append(conditions, fmt.Sprintf(r.condition, args.add(r.value)))
+		}
+	}
+	switch {
+	case f.Search == "":
+	case f.SearchMode == SearchModeFuzzy:
+		search := args.add(f.Search)
+		conditions = append(conditions, fmt.Sprintf("(%s <%% title OR %s)",
+			search, alternateTitleMatch(fmt.Sprintf("%s <%% movie_titles.title", search))))
+	default:
+		vector, query := f.textSearch(args)
+		alternateVector := fmt.Sprintf("to_tsvector(%s, movie_titles.title)", f.searchConfig(args))
+		conditions = append(conditions, fmt.Sprintf("(%s @@ %s OR %s)",
+			vector, query, alternateTitleMatch(fmt.Sprintf("%s @@ %s", alternateVector, query))))
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// alternateTitleMatch returns a condition that holds when one of the alternate titles
+// of the movie in the outer query meets condition, which refers to movie_titles.title.
+func alternateTitleMatch(condition string) string {
+	return fmt.Sprintf("id IN (SELECT movie_id FROM movie_titles WHERE %s)", condition)
+}
+
+// bestAlternateTitle returns the highest value of score, which refers to
+// movie_titles.title, among the alternate titles of the movie in the outer query. It
+// is NULL for a movie without alternate titles, which GREATEST() ignores.
+func bestAlternateTitle(score string) string {
+	return fmt.Sprintf("(SELECT max(%s) FROM movie_titles WHERE movie_titles.movie_id = movies.id)", score)
+}
+
+// searchColumns returns the relevance and headline columns selected alongside each
+// movie, which are only worked out when there is a full-text search. A fuzzy match
+// has no headline, since there may be no word in the title that matches exactly.
+func (f MovieFilters) searchColumns(args *queryArgs) string {
+	switch {
+	case f.Search == "":
+		return "0::real AS relevance, '' AS headline"
+	case f.SearchMode == SearchModeFuzzy:
+		search := args.add(f.Search)
+		return fmt.Sprintf("GREATEST(word_similarity(%s, title), %s) AS relevance, '' AS headline",
+			search, bestAlternateTitle(fmt.Sprintf("word_similarity(%s, movie_titles.title)", search)))
+	}
+
+	vector, query := f.textSearch(args)
+	config := f.searchConfig(args)
+	alternateRank := fmt.Sprintf("ts_rank(to_tsvector(%s, movie_titles.title), %s)", config, query)
+	return fmt.Sprintf("GREATEST(ts_rank(%s, %s), %s) AS relevance, ts_headline(%s, title, %s, '%s') AS headline",
+		vector, query, bestAlternateTitle(alternateRank), config, query, headlineOptions)
+}
+
+// textSearch returns the weighted tsvector of a movie and the tsquery for the search.
+// movie_search_vector() is defined by the migrations, with an index for the common
+// configurations.
+func (f MovieFilters) textSearch(args *queryArgs) (string, string) {
+	config := f.searchConfig(args)
+
+	var query string
+	switch f.SearchMode {
+	case SearchModePrefix:
+		query = fmt.Sprintf("to_tsquery(%s, %s)", config, args.add(prefixQuery(f.Search)))
+	case SearchModePhrase:
+		query = fmt.Sprintf("phraseto_tsquery(%s, %s)", config, args.add(f.Search))
+	case SearchModeWebsearch:
+		query = fmt.Sprintf("websearch_to_tsquery(%s, %s)", config, args.add(f.Search))
+	default:
+		query = fmt.Sprintf("plainto_tsquery(%s, %s)", config, args.add(f.Search))
+	}
+
+	return fmt.Sprintf("movie_search_vector(%s, title, genres)", config), query
+}
+
+func (f MovieFilters) searchConfig(args *queryArgs) string {
+	return args.add(f.SearchLanguage) + "::regconfig"
+}
+
+// prefixQuery turns the words of a search into to_tsquery() syntax where each of them
+// matches as a prefix, e.g. "star wa" becomes "star:* & wa:*". Anything but letters
+// and digits is dropped, so the result is always valid syntax.
+func prefixQuery(search string) string {
+	words := simpleTSVector(search)
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
@@ -0,0 +1,228 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// The runtime histogram has buckets runtimeBucketWidth minutes wide, starting from 0,
+// and a last open-ended one for everything from runtimeBucketWidth * (runtimeBuckets
+// - 1) minutes up.
+const (
+	runtimeBucketWidth = 30
+	runtimeBuckets     = 7
+)
+
+// recentlyAddedWeeks is how many weeks, the current one included, the recently added
+// trend goes back.
+const recentlyAddedWeeks = 12
+
+// MovieStats sums up the movies matching a set of filters.
+type MovieStats struct {
+	Totals        MovieTotals   `json:"totals"`
+	Genres        []FacetCount  `json:"genres"`  // most common first, like the genres facet
+	Decades       []FacetCount  `json:"decades"` // oldest first, by the first year of the decade
+	Runtime       RuntimeStats  `json:"runtime"`
+	RecentlyAdded []WeeklyCount `json:"recently_added"` // oldest first, weeks without movies included
+	GeneratedAt   time.Time     `json:"generated_at"`
+}
+
+type MovieTotals struct {
+	Movies      int `json:"movies"`
+	RatedMovies int `json:"rated_movies"` // movies with at least one rating
+	Ratings     int `json:"ratings"`
+}
+
+// RuntimeStats is the spread of the runtimes. Min, Max and Average are 0 when no
+// movie matched.
+type RuntimeStats struct {
+	Min       Runtime         `json:"min"`
+	Max       Runtime         `json:"max"`
+	Average   Runtime         `json:"average"` // rounded to the nearest minute
+	Histogram []RuntimeBucket `json:"histogram"`
+}
+
+// RuntimeBucket is the number of movies with a runtime from Min to Max, both included.
+// The last bucket has no Max.
+type RuntimeBucket struct {
+	Min   Runtime `json:"min"`
+	Max   Runtime `json:"max,omitzero"`
+	Count int     `json:"count"`
+}
+
+// WeeklyCount is the number of movies added in the week starting on Monday Week
+// (YYYY-MM-DD, in UTC).
+type WeeklyCount struct {
+	Week  string `json:"week"`
+	Count int    `json:"count"`
+}
+
+// newMovieStats starts the stats off with the genres and years facets of the movies,
+// empty runtime buckets and the recent weeks up to now, for the counts to be filled in.
+func newMovieStats(facets map[string][]FacetCount, now time.Time) *MovieStats {
+	stats := &MovieStats{
+		Genres:      facets[MovieFacetGenres],
+		Decades:     []FacetCount{},
+		GeneratedAt: now.UTC(),
+	}
+
+	for _, year := range facets[MovieFacetYear] {
+		decade := year.Value.(int32) / 10 * 10
+		i := slices.IndexFunc(stats.Decades, func(d FacetCount) bool { return d.Value == decade })
+		if i == -1 {
+			stats.Decades = append(stats.Decades, FacetCount{Value: decade})
+			i = len(stats.Decades) - 1
+		}
+		stats.Decades[i].Count += year.Count
+	}
+	// the years come newest first
+	slices.Reverse(stats.Decades)
+
+	for i := range runtimeBuckets {
+		bucket := RuntimeBucket{Min: Runtime(i * runtimeBucketWidth)}
+		if i < runtimeBuckets-1 {
+			bucket.Max = bucket.Min + runtimeBucketWidth - 1
+		}
+		stats.Runtime.Histogram = append(stats.Runtime.Histogram, bucket)
+	}
+
+	first := recentlyAddedSince(now)
+	for i := range recentlyAddedWeeks {
+		stats.RecentlyAdded = append(stats.RecentlyAdded, WeeklyCount{Week: first.AddDate(0, 0, 7*i).Format(time.DateOnly)})
+	}
+
+	return stats
+}
+
+// runtimeBucket returns the index of the histogram bucket a runtime falls in.
+func runtimeBucket(runtime Runtime) int {
+	return min(int(runtime)/runtimeBucketWidth, runtimeBuckets-1)
+}
+
+// addRecentlyAdded adds count movies to the week starting on week, if that week is one
+// of the recent ones.
+func (s *MovieStats) addRecentlyAdded(week time.Time, count int) {
+	key := week.Format(time.DateOnly)
+	for i := range s.RecentlyAdded {
+		if s.RecentlyAdded[i].Week == key {
+			s.RecentlyAdded[i].Count += count
+			return
+		}
+	}
+}
+
+// weekStart returns the Monday, at midnight UTC, of the week t is in, like
+// date_trunc('week', t AT TIME ZONE 'UTC').
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// recentlyAddedSince returns the start of the oldest week of the recently added trend.
+func recentlyAddedSince(now time.Time) time.Time {
+	return weekStart(now).AddDate(0, 0, -7*(recentlyAddedWeeks-1))
+}
+
+// Stats sums up the movies matching the filters: how many there are and how often they
+// are rated, how they spread over the genres, decades and runtimes, and how many were
+// added each of the last few weeks. Movies in the trash are left out.
+//
+// The queries run in a single read-only REPEATABLE READ transaction, so they all see
+// the same snapshot and the numbers add up even while movies are being changed.
+func (m MovieModel) Stats(ctx context.Context, movieFilters MovieFilters) (*MovieStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	facets, err := queryFacets(ctx, tx, movieFilters, []string{MovieFacetGenres, MovieFacetYear})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	stats := newMovieStats(facets, now)
+
+	var args queryArgs
+	query := fmt.Sprintf(`
+	SELECT count(*), count(*) FILTER (WHERE rating_count > 0), COALESCE(sum(rating_count), 0),
+		COALESCE(min(runtime), 0), COALESCE(max(runtime), 0), COALESCE(round(avg(runtime)), 0)::integer
+	FROM movies
+	WHERE %s
+	`, movieFilters.where(&args))
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&stats.Totals.Movies,
+		&stats.Totals.RatedMovies,
+		&stats.Totals.Ratings,
+		&stats.Runtime.Min,
+		&stats.Runtime.Max,
+		&stats.Runtime.Average,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	args = nil
+	query = fmt.Sprintf(`
+	SELECT LEAST(runtime / %d, %d), count(*)
+	FROM movies
+	WHERE %s
+	GROUP BY 1
+	`, runtimeBucketWidth, runtimeBuckets-1, movieFilters.where(&args))
+
+	err = scanCounts(ctx, tx, query, args, func(bucket int, count int) {
+		stats.Runtime.Histogram[bucket].Count = count
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	args = nil
+	where := movieFilters.where(&args)
+	query = fmt.Sprintf(`
+	SELECT date_trunc('week', created_at AT TIME ZONE 'UTC'), count(*)
+	FROM movies
+	WHERE %s AND created_at >= %s
+	GROUP BY 1
+	`, where, args.add(recentlyAddedSince(now)))
+
+	err = scanCounts(ctx, tx, query, args, func(week time.Time, count int) {
+		stats.addRecentlyAdded(week, count)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, tx.Commit()
+}
+
+// scanCounts runs a query for rows of a key and a count, and calls fn with each.
+func scanCounts[K any](ctx context.Context, db querier, query string, args []any, fn func(K, int)) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key K
+		var count int
+
+		err := rows.Scan(&key, &count)
+		if err != nil {
+			return err
+		}
+		fn(key, count)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,166 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kayconfig/green-light-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+// localeRX matches the locales titles are given in: a BCP 47 language tag made of a
+// language and optional script and region subtags, such as fr, pt-BR or zh-Hant-TW.
+var localeRX = regexp.MustCompile("^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$")
+
+// MovieTitle is the title a movie is known by in a locale, when it differs from its
+// original title. A movie has at most one title per locale.
+type MovieTitle struct {
+	MovieID int64  `json:"movie_id"`
+	Locale  string `json:"locale"`
+	Title   string `json:"title"`
+}
+
+// NormalizeLocale puts the subtags of a language tag in their conventional case, so
+// that fr-ca becomes fr-CA and zh-hant becomes zh-Hant. Underscores are taken for
+// hyphens.
+func NormalizeLocale(locale string) string {
+	subtags := strings.Split(strings.ReplaceAll(locale, "_", "-"), "-")
+	for i, subtag := range subtags {
+		switch {
+		case i == 0:
+			subtags[i] = strings.ToLower(subtag)
+		case len(subtag) == 4:
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		default:
+			subtags[i] = strings.ToUpper(subtag)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+func ValidateMovieTitle(v *validator.Validator, title *MovieTitle) {
+	v.Check(validator.Matches(title.Locale, localeRX), "locale", "must be a language tag such as fr or pt-BR")
+	v.Check(title.Title != "", "title", "must be provided")
+	v.Check(len(title.Title) <= 500, "title", "must not be more than 500 bytes long")
+}
+
+// PickTitle returns the title that best suits the languages, which are language ranges
+// in order of preference as in an Accept-Language header, or nil if none suits them.
+// For each range, an exact match is preferred, then the range with its last subtags
+// dropped (pt-BR falls back to pt), then any more specific locale (pt picks pt-BR).
+func PickTitle(titles []*MovieTitle, languages []string) *MovieTitle {
+	for _, language := range languages {
+		language = NormalizeLocale(language)
+
+		for tag := language; tag != ""; {
+			for _, title := range titles {
+				if title.Locale == tag {
+					return title
+				}
+			}
+
+			i := strings.LastIndex(tag, "-")
+			if i == -1 {
+				break
+			}
+			tag = tag[:i]
+		}
+
+		for _, title := range titles {
+			if strings.HasPrefix(title.Locale, language+"-") {
+				return title
+			}
+		}
+	}
+	return nil
+}
+
+type MovieTitleModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Set gives the movie a title in the locale, replacing the title it had there.
+func (m MovieTitleModel) Set(ctx context.Context, title *MovieTitle) error {
+	query := `
+	INSERT INTO movie_titles (movie_id, locale, title)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (movie_id, locale) DO UPDATE SET title = EXCLUDED.title
+	`
+	args := []any{title.MovieID, title.Locale, title.Title}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m MovieTitleModel) Delete(ctx context.Context, movieID int64, locale string) error {
+	query := `
+	DELETE FROM movie_titles
+	WHERE movie_id = $1 AND locale = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, locale)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MovieTitleModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTitle, error) {
+	titles, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*MovieTitle{}, titles[movieID]...), nil
+}
+
+// GetAllForMovies returns the alternate titles of several movies at once, keyed by
+// movie id and ordered by locale.
+func (m MovieTitleModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieTitle, error) {
+	query := `
+	SELECT movie_id, locale, title
+	FROM movie_titles
+	WHERE movie_id = ANY($1)
+	ORDER BY movie_id, locale
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make(map[int64][]*MovieTitle)
+	for rows.Next() {
+		var title MovieTitle
+		err := rows.Scan(&title.MovieID, &title.Locale, &title.Title)
+		if err != nil {
+			return nil, err
+		}
+		titles[title.MovieID] = append(titles[title.MovieID], &title)
+	}
+
+	return titles, rows.Err()
+}
@@ -0,0 +1,61 @@
+// Package imaging makes thumbnails of uploaded images using only the standard library
+// codecs.
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// ScaledHeight returns the height of a width x height image scaled down to the given
+// width, keeping its aspect ratio. It is never less than a pixel.
+func ScaledHeight(width, height, scaledWidth int) int {
+	return max(1, (height*scaledWidth+width/2)/width)
+}
+
+// Thumbnail scales src down to the given width, keeping its aspect ratio. Each pixel
+// of the thumbnail is the average of the pixels of src it covers, which is slow next
+// to the usual resampling filters but doesn't alias. Transparent areas are filled with
+// white, so that the thumbnail can be encoded as a JPEG.
+func Thumbnail(src image.Image, width int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	height := ScaledHeight(srcWidth, srcHeight, width)
+
+	// reading the pixels of an RGBA copy directly is much faster than calling At for
+	// each of them
+	flat := image.NewRGBA(image.Rect(0, 0, srcWidth, srcHeight))
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := y * srcHeight / height
+		y1 := max((y+1)*srcHeight/height, y0+1)
+
+		for x := range width {
+			x0 := x * srcWidth / width
+			x1 := max((x+1)*srcWidth/width, x0+1)
+
+			var r, g, b int
+			for sy := y0; sy < y1; sy++ {
+				i := flat.PixOffset(x0, sy)
+				for range x1 - x0 {
+					r += int(flat.Pix[i])
+					g += int(flat.Pix[i+1])
+					b += int(flat.Pix[i+2])
+					i += 4
+				}
+			}
+
+			n := (x1 - x0) * (y1 - y0)
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,300 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// documents to JSON values decoded into the generic types of encoding/json: map[string]any,
+// []any, string, float64, bool and nil.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrTestFailed is returned, wrapped, when a test operation finds a different value.
+var ErrTestFailed = errors.New("test operation failed")
+
+// Operation is a single operation of a JSON Patch. Value is left nil when the
+// operation has no "value" member, which is different from a JSON null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitzero"`
+	Value json.RawMessage `json:"value,omitzero"`
+}
+
+// MergePatch applies a JSON Merge Patch to target and returns the result. A null in
+// the patch removes the member, any other value replaces it, and objects are merged
+// recursively. Target may be modified in place.
+func MergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = MergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// Apply applies the operations of a JSON Patch to doc in order, and returns the result.
+// The patch is atomic as far as the caller is concerned: if any operation fails, the
+// error says which one and the result should be thrown away. Doc may be modified in
+// place.
+func Apply(doc any, ops []Operation) (any, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = apply(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, fmt.Errorf("path %w", err)
+	}
+
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%s needs a value", op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("value is not valid JSON")
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("from %w", err)
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+			return nil, fmt.Errorf("can't move %q into one of its children", op.From)
+		}
+
+		value, err = get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			doc, err = remove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	switch op.Op {
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "test":
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w at %q", ErrTestFailed, op.Path)
+		}
+		return doc, nil
+	default: // add, move and copy
+		return add(doc, path, value)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens.
+// The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%q must start with a slash", pointer)
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = unescape.Replace(token)
+	}
+	return tokens, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for i, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(path[:i+1])
+			}
+			doc = value
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, notFound(path[:i+1])
+			}
+			doc = node[index]
+		default:
+			return nil, notFound(path[:i+1])
+		}
+	}
+	return doc, nil
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			index := len(node)
+			if token != "-" {
+				var err error
+				index, err = arrayIndex(token, len(node))
+				if err != nil {
+					return nil, notFound(path)
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, notFound(path)
+		}
+	})
+}
+
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("the whole document can't be removed")
+	}
+
+	return update(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, notFound(path)
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, notFound(path)
+			}
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, notFound(path)
+		}
+	})
+}
+
+// update walks down to the parent of the last token of path and replaces it with what
+// fn returns, since changing the length of an array makes a new slice.
+func update(doc any, path []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, notFound(path[:1])
+		}
+		child, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []any:
+		index, err := arrayIndex(path[0], len(node)-1)
+		if err != nil {
+			return nil, notFound(path[:1])
+		}
+		child, err := update(node[index], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = child
+		return node, nil
+	default:
+		return nil, notFound(path[:1])
+	}
+}
+
+// arrayIndex parses an array index token, which must be a number without leading
+// zeros from 0 to last.
+func arrayIndex(token string, last int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.New("invalid array index")
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > last {
+		return 0, errors.New("invalid array index")
+	}
+	return index, nil
+}
+
+func deepCopy(value any) any {
+	js, err := json.Marshal(value)
+	if err != nil {
+		panic(err) // a decoded JSON value always marshals
+	}
+
+	var c any
+	if err := json.Unmarshal(js, &c); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func notFound(path []string) error {
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+	var b strings.Builder
+	for _, token := range path {
+		b.WriteString("/" + escape.Replace(token))
+	}
+	return fmt.Errorf("path %q does not exist", b.String())
+}
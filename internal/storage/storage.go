@@ -0,0 +1,21 @@
+// Package storage keeps uploaded files, such as movie images, in a blob store: a
+// directory on the local filesystem or a bucket in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// Storage stores files under slash-separated keys like "images/abc.jpg". Keys are
+// chosen by the caller and never reused, so stored files can be cached forever.
+type Storage interface {
+	// Put stores size bytes read from body under key, replacing any file already
+	// stored there.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Delete removes the file stored under key. Deleting a file that doesn't exist is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients can download the file stored under key from.
+	URL(key string) string
+}
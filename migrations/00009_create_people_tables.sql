@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS people (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name TEXT NOT NULL,
+    birth_year INTEGER,
+    biography TEXT NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people USING GIN (to_tsvector('simple', name));
+
+CREATE TABLE IF NOT EXISTS movies_people (
+    id BIGSERIAL PRIMARY KEY,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    person_id BIGINT NOT NULL REFERENCES people ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    character_name TEXT NOT NULL DEFAULT '',
+    billing_order INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT movies_people_role_check CHECK (role IN ('director', 'writer', 'actor')),
+    CONSTRAINT movies_people_credit_key UNIQUE (movie_id, person_id, role, character_name)
+);
+
+CREATE INDEX IF NOT EXISTS movies_people_person_id_idx ON movies_people (person_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movies_people;
+DROP TABLE IF EXISTS people;
+-- +goose StatementEnd
@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS reviews (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    rating INTEGER NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT reviews_rating_check CHECK (rating BETWEEN 1 AND 10),
+    CONSTRAINT reviews_movie_user_key UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+-- the aggregated ratings live on the movies table so that listings can sort by them
+-- without joining reviews; the trigger below keeps them up to date
+ALTER TABLE movies ADD COLUMN average_rating NUMERIC(4, 2) NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN rating_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE OR REPLACE FUNCTION reviews_refresh_movie_rating() RETURNS TRIGGER AS $$
+DECLARE
+    target_movie_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_movie_id := OLD.movie_id;
+    ELSE
+        target_movie_id := NEW.movie_id;
+    END IF;
+
+    UPDATE movies
+    SET average_rating = stats.average_rating, rating_count = stats.rating_count
+    FROM (
+        SELECT COALESCE(ROUND(AVG(rating), 2), 0) AS average_rating, count(*) AS rating_count
+        FROM reviews
+        WHERE movie_id = target_movie_id
+    ) AS stats
+    WHERE movies.id = target_movie_id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reviews_refresh_movie_rating
+AFTER INSERT OR UPDATE OF rating OR DELETE ON reviews
+FOR EACH ROW EXECUTE FUNCTION reviews_refresh_movie_rating();
+
+INSERT INTO permissions (code)
+VALUES ('reviews:write');
+
+-- new users are granted reviews:write when they register, so grant it to the existing
+-- ones as well
+INSERT INTO users_permissions
+SELECT users.id, permissions.id
+FROM users, permissions
+WHERE permissions.code = 'reviews:write';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE code = 'reviews:write';
+DROP TABLE IF EXISTS reviews;
+DROP FUNCTION IF EXISTS reviews_refresh_movie_rating;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE movies DROP COLUMN IF EXISTS average_rating;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS lists (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT lists_user_name_key UNIQUE (user_id, name)
+);
+
+-- position is only used for ordering; the API numbers the movies of a list from 1.
+-- The unique constraint is deferred so that a reorder can rewrite every position in
+-- a single statement.
+CREATE TABLE IF NOT EXISTS lists_movies (
+    list_id BIGINT NOT NULL REFERENCES lists ON DELETE CASCADE,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (list_id, movie_id),
+    CONSTRAINT lists_movies_position_key UNIQUE (list_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS lists_movies_movie_id_idx ON lists_movies (movie_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS lists_movies;
+DROP TABLE IF EXISTS lists;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE movies ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- only the trash listing and the purge look for deleted movies, and the trash is small
+CREATE INDEX IF NOT EXISTS movies_deleted_at_idx ON movies (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES ('movies:admin');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE code = 'movies:admin';
+DROP INDEX IF EXISTS movies_deleted_at_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    -- the history outlives the user who made the change
+    user_id BIGINT REFERENCES users ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    snapshot JSONB NOT NULL,
+    CONSTRAINT movie_revisions_action_check CHECK (action IN ('insert', 'update', 'delete', 'restore', 'revert'))
+);
+
+CREATE INDEX IF NOT EXISTS movie_revisions_movie_id_idx ON movie_revisions (movie_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movie_revisions;
+-- +goose StatementEnd
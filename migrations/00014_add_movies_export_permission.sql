@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO permissions (code)
+VALUES ('movies:export');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE code = 'movies:export';
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the weighted document for full-text searches: the title ranks above the genres.
+-- array_to_string() is only stable, so the function is declared immutable for it to
+-- be usable in an index
+CREATE OR REPLACE FUNCTION movie_search_vector(config regconfig, title text, genres text[])
+RETURNS tsvector AS $$
+	SELECT setweight(to_tsvector(config, title), 'A') ||
+		setweight(to_tsvector(config, array_to_string(genres, ' ')), 'B')
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE INDEX IF NOT EXISTS movies_search_simple_idx ON movies USING GIN (movie_search_vector('simple', title, genres));
+CREATE INDEX IF NOT EXISTS movies_search_english_idx ON movies USING GIN (movie_search_vector('english', title, genres));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS movies_search_english_idx;
+DROP INDEX IF EXISTS movies_search_simple_idx;
+DROP FUNCTION IF EXISTS movie_search_vector(regconfig, text, text[]);
+-- +goose StatementEnd
@@ -0,0 +1,90 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS genres (
+    id BIGSERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    parent TEXT REFERENCES genres (slug) ON DELETE SET NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT INTO genres (slug, name, parent, aliases)
+VALUES
+    ('action', 'Action', NULL, '{}'),
+    ('adventure', 'Adventure', NULL, '{}'),
+    ('animation', 'Animation', NULL, '{animated,cartoon}'),
+    ('comedy', 'Comedy', NULL, '{}'),
+    ('crime', 'Crime', NULL, '{}'),
+    ('documentary', 'Documentary', NULL, '{}'),
+    ('drama', 'Drama', NULL, '{}'),
+    ('family', 'Family', NULL, '{}'),
+    ('fantasy', 'Fantasy', NULL, '{}'),
+    ('history', 'History', NULL, '{}'),
+    ('horror', 'Horror', NULL, '{}'),
+    ('musical', 'Musical', NULL, '{}'),
+    ('mystery', 'Mystery', NULL, '{}'),
+    ('romance', 'Romance', NULL, '{}'),
+    ('sci-fi', 'Science Fiction', NULL, '{scifi,sf}'),
+    ('thriller', 'Thriller', NULL, '{}'),
+    ('war', 'War', NULL, '{}'),
+    ('western', 'Western', NULL, '{}'),
+    ('romantic-comedy', 'Romantic Comedy', 'comedy', '{rom-com,romcom}'),
+    ('superhero', 'Superhero', 'action', '{}');
+
+-- Every name a genre goes by, keyed the way genreKey() in internal/data compares
+-- them: lowercase, with runs of spaces, hyphens and underscores turned into a space.
+CREATE TEMPORARY TABLE genre_names (
+    key TEXT PRIMARY KEY,
+    slug TEXT NOT NULL
+) ON COMMIT DROP;
+
+INSERT INTO genre_names (key, slug)
+SELECT trim(regexp_replace(lower(names.name), '[\s_-]+', ' ', 'g')), genres.slug
+FROM genres, unnest(ARRAY[genres.slug, genres.name] || genres.aliases) AS names (name)
+ON CONFLICT DO NOTHING;
+
+-- Genres the movies use that aren't in the taxonomy are added to it, so that no movie
+-- is left with a genre ValidateMovie would reject.
+CREATE TEMPORARY TABLE unknown_genres ON COMMIT DROP AS
+SELECT DISTINCT key, trim(BOTH '-' FROM regexp_replace(key, '[^a-z0-9]+', '-', 'g')) AS slug
+FROM (
+    SELECT trim(regexp_replace(lower(genre), '[\s_-]+', ' ', 'g')) AS key
+    FROM movies, unnest(genres) AS genre
+) used
+WHERE key NOT IN (SELECT key FROM genre_names);
+
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (slug) slug, initcap(key)
+FROM unknown_genres
+WHERE slug <> ''
+ORDER BY slug, key
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO genre_names (key, slug)
+SELECT key, slug
+FROM unknown_genres
+WHERE slug <> ''
+ON CONFLICT DO NOTHING;
+
+-- Replace the genres of every movie by their slugs, keeping the first of any that turn
+-- out to be the same genre, in their original order.
+UPDATE movies
+SET genres = normalized.genres, version = movies.version + 1, updated_at = CURRENT_TIMESTAMP
+FROM (
+    SELECT movies.id, array_agg(slugs.slug ORDER BY slugs.position) AS genres
+    FROM movies, LATERAL (
+        SELECT COALESCE(genre_names.slug, g.genre) AS slug, min(g.position) AS position
+        FROM unnest(movies.genres) WITH ORDINALITY AS g (genre, position)
+        LEFT JOIN genre_names ON genre_names.key = trim(regexp_replace(lower(g.genre), '[\s_-]+', ' ', 'g'))
+        GROUP BY 1
+    ) slugs
+    GROUP BY movies.id
+) normalized
+WHERE movies.id = normalized.id AND movies.genres <> normalized.genres;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS genres;
+-- +goose StatementEnd
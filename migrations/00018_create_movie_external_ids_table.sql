@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    PRIMARY KEY (movie_id, source),
+    CONSTRAINT movie_external_ids_source_check CHECK (source IN ('imdb', 'tmdb', 'wikidata')),
+    CONSTRAINT movie_external_ids_source_external_id_key UNIQUE (source, external_id)
+);
+
+-- duplicates are spotted by title and year, with the title normalized the same way as
+-- normalizeTitle() in internal/data
+CREATE INDEX IF NOT EXISTS movies_normalized_title_year_idx
+ON movies ((trim(regexp_replace(lower(title), '[^[:alnum:]]+', ' ', 'g'))), year)
+WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS movies_normalized_title_year_idx;
+DROP TABLE IF EXISTS movie_external_ids;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS collections (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT collections_name_key UNIQUE (name),
+    CONSTRAINT collections_kind_check CHECK (kind IN ('series', 'franchise', 'curated'))
+);
+
+-- ordered like lists_movies, with the same deferred constraint on positions
+CREATE TABLE IF NOT EXISTS collections_movies (
+    collection_id BIGINT NOT NULL REFERENCES collections ON DELETE CASCADE,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, movie_id),
+    CONSTRAINT collections_movies_position_key UNIQUE (collection_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS collections_movies_movie_id_idx ON collections_movies (movie_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS collections_movies;
+DROP TABLE IF EXISTS collections;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS movie_titles (
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    title TEXT NOT NULL,
+    PRIMARY KEY (movie_id, locale)
+);
+
+-- the title filter and the fuzzy search look through the alternate titles too
+CREATE INDEX IF NOT EXISTS movie_titles_title_idx ON movie_titles USING GIN (to_tsvector('simple', title));
+CREATE INDEX IF NOT EXISTS movie_titles_title_trgm_idx ON movie_titles USING GIN (title gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS movie_releases (
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country TEXT NOT NULL,
+    release_date DATE NOT NULL,
+    certification TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (movie_id, country),
+    CONSTRAINT movie_releases_country_check CHECK (country ~ '^[A-Z]{2}$')
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movie_releases;
+DROP TABLE IF EXISTS movie_titles;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS movie_images (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size BIGINT NOT NULL,
+    -- where the original is kept in the storage; the thumbnails list theirs
+    storage_key TEXT NOT NULL UNIQUE,
+    thumbnails JSONB NOT NULL DEFAULT '[]',
+    CONSTRAINT movie_images_kind_check CHECK (kind IN ('poster', 'backdrop'))
+);
+
+CREATE INDEX IF NOT EXISTS movie_images_movie_id_idx ON movie_images (movie_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movie_images;
+-- +goose StatementEnd
@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the ratings are part of a movie, so a change to them makes a new version, like any
+-- other change would, and ETags taken before it go stale
+CREATE OR REPLACE FUNCTION reviews_refresh_movie_rating() RETURNS TRIGGER AS $$
+DECLARE
+    target_movie_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_movie_id := OLD.movie_id;
+    ELSE
+        target_movie_id := NEW.movie_id;
+    END IF;
+
+    UPDATE movies
+    SET average_rating = stats.average_rating, rating_count = stats.rating_count,
+        version = movies.version + 1, updated_at = NOW()
+    FROM (
+        SELECT COALESCE(ROUND(AVG(rating), 2), 0) AS average_rating, count(*) AS rating_count
+        FROM reviews
+        WHERE movie_id = target_movie_id
+    ) AS stats
+    WHERE movies.id = target_movie_id
+    AND (movies.average_rating, movies.rating_count) IS DISTINCT FROM (stats.average_rating, stats.rating_count);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION reviews_refresh_movie_rating() RETURNS TRIGGER AS $$
+DECLARE
+    target_movie_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_movie_id := OLD.movie_id;
+    ELSE
+        target_movie_id := NEW.movie_id;
+    END IF;
+
+    UPDATE movies
+    SET average_rating = stats.average_rating, rating_count = stats.rating_count
+    FROM (
+        SELECT COALESCE(ROUND(AVG(rating), 2), 0) AS average_rating, count(*) AS rating_count
+        FROM reviews
+        WHERE movie_id = target_movie_id
+    ) AS stats
+    WHERE movies.id = target_movie_id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
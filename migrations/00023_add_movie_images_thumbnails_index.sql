@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the files of the local storage are served by looking up the image they belong to,
+-- which for a thumbnail means searching the thumbnails column
+CREATE INDEX IF NOT EXISTS movie_images_thumbnails_idx ON movie_images USING GIN (thumbnails jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS movie_images_thumbnails_idx;
+-- +goose StatementEnd